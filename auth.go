@@ -0,0 +1,222 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"github.com/hewiefreeman/GopherDB/helpers"
+	"strings"
+	"sync"
+)
+
+const (
+	credentialEncryptCost int = 10 // bcrypt cost for the master password and credential passwords
+
+	// Table name for privileges that apply to every table
+	allTables = "*"
+)
+
+var (
+	// Passwords that have already passed a bcrypt check, stored as an HMAC-SHA256 under authCacheKey by credential
+	// name ("" for the master password). Keeps the bcrypt cost off of every request from a client that has already
+	// authenticated.
+	authCacheMux sync.Mutex
+	authCache    map[string][]byte = make(map[string][]byte)
+	authCacheGen uint64            // times the authCache was cleared - checks that started before a clear aren't cached
+	authCacheKey []byte            = newAuthCacheKey()
+)
+
+// Makes a random key for the authCache's HMACs. The key never leaves the process, so the cached sums can't be used
+// to check guesses of a password without it.
+func newAuthCacheKey() []byte {
+	key := make([]byte, sha256.Size)
+	if _, err := rand.Read(key); err != nil {
+		panic("Failed to make the auth cache key: " + err.Error())
+	}
+	return key
+}
+
+// credential is a named login for the query server, along with the privileges it grants.
+type credential struct {
+	Name       string
+	Pass       string // bcrypt encrypted password - plain text passwords are encrypted when the config file is loaded
+	Privileges privileges
+}
+
+// privileges describes which queries a connection may run.
+type privileges struct {
+	Admin  bool                       // Allows admin queries (SetEncryptionCost, SetMaxEntries, etc.)
+	Tables map[string]tablePrivileges // Privileges by table name - "*" applies to all tables
+}
+
+// tablePrivileges describes which queries a connection may run on a table.
+type tablePrivileges struct {
+	Read    bool     // Allows Get, Select, Scan and Range queries
+	Write   bool     // Allows Insert, Update, Upsert, Delete, Refresh, ChangePassword and ResetPassword queries - a Transaction needs the privileges of every query in it
+	Queries []string // Allows specific query types (eg: "NewTable", "DeleteTable", "Update")
+}
+
+// connection is an authenticated client of the query server.
+type connection struct {
+	client string      // Client address
+	name   string      // Credential name - empty for the master password
+	master bool        // Connections authenticated with the master password have every privilege
+	privs  *privileges // Privileges for non-master connections
+}
+
+// authenticate checks a name and password against the master password (when name is empty) or the named credential,
+// and returns a connection for the client with the matching privileges. An empty master password turns off master
+// logins - authentication is only disabled when there are no credentials either.
+func authenticate(client string, name string, pass string) (*connection, int) {
+	statusMux.Lock()
+	mPass := masterPass
+	statusMux.Unlock()
+	if name == "" {
+		if len(mPass) == 0 {
+			confMux.Lock()
+			noCredentials := len(conf.Credentials) == 0
+			confMux.Unlock()
+			if noCredentials {
+				// Authentication is disabled
+				return &connection{client: client, master: true}, 0
+			}
+			return nil, helpers.ErrorNotAuthenticated
+		}
+		if err := checkCredentialPass(client, name, pass, string(mPass)); err != 0 {
+			return nil, err
+		}
+		return &connection{client: client, master: true}, 0
+	}
+	// Copy the credential, so the bcrypt check doesn't hold up other logins and config changes
+	var cred *credential
+	confMux.Lock()
+	for i := range conf.Credentials {
+		if conf.Credentials[i].Name == name {
+			c := conf.Credentials[i]
+			cred = &c
+			break
+		}
+	}
+	confMux.Unlock()
+	if cred == nil {
+		return nil, helpers.ErrorNotAuthenticated
+	}
+	if err := checkCredentialPass(client, name, pass, cred.Pass); err != 0 {
+		return nil, err
+	}
+	return &connection{client: client, name: name, privs: &cred.Privileges}, 0
+}
+
+// Compares a password with a credential's encrypted password, using the authCache when possible. bcrypt checks
+// count towards the client's bcrypt heavy query rate limit.
+func checkCredentialPass(client string, name string, pass string, hash string) int {
+	mac := hmac.New(sha256.New, authCacheKey)
+	mac.Write([]byte(pass))
+	sum := mac.Sum(nil)
+	authCacheMux.Lock()
+	cached, ok := authCache[name]
+	gen := authCacheGen
+	authCacheMux.Unlock()
+	if ok && subtle.ConstantTimeCompare(cached, sum) == 1 {
+		return 0
+	}
+	if !allowAuth(client) {
+		return helpers.ErrorRateLimited
+	}
+	if !helpers.StringMatchesEncryption(pass, []byte(hash)) {
+		return helpers.ErrorNotAuthenticated
+	}
+	authCacheMux.Lock()
+	if gen == authCacheGen {
+		authCache[name] = sum
+	}
+	authCacheMux.Unlock()
+	return 0
+}
+
+// Clears the authCache. Must be called any time the config's credentials change.
+func resetAuthCache() {
+	authCacheMux.Lock()
+	authCache = make(map[string][]byte)
+	authCacheGen++
+	authCacheMux.Unlock()
+}
+
+// Encrypts the master password and credential passwords that are still in plain text - must lock confMux before-hand.
+func encryptConfigPasswords() int {
+	if conf.MasterPass != "" && !isEncrypted(conf.MasterPass) {
+		ePass, err := helpers.EncryptString(conf.MasterPass, credentialEncryptCost)
+		if err != nil {
+			return helpers.ErrorEncryptingString
+		}
+		conf.MasterPass = string(ePass)
+	}
+	for i := range conf.Credentials {
+		if len(conf.Credentials[i].Name) == 0 || len(conf.Credentials[i].Pass) == 0 {
+			return helpers.ErrorNameRequired
+		} else if !isEncrypted(conf.Credentials[i].Pass) {
+			ePass, err := helpers.EncryptString(conf.Credentials[i].Pass, credentialEncryptCost)
+			if err != nil {
+				return helpers.ErrorEncryptingString
+			}
+			conf.Credentials[i].Pass = string(ePass)
+		}
+	}
+	return 0
+}
+
+// Checks if a password from the config file is a bcrypt hash
+func isEncrypted(pass string) bool {
+	return len(pass) == 60 && strings.HasPrefix(pass, "$2")
+}
+
+// allowed checks if the connection has the privileges to run a query type on a table.
+func (c *connection) allowed(qType string, tableName string) bool {
+	if c.master {
+		return true
+	} else if c.privs == nil {
+		return false
+	}
+	if isAdminQuery(qType) {
+		return c.privs.Admin
+	}
+	if tp, ok := c.privs.Tables[tableName]; ok && tp.allowed(qType) {
+		return true
+	}
+	if tp, ok := c.privs.Tables[allTables]; ok && tp.allowed(qType) {
+		return true
+	}
+	return false
+}
+
+func (tp tablePrivileges) allowed(qType string) bool {
+	switch qType {
+	case queryTypeGet, queryTypeSelect, queryTypeScan, queryTypeRange:
+		if tp.Read {
+			return true
+		}
+	case queryTypeInsert, queryTypeUpdate, queryTypeUpsert, queryTypeDelete, queryTypeRefresh,
+		queryTypeChangePassword, queryTypeResetPassword:
+		if tp.Write {
+			return true
+		}
+	}
+	for _, q := range tp.Queries {
+		if q == qType {
+			return true
+		}
+	}
+	return false
+}
+
+// Checks if a query type changes table settings
+func isAdminQuery(qType string) bool {
+	switch qType {
+	case queryTypeSetEncryptionCost, queryTypeSetMaxEntries, queryTypeSetPartitionMax, queryTypeSetSyncPolicy,
+		queryTypeSetCompactRatio, queryTypeCompact, queryTypeCheckIntegrity, queryTypeSetMinPasswordLength, queryTypeSetPasswordResetLength,
+		queryTypeSetAltLoginItem, queryTypeSetEmailItem:
+		return true
+	}
+	return false
+}
@@ -3,10 +3,7 @@ package authtable
 import (
 	"github.com/hewiefreeman/GopherDB/helpers"
 	"github.com/hewiefreeman/GopherDB/schema"
-	"github.com/hewiefreeman/GopherDB/storage"
-	"strconv"
 	"strings"
-	"encoding/json"
 	"regexp"
 )
 
@@ -29,6 +26,44 @@ func makeJsonBytes(name string, password []byte, data []interface{}, jBytes *[]b
 	return 0
 }
 
+// Makes the bytes stored for a user in the AuthTable's encoding
+func (t *AuthTable) makeEntryBytes(name string, password []byte, data []interface{}, eBytes *[]byte) int {
+	if t.encoding != helpers.EncodingBinary {
+		return makeJsonBytes(name, password, data, eBytes)
+	}
+	var err int
+	b := schema.AppendBinaryString(schema.AppendBinaryString(nil, name), string(password))
+	*eBytes, err = t.schema.AppendBinary(b, data)
+	return err
+}
+
+// Reads the name, password and data of a user from it's stored bytes. Data from binary records already has the
+// types the AuthTable keeps in memory.
+func (t *AuthTable) readEntryBytes(b []byte) (string, string, []interface{}, int) {
+	if t.encoding != helpers.EncodingBinary {
+		name, pass, data := restoreDataLine(b)
+		if data == nil {
+			return "", "", nil, helpers.ErrorJsonDecoding
+		}
+		return name, pass, data, 0
+	}
+	name, rest, err := schema.ReadBinaryString(b)
+	if err != 0 {
+		return "", "", nil, err
+	}
+	pass, rest, err := schema.ReadBinaryString(rest)
+	if err != 0 {
+		return "", "", nil, err
+	}
+	data, rest, err := t.schema.ReadBinary(rest)
+	if err != 0 {
+		return "", "", nil, err
+	} else if name == "" || pass == "" || len(rest) > 0 {
+		return "", "", nil, helpers.ErrorBinaryDecoding
+	}
+	return name, pass, data, 0
+}
+
 var (
 	emailExp = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+\\/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
 )
@@ -88,7 +123,7 @@ func (t *AuthTable) NewUser(name string, password string, insertObj map[string]i
 	// Make JSON []byte for entry
 	var jBytes []byte
 	if !t.memOnly {
-		if jErr := makeJsonBytes(name, ePass, ute.data, &jBytes); jErr != 0 {
+		if jErr := t.makeEntryBytes(name, ePass, ute.data, &jBytes); jErr != 0 {
 			helpers.LogAndPrint("Auth '" + t.name + "' JSON failure on a NewUser() request", 4)
 			return nil, helpers.NewError(jErr, name)
 		}
@@ -96,6 +131,8 @@ func (t *AuthTable) NewUser(name string, password string, insertObj map[string]i
 
 	// Lock table, check for duplicate entry
 	maxEntries := t.maxEntries.Load().(uint64)
+	t.pMux.RLock()
+	defer t.pMux.RUnlock()
 	t.eMux.Lock()
 	if t.entries[name] != nil {
 		t.eMux.Unlock()
@@ -117,10 +154,10 @@ func (t *AuthTable) NewUser(name string, password string, insertObj map[string]i
 		}*/
 	}
 	// Append jBytes to fileOn and get the persistIndex
-	var lineOn uint16
+	var lineOn uint32
 	if !t.memOnly {
 		var aErr int
-		lineOn, aErr = storage.Insert(dataFolderPrefix + t.name + "/" + strconv.Itoa(int(t.fileOn)) + helpers.FileTypeStorage, jBytes)
+		lineOn, aErr = t.engine.Insert(t.fileOn, jBytes)
 		if aErr != 0 {
 			t.uMux.Unlock()
 			t.eMux.Unlock()
@@ -143,7 +180,7 @@ func (t *AuthTable) NewUser(name string, password string, insertObj map[string]i
 	ute.persistFile = t.fileOn
 
 	// Increase fileOn when the index has reached or surpassed partitionMax
-	pMax := t.partitionMax.Load().(uint16)
+	pMax := t.partitionMax.Load().(uint32)
 	if ute.persistIndex >= pMax {
 		t.fileOn++
 		conf := t.makeDefaultConfig(t.fileOn)
@@ -184,7 +221,9 @@ func (t *AuthTable) GetUser(userName string, password string, items map[string]i
 	// Get entry data
 	if t.dataOnDrive {
 		var dErr int
-		data, dErr = t.dataFromDrive(dataFolderPrefix + t.name + "/" + strconv.Itoa(int(e.persistFile)) + helpers.FileTypeStorage, e.persistIndex)
+		t.pMux.RLock()
+		data, dErr = t.dataFromDrive(e.persistFile, e.persistIndex)
+		t.pMux.RUnlock()
 		if dErr != 0 {
 			helpers.LogAndPrint("Auth '" + t.name + "' failed to retrieve data for a GetUser() request", 4)
 			return nil, helpers.NewError(dErr, userName)
@@ -227,21 +266,14 @@ func (t *AuthTable) GetUser(userName string, password string, items map[string]i
 	return items, helpers.Error{}
 }
 
-func (t *AuthTable) dataFromDrive(file string, index uint16) ([]interface{}, int) {
-	// Read bytes from file
-	bytes, rErr := storage.Read(file, index)
+func (t *AuthTable) dataFromDrive(partition uint32, index uint32) ([]interface{}, int) {
+	// Read bytes from storage
+	bytes, rErr := t.engine.Read(uint32(partition), index)
 	if rErr != 0 {
 		return nil, rErr
 	}
-	var jEntry jsonEntry
-	jErr := json.Unmarshal(bytes, &jEntry)
-	if jErr != nil {
-		return nil, helpers.ErrorJsonDecoding
-	}
-	if jEntry.D == nil || len(jEntry.D) == 0 {
-		return nil, helpers.ErrorJsonDecoding
-	}
-	return jEntry.D, 0
+	_, _, data, dErr := t.readEntryBytes(bytes)
+	return data, dErr
 }
 
 // Example JSON for update query:
@@ -288,12 +320,16 @@ func (t *AuthTable) UpdateUser(userName string, password string, updateObj map[s
 		return helpers.NewError(err, userName)
 	}
 
+	// Keep the user in it's line until the update is done
+	t.pMux.RLock()
+	defer t.pMux.RUnlock()
+
 	var data []interface{}
 
 	// Get entry data
 	if t.dataOnDrive {
 		var dErr int
-		data, dErr = t.dataFromDrive(dataFolderPrefix + t.name + "/" + strconv.Itoa(int(e.persistFile)) + helpers.FileTypeStorage, e.persistIndex)
+		data, dErr = t.dataFromDrive(e.persistFile, e.persistIndex)
 		if dErr != 0 {
 			helpers.LogAndPrint("Auth '" + t.name + "' failed to retrieve data for an UpdateUser() request", 4)
 			return helpers.NewError(dErr, userName)
@@ -340,7 +376,7 @@ func (t *AuthTable) UpdateUser(userName string, password string, updateObj map[s
 	// Make JSON []byte for entry
 	var jBytes []byte
 	if !t.memOnly {
-		if jErr := makeJsonBytes(userName, e.password.Load().([]byte), data, &jBytes); jErr != 0 {
+		if jErr := t.makeEntryBytes(userName, e.password.Load().([]byte), data, &jBytes); jErr != 0 {
 			helpers.LogAndPrint("Auth '" + t.name + "' JSON failure on an UpdateUser() request", 4)
 			return helpers.NewError(jErr, userName)
 		}
@@ -360,7 +396,7 @@ func (t *AuthTable) UpdateUser(userName string, password string, updateObj map[s
 
 	// Update entry on disk with jBytes
 	if !t.memOnly {
-		uErr := storage.Update(dataFolderPrefix + t.name + "/" + strconv.Itoa(int(e.persistFile)) + helpers.FileTypeStorage, e.persistIndex, jBytes)
+		uErr := t.engine.Update(e.persistFile, e.persistIndex, jBytes)
 		if uErr != 0 {
 			t.uMux.Unlock()
 			e.mux.Unlock()
@@ -416,12 +452,16 @@ func (t *AuthTable) ChangeUserPassword(userName string, password string, newPass
 		return helpers.NewError(helpers.ErrorPasswordEncryption, userName)
 	}
 
+	// Keep the user in it's line until the password change is done
+	t.pMux.RLock()
+	defer t.pMux.RUnlock()
+
 	var data []interface{}
 
 	// Get entry data
 	if t.dataOnDrive {
 		var dErr int
-		data, dErr = t.dataFromDrive(dataFolderPrefix + t.name + "/" + strconv.Itoa(int(ue.persistFile)) + helpers.FileTypeStorage, ue.persistIndex)
+		data, dErr = t.dataFromDrive(ue.persistFile, ue.persistIndex)
 		if dErr != 0 {
 			helpers.LogAndPrint("Auth '" + t.name + "' failed to retrieve data for a ChangeUserPassword() request", 4)
 			return helpers.NewError(dErr, userName)
@@ -435,13 +475,13 @@ func (t *AuthTable) ChangeUserPassword(userName string, password string, newPass
 	if !t.memOnly {
 		// Make JSON []byte for entry
 		var jBytes []byte
-		if jErr := makeJsonBytes(userName, ePass, data, &jBytes); jErr != 0 {
+		if jErr := t.makeEntryBytes(userName, ePass, data, &jBytes); jErr != 0 {
 			helpers.LogAndPrint("Auth '" + t.name + "' JSON failure on a ChangeUserPassword() request", 4)
 			return helpers.NewError(jErr, userName)
 		}
 
 		// Update entry on disk with jBytes
-		uErr := storage.Update(dataFolderPrefix + t.name + "/" + strconv.Itoa(int(ue.persistFile)) + helpers.FileTypeStorage, ue.persistIndex, jBytes)
+		uErr := t.engine.Update(ue.persistFile, ue.persistIndex, jBytes)
 		if uErr != 0 {
 			helpers.LogAndPrint("Auth '" + t.name + "' failed to store a ChangeUserPassword() request", 4)
 			return helpers.NewError(uErr, userName)
@@ -487,12 +527,16 @@ func (t *AuthTable) ResetUserPassword(userName string) helpers.Error {
 		return helpers.Error{}
 	}
 
+	// Keep the user in it's line until the password reset is done
+	t.pMux.RLock()
+	defer t.pMux.RUnlock()
+
 	var data []interface{}
 
 	// Get entry data
 	if t.dataOnDrive {
 		var dErr int
-		data, dErr = t.dataFromDrive(dataFolderPrefix + t.name + "/" + strconv.Itoa(int(ue.persistFile)) + helpers.FileTypeStorage, ue.persistIndex)
+		data, dErr = t.dataFromDrive(ue.persistFile, ue.persistIndex)
 		if dErr != 0 {
 			helpers.LogAndPrint("Auth '" + t.name + "' failed to retrieve data for a ResetUserPassword() request", 4)
 			return helpers.Error{}
@@ -515,13 +559,13 @@ func (t *AuthTable) ResetUserPassword(userName string) helpers.Error {
 	if !t.memOnly {
 		// Make JSON []byte for entry
 		var jBytes []byte
-		if jErr := makeJsonBytes(userName, ePass, data, &jBytes); jErr != 0 {
+		if jErr := t.makeEntryBytes(userName, ePass, data, &jBytes); jErr != 0 {
 			helpers.LogAndPrint("Auth '" + t.name + "' JSON failure on a ResetUserPassword() request", 4)
 			return helpers.Error{}
 		}
 
 		// Update entry on disk with jBytes
-		uErr := storage.Update(dataFolderPrefix + t.name + "/" + strconv.Itoa(int(ue.persistFile)) + helpers.FileTypeStorage, ue.persistIndex, jBytes)
+		uErr := t.engine.Update(ue.persistFile, ue.persistIndex, jBytes)
 		if uErr != 0 {
 			helpers.LogAndPrint("Auth '" + t.name + "' failed to store a ResetUserPassword() request", 4)
 			return helpers.Error{}
@@ -541,12 +585,16 @@ func (t *AuthTable) DeleteUser(userName string, password string) helpers.Error {
 		return helpers.NewError(err, userName)
 	}
 
+	// Keep the user in it's line until the delete is done
+	t.pMux.RLock()
+	defer t.pMux.RUnlock()
+
 	var data []interface{}
 
 	// Get entry data
 	if t.dataOnDrive {
 		var dErr int
-		data, dErr = t.dataFromDrive(dataFolderPrefix + t.name + "/" + strconv.Itoa(int(ue.persistFile)) + helpers.FileTypeStorage, ue.persistIndex)
+		data, dErr = t.dataFromDrive(ue.persistFile, ue.persistIndex)
 		if dErr != 0 {
 			helpers.LogAndPrint("Auth '" + t.name + "' failed to retrieve data for a DeleteUser() request", 4)
 			return helpers.NewError(dErr, userName)
@@ -598,11 +646,13 @@ func (t *AuthTable) DeleteUser(userName string, password string) helpers.Error {
 
 	// Update entry on disk with []byte{}
 	if !t.memOnly {
-		uErr := storage.Update(dataFolderPrefix + t.name + "/" + strconv.Itoa(int(ue.persistFile)) + helpers.FileTypeStorage, ue.persistIndex, []byte{})
+		uErr := t.engine.Update(ue.persistFile, ue.persistIndex, []byte{})
 		if uErr != 0 {
 			helpers.LogAndPrint("Auth '" + t.name + "' failed execute a DeleteUser() request due to internal storage engine error", 4)
 			return helpers.NewError(uErr, userName)
 		}
+		// Count the deleted line towards compaction
+		t.addDeadLine(ue.persistFile)
 	}
 
 	//
@@ -610,7 +660,7 @@ func (t *AuthTable) DeleteUser(userName string, password string) helpers.Error {
 }
 
 // RestoreUser is NOT concurrently safe! Use authtable.Restore() instead.
-func (t *AuthTable) restoreUser(name string, pass []byte, data []interface{}, fileOn uint16, lineOn uint16) int {
+func (t *AuthTable) restoreUser(name string, pass []byte, data []interface{}, fileOn uint32, lineOn uint32) int {
 	// Check for duplicate entry
 	if t.entries[name] != nil {
 		return helpers.ErrorKeyInUse
@@ -625,21 +675,33 @@ func (t *AuthTable) restoreUser(name string, pass []byte, data []interface{}, fi
 	altLogin := ""
 	altLoginItem := t.AltLoginItem()
 
-	// Fill entry data with data
-	for itemName, schemaItem := range t.schema {
-		// Check for out of range item
-		if int(schemaItem.DataIndex()) > len(data)-1 {
+	if t.encoding == helpers.EncodingBinary {
+		// Binary data already has the right types - only get it's unique values
+		if len(data) != len(t.schema) {
 			return helpers.ErrorRestoreItemSchema
 		}
-
-		// Item filter
-		err := schema.ItemFilter(data[schemaItem.DataIndex()], nil, &e.data[schemaItem.DataIndex()], nil, schemaItem, &uniqueVals, 0, false, true)
-		if err != 0 {
-			return err
+		e.data = data
+		schema.GetUniqueValues(t.schema, data, &uniqueVals, "")
+		if si, ok := t.schema[altLoginItem]; ok {
+			altLogin, _ = data[si.DataIndex()].(string)
 		}
+	} else {
+		// Fill entry data with data
+		for itemName, schemaItem := range t.schema {
+			// Check for out of range item
+			if int(schemaItem.DataIndex()) > len(data)-1 {
+				return helpers.ErrorRestoreItemSchema
+			}
 
-		if itemName == altLoginItem {
-			altLogin = e.data[schemaItem.DataIndex()].(string)
+			// Item filter
+			err := schema.ItemFilter(data[schemaItem.DataIndex()], nil, &e.data[schemaItem.DataIndex()], nil, schemaItem, &uniqueVals, 0, false, true)
+			if err != 0 {
+				return err
+			}
+
+			if itemName == altLoginItem {
+				altLogin = e.data[schemaItem.DataIndex()].(string)
+			}
 		}
 	}
 
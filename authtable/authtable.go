@@ -27,7 +27,6 @@ import (
 	"io"
 	"encoding/json"
 	"net/smtp"
-	"strings"
 	"strconv"
 	"fmt"
 )
@@ -52,7 +51,7 @@ var (
 
 // Authtable
 type AuthTable struct {
-	fileOn    uint16 // locked by eMux - placed for memory efficiency
+	fileOn    uint32 // locked by eMux - placed for memory efficiency
 
 	// Settings and schema - read only
 	memOnly       bool // Store data in memory only (overrides dataOnDrive)
@@ -60,12 +59,16 @@ type AuthTable struct {
 	name          string // table's logger/persist folder name
 	schema        schema.Schema // table's schema
 	configFile    *os.File // config file
+	engine        storage.Engine // storage engine of the table's data
+	encoding      string // encoding of users in storage
 
 	// Atomic changeable settings values - 99% read
-	partitionMax  atomic.Value // *uint16* maximum entries per data file
+	partitionMax  atomic.Value // *uint32* maximum entries per data file
 	maxEntries    atomic.Value // *uint64* maximum amount of entries in the AuthTable
 	minPassword   atomic.Value // *uint8* minimum password length
 	encryptCost   atomic.Value // *int* encryption cost of passwords
+	syncPolicy    atomic.Value // *uint8* storage sync policy of data files
+	compactRatio  atomic.Value // *float64* ratio of deleted lines that triggers a partition's compaction - 0 turns it off
 	passResetLen  atomic.Value // *uint8* the length of passwords created by the database
 	emailItem     atomic.Value // *string* item in schema that represents a user's email address
 	verifyItem    atomic.Value // *string* when set, the database will send a verified boolean for the User along with insert/update/get queries. The verified boolean is true if the User has successfully verified their account through email. Requires emailItem to be set
@@ -81,6 +84,10 @@ type AuthTable struct {
 	// unique values
 	uMux       sync.Mutex
 	uniqueVals map[string]map[interface{}]bool
+
+	// compaction
+	pMux      sync.RWMutex       // entry persistFile/persistIndex lock - write locked while compacting a partition
+	compactor *storage.Compactor // counts deleted lines, and compacts data files
 }
 
 type EmailSettings struct {
@@ -97,8 +104,8 @@ type EmailSettings struct {
 }
 
 type authTableEntry struct {
-	persistFile  uint16
-	persistIndex uint16
+	persistFile  uint32
+	persistIndex uint32
 
 	password atomic.Value
 
@@ -107,14 +114,19 @@ type authTableEntry struct {
 }
 
 type authtableConfig struct {
+	FormatVersion uint8
 	Name string
 	Schema []schema.SchemaConfigItem
-	FileOn uint16
+	FileOn uint32
 	DataOnDrive bool
 	MemOnly bool
-	PartitionMax uint16
+	Engine string
+	Encoding string
+	PartitionMax uint32
 	EncryptCost int
 	MaxEntries uint64
+	SyncPolicy uint8
+	CompactRatio float64
 	MinPass uint8
 	PassResetLen uint8
 	EmailItem string
@@ -145,8 +157,10 @@ type authtableConfig struct {
 //		]};
 //
 
-// New creates a new AuthTable with the provided name, schema, and other parameters.
-func New(name string, configFile *os.File, s schema.Schema, fileOn uint16, dataOnDrive bool, memOnly bool) (*AuthTable, helpers.Error) {
+// New creates a new AuthTable with the provided name, schema, and other parameters. engine is the name of the storage
+// engine for the AuthTable's data - an empty engine uses the file engine. encoding is how users are stored (see
+// helpers.EncodingJSON and helpers.EncodingBinary) - an empty encoding uses JSON.
+func New(name string, configFile *os.File, s schema.Schema, fileOn uint32, dataOnDrive bool, memOnly bool, engine string, encoding string) (*AuthTable, helpers.Error) {
 	if len(name) == 0 {
 		return nil, helpers.NewError(helpers.ErrorTableNameRequired, name)
 	} else if Get(name) != nil {
@@ -158,14 +172,24 @@ func New(name string, configFile *os.File, s schema.Schema, fileOn uint16, dataO
 	if memOnly {
 		dataOnDrive = false
 	}
-	namePre := dataFolderPrefix + name
+	// Check encoding
+	if encoding == "" {
+		encoding = helpers.EncodingJSON
+	} else if encoding != helpers.EncodingJSON && encoding != helpers.EncodingBinary {
+		return nil, helpers.NewError(helpers.ErrorInvalidEncoding, encoding)
+	}
+	namePre := helpers.DataPath(dataFolderPrefix + name)
+	// Get storage engine
+	se, seErr := storage.NewEngine(engine, namePre)
+	if seErr != 0 {
+		return nil, helpers.NewError(seErr, engine)
+	}
 	// Restoring if configFile is not nil
 	if configFile == nil {
 		var err error
-		// Make table storage folder
-		err = storage.MakeDir(namePre)
-		if err != nil {
-			return nil, helpers.NewError(helpers.ErrorCreatingFolder, namePre + helpers.FileTypeConfig + ": " + err.Error())
+		// Make table storage
+		if cErr := se.Create(); cErr != 0 {
+			return nil, helpers.NewError(cErr, namePre)
 		}
 		// Create/open config file
 		configFile, err = os.OpenFile(namePre + helpers.FileTypeConfig, os.O_RDWR|os.O_CREATE, 0755)
@@ -174,14 +198,19 @@ func New(name string, configFile *os.File, s schema.Schema, fileOn uint16, dataO
 		}
 		// Write config file
 		if wErr := writeConfigFile(configFile, authtableConfig{
+			FormatVersion: helpers.FormatVersion,
 			Name: name,
 			Schema: s.MakeConfig(),
 			FileOn: fileOn,
 			DataOnDrive: dataOnDrive,
 			MemOnly: memOnly,
+			Engine: se.Name(),
+			Encoding: encoding,
 			PartitionMax: helpers.DefaultPartitionMax,
 			EncryptCost: helpers.DefaultEncryptCost,
 			MaxEntries: helpers.DefaultMaxEntries,
+			SyncPolicy: helpers.DefaultSyncPolicy,
+			CompactRatio: helpers.DefaultCompactRatio,
 			MinPass: defaultMinPassword,
 			PassResetLen: defaultPassResetLen,
 			EmailItem: "",
@@ -199,17 +228,23 @@ func New(name string, configFile *os.File, s schema.Schema, fileOn uint16, dataO
 		dataOnDrive:   dataOnDrive,
 		schema:        s,
 		configFile:    configFile,
+		engine:        se,
+		encoding:      encoding,
 		entries:       make(map[string]*authTableEntry),
 		altLogins:     make(map[string]*authTableEntry),
 		vCodes:        make(map[string]string),
 		uniqueVals:    make(map[string]map[interface{}]bool),
 		fileOn:        fileOn,
 	}
+	t.compactor = storage.NewCompactor(se, "Auth '"+name+"'", &t.pMux, t.moveEntries)
 	// Set defaults
 	t.partitionMax.Store(helpers.DefaultPartitionMax)
 	t.maxEntries.Store(helpers.DefaultMaxEntries)
 	t.minPassword.Store(defaultMinPassword)
 	t.encryptCost.Store(helpers.DefaultEncryptCost)
+	t.syncPolicy.Store(helpers.DefaultSyncPolicy)
+	se.SetSyncPolicy(helpers.DefaultSyncPolicy)
+	t.compactRatio.Store(helpers.DefaultCompactRatio)
 	t.passResetLen.Store(defaultPassResetLen)
 	t.emailItem.Store("")
 	t.verifyItem.Store("")
@@ -228,14 +263,19 @@ func (t *AuthTable) Close(save bool) {
 		fileOn := t.fileOn
 		t.eMux.Unlock()
 		writeConfigFile(t.configFile, authtableConfig{
+			FormatVersion: helpers.FormatVersion,
 			Name: t.name,
 			Schema: t.schema.MakeConfig(),
 			FileOn: fileOn,
 			DataOnDrive: t.dataOnDrive,
 			MemOnly: t.memOnly,
-			PartitionMax: t.partitionMax.Load().(uint16),
+			Engine: t.engine.Name(),
+			Encoding: t.encoding,
+			PartitionMax: t.partitionMax.Load().(uint32),
 			EncryptCost: t.encryptCost.Load().(int),
 			MaxEntries: t.maxEntries.Load().(uint64),
+			SyncPolicy: t.syncPolicy.Load().(uint8),
+			CompactRatio: t.compactRatio.Load().(float64),
 			MinPass: t.minPassword.Load().(uint8),
 			PassResetLen: t.passResetLen.Load().(uint8),
 			EmailItem: t.emailItem.Load().(string),
@@ -253,15 +293,15 @@ func (t *AuthTable) Close(save bool) {
 // Delete deletes the AuthTable from memory and disk
 func (t *AuthTable) Delete() helpers.Error {
 	t.Close(false)
-	// Delete data directory
-	if err := os.RemoveAll(dataFolderPrefix + t.name); err != nil {
-		return helpers.NewError(helpers.ErrorFileDelete, "Data directory")
+	// Delete data
+	if err := t.engine.Delete(); err != 0 {
+		return helpers.NewError(err, "Data")
 	}
 	// Delete config file
-	if err := os.Remove(dataFolderPrefix + t.name + helpers.FileTypeConfig); err != nil {
+	if err := os.Remove(helpers.DataPath(dataFolderPrefix + t.name) + helpers.FileTypeConfig); err != nil {
 		return helpers.NewError(helpers.ErrorFileDelete, "Config file")
 	}
-	return 0
+	return helpers.Error{}
 }
 
 // Get retrieves a AuthTable by name
@@ -314,6 +354,14 @@ func (t *AuthTable) EncryptCost() int {
 	return t.encryptCost.Load().(int)
 }
 
+func (t *AuthTable) SyncPolicy() uint8 {
+	return t.syncPolicy.Load().(uint8)
+}
+
+func (t *AuthTable) CompactRatio() float64 {
+	return t.compactRatio.Load().(float64)
+}
+
 func (t *AuthTable) AltLoginItem() string {
 	return t.altLoginItem.Load().(string)
 }
@@ -475,7 +523,7 @@ func (t *AuthTable) SetEmailSettings(settings EmailSettings) int {
 	return 0
 }
 
-func (t *AuthTable) SetPartitionMax(max uint16) int {
+func (t *AuthTable) SetPartitionMax(max uint32) int {
 	if max < helpers.PartitionMin {
 		max = helpers.DefaultPartitionMax
 	}
@@ -491,16 +539,54 @@ func (t *AuthTable) SetPartitionMax(max uint16) int {
 	return 0
 }
 
-func (t *AuthTable) makeDefaultConfig(fileOn uint16) authtableConfig {
+func (t *AuthTable) SetSyncPolicy(policy uint8) int {
+	if policy > helpers.SyncPolicyAlways {
+		policy = helpers.DefaultSyncPolicy
+	}
+	t.eMux.Lock()
+	fileOn := t.fileOn
+	t.eMux.Unlock()
+	conf := t.makeDefaultConfig(fileOn)
+	conf.SyncPolicy = policy
+	if err := writeConfigFile(t.configFile, conf); err != 0 {
+		return err
+	}
+	t.syncPolicy.Store(policy)
+	t.engine.SetSyncPolicy(policy)
+	return 0
+}
+
+func (t *AuthTable) SetCompactRatio(ratio float64) int {
+	if ratio < 0 || ratio > 1 {
+		ratio = helpers.DefaultCompactRatio
+	}
+	t.eMux.Lock()
+	fileOn := t.fileOn
+	t.eMux.Unlock()
+	conf := t.makeDefaultConfig(fileOn)
+	conf.CompactRatio = ratio
+	if err := writeConfigFile(t.configFile, conf); err != 0 {
+		return err
+	}
+	t.compactRatio.Store(ratio)
+	return 0
+}
+
+func (t *AuthTable) makeDefaultConfig(fileOn uint32) authtableConfig {
 	return authtableConfig{
+		FormatVersion: helpers.FormatVersion,
 		Name: t.name,
 		Schema: t.schema.MakeConfig(),
 		FileOn: fileOn,
 		DataOnDrive: t.dataOnDrive,
 		MemOnly: t.memOnly,
-		PartitionMax: t.partitionMax.Load().(uint16),
+		Engine: t.engine.Name(),
+		Encoding: t.encoding,
+		PartitionMax: t.partitionMax.Load().(uint32),
 		EncryptCost: t.encryptCost.Load().(int),
 		MaxEntries: t.maxEntries.Load().(uint64),
+		SyncPolicy: t.syncPolicy.Load().(uint8),
+		CompactRatio: t.compactRatio.Load().(float64),
 		MinPass: t.minPassword.Load().(uint8),
 		PassResetLen: t.passResetLen.Load().(uint8),
 		EmailItem: t.emailItem.Load().(string),
@@ -532,7 +618,7 @@ func writeConfigFile(f *os.File, c authtableConfig) int {
 // Restore restores an AuthTable by name; requires a valid config file and data folder
 func Restore(name string) (*AuthTable, helpers.Error) {
 	fmt.Printf("Restoring Auth '%v'...\n", name)
-	namePre := dataFolderPrefix + name
+	namePre := helpers.DataPath(dataFolderPrefix + name)
 	// Open the File
 	f, err := os.OpenFile(namePre + helpers.FileTypeConfig, os.O_RDWR, 0755)
 	if err != nil {
@@ -552,13 +638,17 @@ func Restore(name string) (*AuthTable, helpers.Error) {
 		return nil, helpers.NewError(helpers.ErrorFileRead, "Config data is corrupt for Auth '" + name + "'")
 	}
 	// Make confStruct from json bytes
-	var confStruct authtableConfig
+	confStruct := authtableConfig{SyncPolicy: helpers.DefaultSyncPolicy, CompactRatio: helpers.DefaultCompactRatio}
 	mErr := json.Unmarshal(bytes, &confStruct)
 	if mErr != nil {
 		f.Close()
 		return nil, helpers.NewError(helpers.ErrorJsonDecoding,
 			"Config contains JSON syntax errors for Auth '" + name + "': " + mErr.Error())
 	}
+	if confStruct.FormatVersion > helpers.FormatVersion {
+		f.Close()
+		return nil, helpers.NewError(helpers.ErrorFormatVersion, "Auth '" + name + "' was made by a newer version (format version " + strconv.Itoa(int(confStruct.FormatVersion)) + ")")
+	}
 	// Make schema with the schemaList
 	s, schemaErr := schema.Restore(confStruct.Schema)
 	if schemaErr.ID != 0 {
@@ -566,7 +656,7 @@ func Restore(name string) (*AuthTable, helpers.Error) {
 		schemaErr.From = "(Auth '" + name + "') " + schemaErr.From
 		return nil, schemaErr
 	}
-	at, tErr := New(name, f, s, confStruct.FileOn, confStruct.DataOnDrive, confStruct.MemOnly)
+	at, tErr := New(name, f, s, confStruct.FileOn, confStruct.DataOnDrive, confStruct.MemOnly, confStruct.Engine, confStruct.Encoding)
 	if tErr.ID != 0 {
 		f.Close()
 		return nil, tErr
@@ -583,6 +673,13 @@ func Restore(name string) (*AuthTable, helpers.Error) {
 	if confStruct.PartitionMax != helpers.DefaultPartitionMax {
 		at.partitionMax.Store(confStruct.PartitionMax)
 	}
+	if confStruct.SyncPolicy != helpers.DefaultSyncPolicy {
+		at.syncPolicy.Store(confStruct.SyncPolicy)
+		at.engine.SetSyncPolicy(confStruct.SyncPolicy)
+	}
+	if confStruct.CompactRatio != helpers.DefaultCompactRatio {
+		at.compactRatio.Store(confStruct.CompactRatio)
+	}
 	if confStruct.MinPass != defaultMinPassword {
 		at.minPassword.Store(confStruct.MinPass)
 	}
@@ -598,57 +695,43 @@ func Restore(name string) (*AuthTable, helpers.Error) {
 	if confStruct.AltLogin != "" {
 		at.altLoginItem.Store(confStruct.AltLogin)
 	}
-	// Open data folder
-	df, err := os.Open(namePre)
-	if err != nil {
+	// Get data partitions
+	partitions, pErr := at.engine.Partitions()
+	if pErr != 0 {
 		at.eMux.Unlock()
 		at.uMux.Unlock()
-		df.Close()
 		at.Close(false)
-		return nil, helpers.NewError(helpers.ErrorFileOpen, "Missing data folder for Auth '" + name + "'")
-	}
-	files, err := df.Readdir(-1)
-	df.Close()
-	if err != nil {
-		at.eMux.Unlock()
-		at.uMux.Unlock()
-		at.Close(false)
-		return nil, helpers.NewError(helpers.ErrorFileRead, "Error reading files in data folder for Auth '" + name + "'")
+		return nil, helpers.NewError(pErr, "Missing data for Auth '" + name + "'")
 	}
 	fmt.Printf("Loading Auth data for '%v'...\n", name)
 	// Make progress bar
-	pBar := progressbar.New(len(files))
-	// Go through files
-	for _, fileStats := range files {
-		// Get file number
-		fileNameSplit := strings.Split(fileStats.Name(), ".")
-		fileNum, fnErr := strconv.Atoi(fileNameSplit[0])
-		if fnErr != nil || len(fileNameSplit) < 2 || "."+fileNameSplit[1] != helpers.FileTypeStorage {
-			// Not a storage file...
+	pBar := progressbar.New(len(partitions))
+	// Go through partitions
+	for _, fileNum := range partitions {
+		lines, err := at.engine.Lines(fileNum)
+		if err != 0 {
+			fmt.Printf("Error: Auth '%v':: Data partition %v is corrupt!\n", name, fileNum)
 			pBar.Add(1)
 			continue
 		}
-		var of *storage.OpenFile
-		var err int
-		if of, err = storage.GetOpenFile(namePre + "/" + fileStats.Name()); err != 0 {
-			fmt.Printf("Error: Auth '%v':: Data file '%v' is corrupt!\n", name, fileStats.Name())
-			pBar.Add(1)
-			continue
-		}
-		for i := 0; i < of.Lines(); i++ {
+		for i := 0; i < lines; i++ {
 			// Get line bytes
 			var lb []byte
-			if lb, err = of.Read(uint16(i+1)); err != 0 {
-				fmt.Printf("Error: Auth '%v':: Could not read line %v of '%v'!\n", name, i + 1, fileStats.Name())
+			if lb, err = at.engine.Read(fileNum, uint32(i+1)); err != 0 {
+				fmt.Printf("Error: Auth '%v':: Could not read line %v of partition %v!\n", name, i + 1, fileNum)
+				continue
+			} else if len(lb) == 0 {
+				// Deleted user
+				at.compactor.AddDead(fileNum, 1)
 				continue
 			}
-			eKey, ePass, eData := restoreDataLine(lb)
-			if eData == nil {
-				fmt.Printf("Error: Auth '%v':: Incorrect JSON format on line %v of '%v'!\n", name, i + 1, fileStats.Name())
+			eKey, ePass, eData, dErr := at.readEntryBytes(lb)
+			if dErr != 0 {
+				fmt.Printf("Error: Auth '%v':: Incorrect %v format on line %v of partition %v!\n", name, at.encoding, i + 1, fileNum)
 				continue
 			}
-			if err = at.restoreUser(eKey, []byte(ePass), eData, uint16(fileNum), uint16(i+1)); err != 0 {
-				fmt.Printf("Error: Auth '%v':: Line %v of '%v' error code %v\n", name, i + 1, fileStats.Name(), err)
+			if err = at.restoreUser(eKey, []byte(ePass), eData, fileNum, uint32(i+1)); err != 0 {
+				fmt.Printf("Error: Auth '%v':: Line %v of partition %v error code %v\n", name, i + 1, fileNum, err)
 				continue
 			}
 		}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"github.com/hewiefreeman/GopherDB/helpers"
 	"github.com/hewiefreeman/GopherDB/authtable"
+	"github.com/hewiefreeman/GopherDB/schema"
 	"github.com/hewiefreeman/GopherDB/storage"
 	"strconv"
 	"testing"
@@ -13,7 +14,7 @@ import (
 const (
 	// Test settings
 	tableName           string = "test"
-	tablePartitionMax   uint16 = 250
+	tablePartitionMax   uint32 = 250
 	tableMaxEntries     uint64 = 1000000
 	tableEncryptionCost int    = 4
 	tableMinPassLen     uint8  = 8
@@ -36,7 +37,7 @@ var (
 
 func TestChangeStorageSettings(t *testing.T) {
 	// Initialize storage engine
-	storage.Init()
+	storage.Init("")
 
 	// Set fileOpenTime to 3 seconds (needs 3 sec or lower for testing file closing at end)
 	storage.SetFileOpenTime(3 * time.Second)
@@ -702,6 +703,45 @@ func TestSortArray(t *testing.T) {
 	}
 }*/
 
+func TestCompact(t *testing.T) {
+	s, sErr := schema.New(map[string]interface{}{
+		"mmr": []interface{}{"Uint16", 0.0, 0.0, 0.0, false, false},
+	}, false)
+	if sErr.ID != 0 {
+		t.Errorf("TestCompact error making schema: %v", sErr)
+		return
+	}
+	// Remove an AuthTable left behind by a failed test
+	if at, err := authtable.Restore(tableName + "-compact"); err.ID == 0 {
+		at.Delete()
+	}
+	at, aErr := authtable.New(tableName+"-compact", nil, s, 0, true, false, "", "")
+	if aErr.ID != 0 {
+		t.Errorf("TestCompact error making AuthTable: %v", aErr)
+		return
+	}
+	defer at.Delete()
+	at.SetCompactRatio(0)
+	for i := 0; i < 4; i++ {
+		at.NewUser("user"+strconv.Itoa(i), "password", map[string]interface{}{"mmr": i})
+	}
+	at.DeleteUser("user0", "password")
+	at.DeleteUser("user2", "password")
+	if err := at.Compact(); err != 0 {
+		t.Errorf("TestCompact error: %v", err)
+		return
+	}
+	for _, i := range []int{1, 3} {
+		data, err := at.GetUser("user"+strconv.Itoa(i), "password", map[string]interface{}{"mmr": nil})
+		if err.ID != 0 || data["mmr"] != uint16(i) {
+			t.Errorf("TestCompact expected mmr %v for user%v, but got: %v %v", i, i, data, err)
+		}
+	}
+	if f, err := storage.GetOpenFile("Auth-" + tableName + "-compact/0" + helpers.FileTypeStorage); err != 0 || f.Lines() != 2 {
+		t.Errorf("TestCompact expected 2 lines after compacting")
+	}
+}
+
 // Must be last test!!
 func TestStorageShutdown(t *testing.T) {
 	storage.ShutDown()
@@ -0,0 +1,44 @@
+/*
+Copyright 2020 Dominique Debergue
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at:
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+either express or implied. See the License for the specific
+language governing permissions and limitations under the License.
+*/
+
+package authtable
+
+// Compact rewrites every data file of the AuthTable without the lines of deleted users.
+func (t *AuthTable) Compact() int {
+	if t.memOnly {
+		return 0
+	}
+	return t.compactor.Compact()
+}
+
+// Counts a deleted line in a data file, and compacts the file in the background when it's ratio of deleted lines
+// reaches the compactRatio.
+func (t *AuthTable) addDeadLine(fileNum uint32) {
+	t.compactor.AddDeadLine(fileNum, t.compactRatio.Load().(float64))
+}
+
+// Moves the users of a compacted data file to their new lines - called by the compactor with pMux write locked
+func (t *AuthTable) moveEntries(fileNum uint32, moved map[uint32]uint32) {
+	t.eMux.Lock()
+	for _, e := range t.entries {
+		if e.persistFile == fileNum {
+			if line, ok := moved[e.persistIndex]; ok {
+				e.persistIndex = line
+			}
+		}
+	}
+	t.eMux.Unlock()
+}
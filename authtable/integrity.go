@@ -0,0 +1,57 @@
+/*
+Copyright 2020 Dominique Debergue
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at:
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+either express or implied. See the License for the specific
+language governing permissions and limitations under the License.
+*/
+
+package authtable
+
+import (
+	"github.com/hewiefreeman/GopherDB/helpers"
+	"strconv"
+)
+
+// CheckIntegrity reads every line of the AuthTable's data, and reports the lines that can't be read, don't
+// match their checksum, or aren't a valid user.
+func (t *AuthTable) CheckIntegrity() []helpers.Error {
+	errs := []helpers.Error{}
+	if t.memOnly {
+		return errs
+	}
+	t.pMux.RLock()
+	defer t.pMux.RUnlock()
+	partitions, err := t.engine.Partitions()
+	if err != 0 {
+		return append(errs, helpers.NewError(err, "Auth '" + t.name + "'"))
+	}
+	for _, i := range partitions {
+		lines, err := t.engine.Lines(i)
+		if err != 0 {
+			errs = append(errs, helpers.NewError(err, "Auth '" + t.name + "' partition " + strconv.Itoa(int(i))))
+			continue
+		}
+		for l := 1; l <= lines; l++ {
+			from := "Auth '" + t.name + "' partition " + strconv.Itoa(int(i)) + " line " + strconv.Itoa(l)
+			b, err := t.engine.Read(i, uint32(l))
+			if err != 0 {
+				errs = append(errs, helpers.NewError(err, from))
+			} else if len(b) == 0 {
+				// Deleted user
+				continue
+			} else if _, _, _, err := t.readEntryBytes(b); err != 0 {
+				errs = append(errs, helpers.NewError(err, from))
+			}
+		}
+	}
+	return errs
+}
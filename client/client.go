@@ -0,0 +1,155 @@
+/*
+client package Copyright 2020 Dominique Debergue
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at:
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+either express or implied. See the License for the specific
+language governing permissions and limitations under the License.
+*/
+
+// Package client sends queries to a GopherDB server and decodes the responses.
+package client
+
+import (
+	"bytes"
+	"context"
+	"github.com/hewiefreeman/GopherDB/helpers"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// Default maximum pooled connections to the server
+	defaultMaxConns int = 16
+
+	// Maximum size of a server response
+	maxResponseSize int64 = 1 << 26
+)
+
+// Client sends queries to a GopherDB server over a pool of connections. A Client is safe for concurrent use.
+type Client struct {
+	url       string
+	name      string
+	pass      string
+	transport *http.Transport
+	http      *http.Client
+}
+
+type response struct {
+	R interface{}
+	E helpers.Error
+}
+
+// New creates a Client for the server at address (eg: "localhost:8082"). The name and pass are the credentials
+// to authenticate with - use an empty name for the master password. maxConns is the maximum number of connections
+// kept open to the server - a value of 0 uses the default of 16.
+func New(address string, name string, pass string, maxConns int) *Client {
+	if maxConns <= 0 {
+		maxConns = defaultMaxConns
+	}
+	if !strings.HasPrefix(address, "http://") && !strings.HasPrefix(address, "https://") {
+		address = "http://" + address
+	}
+	if !strings.HasSuffix(address, "/") {
+		address += "/"
+	}
+	t := &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		MaxIdleConns:        maxConns,
+		MaxIdleConnsPerHost: maxConns,
+		MaxConnsPerHost:     maxConns,
+		IdleConnTimeout:     90 * time.Second,
+	}
+	return &Client{
+		url:       address,
+		name:      name,
+		pass:      pass,
+		transport: t,
+		http:      &http.Client{Transport: t},
+	}
+}
+
+// Close closes the Client's idle connections. The Client can still be used after it's closed.
+func (c *Client) Close() {
+	c.transport.CloseIdleConnections()
+}
+
+// Query sends a query to the server and returns the result. The query can be made with one of the query builders
+// (GetQuery, InsertQuery, etc.), or by hand. Cancelling ctx cancels the query.
+func (c *Client) Query(ctx context.Context, query []interface{}) (interface{}, helpers.Error) {
+	jBytes, jErr := helpers.Fjson.Marshal(query)
+	if jErr != nil {
+		return nil, helpers.NewError(helpers.ErrorJsonEncoding, jErr.Error())
+	}
+	req, rErr := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(jBytes))
+	if rErr != nil {
+		return nil, helpers.NewError(helpers.ErrorClientConnection, rErr.Error())
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth(c.name, c.pass)
+	res, hErr := c.http.Do(req)
+	if hErr != nil {
+		return nil, helpers.NewError(helpers.ErrorClientConnection, hErr.Error())
+	}
+	defer res.Body.Close()
+	body, bErr := io.ReadAll(io.LimitReader(res.Body, maxResponseSize))
+	if bErr != nil {
+		return nil, helpers.NewError(helpers.ErrorClientConnection, bErr.Error())
+	}
+	var r response
+	if err := helpers.Fjson.Unmarshal(body, &r); err != nil {
+		return nil, helpers.NewError(helpers.ErrorClientResponse, res.Status)
+	}
+	return r.R, r.E
+}
+
+// Sends a query that returns an object
+func (c *Client) queryObject(ctx context.Context, query []interface{}) (map[string]interface{}, helpers.Error) {
+	r, err := c.Query(ctx, query)
+	if err.ID != 0 {
+		return nil, err
+	}
+	if r == nil {
+		return nil, helpers.Error{}
+	}
+	obj, ok := r.(map[string]interface{})
+	if !ok {
+		return nil, helpers.NewError(helpers.ErrorClientResponse, "")
+	}
+	return obj, helpers.Error{}
+}
+
+// Sends a query that returns a page of keys
+func (c *Client) queryKeys(ctx context.Context, query []interface{}) (KeysResult, helpers.Error) {
+	obj, err := c.queryObject(ctx, query)
+	if err.ID != 0 {
+		return KeysResult{}, err
+	}
+	list, ok := obj["Keys"].([]interface{})
+	if !ok {
+		return KeysResult{}, helpers.NewError(helpers.ErrorClientResponse, "")
+	}
+	res := KeysResult{Keys: make([]string, len(list))}
+	for i, key := range list {
+		if res.Keys[i], ok = key.(string); !ok {
+			return KeysResult{}, helpers.NewError(helpers.ErrorClientResponse, "")
+		}
+	}
+	res.Cursor, _ = obj["Cursor"].(string)
+	return res, helpers.Error{}
+}
+
+// Sends a query that returns no result
+func (c *Client) queryNoResult(ctx context.Context, query []interface{}) helpers.Error {
+	_, err := c.Query(ctx, query)
+	return err
+}
@@ -0,0 +1,177 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"github.com/hewiefreeman/GopherDB/client"
+	"github.com/hewiefreeman/GopherDB/helpers"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Test server that echoes the query back as the result, and returns error 6001 for the wrong password
+func newTestServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, pass, _ := r.BasicAuth(); pass != "pass" {
+			w.Write([]byte("{\"R\":null,\"E\":{\"ID\":6001,\"From\":\"\"}}"))
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		var query []interface{}
+		helpers.Fjson.Unmarshal(body, &query)
+		if len(query) > 0 && query[0] == "Slow" {
+			time.Sleep(time.Second)
+		}
+		res, _ := helpers.Fjson.Marshal(map[string]interface{}{"R": map[string]interface{}{"query": query}, "E": helpers.Error{}})
+		w.Write(res)
+	}))
+}
+
+func TestItemNames(t *testing.T) {
+	obj := client.Object(
+		client.Path("friends", 0, "status"),
+		client.Path("mmr").Add(10).Div(2),
+		client.Path("friends").AppendAt(3, []interface{}{"Bill"}),
+		client.Path("actions").Len().Gte(5),
+		client.Path("name").Set("Mary"),
+	)
+	if p, ok := obj["friends.0.status"].([]interface{}); !ok || len(p) != 0 {
+		t.Errorf("TestItemNames expected empty parameters for 'friends.0.status', but got: %v", obj["friends.0.status"])
+	}
+	if p, ok := obj["mmr.*add.*div"].([]interface{}); !ok || len(p) != 2 || p[0] != 10 || p[1] != 2 {
+		t.Errorf("TestItemNames expected [10 2] for 'mmr.*add.*div', but got: %v", obj["mmr.*add.*div"])
+	}
+	if p, ok := obj["friends.*append[3]"].([]interface{}); !ok || len(p) != 1 {
+		t.Errorf("TestItemNames expected one parameter for 'friends.*append[3]', but got: %v", obj["friends.*append[3]"])
+	}
+	if p, ok := obj["actions.*len.*gte"].([]interface{}); !ok || len(p) != 1 || p[0] != 5 {
+		t.Errorf("TestItemNames expected [5] for 'actions.*len.*gte', but got: %v", obj["actions.*len.*gte"])
+	}
+	if obj["name"] != "Mary" {
+		t.Errorf("TestItemNames expected 'Mary' for 'name', but got: %v", obj["name"])
+	}
+}
+
+func TestQueryBuilders(t *testing.T) {
+	q := client.GetQuery("users", "Maya", nil)
+	if len(q) != 3 || q[0] != "Get" || q[1] != "users" || q[2] != "Maya" {
+		t.Errorf("TestQueryBuilders got unexpected Get query: %v", q)
+	}
+	q = client.SelectQuery("users", client.Object(client.Path("mmr").Gt(1500)), nil, "mmr", true, 10, 0)
+	if len(q) != 8 || q[0] != "Select" || q[4] != "mmr" || q[5] != true || q[6] != 10 {
+		t.Errorf("TestQueryBuilders got unexpected Select query: %v", q)
+	}
+	q = client.InsertTTLQuery("sessions", "abc", nil, 90*time.Second)
+	if len(q) != 5 || q[0] != "Insert" || q[4] != 90.0 {
+		t.Errorf("TestQueryBuilders got unexpected Insert query with a TTL: %v", q)
+	}
+	q = client.RefreshQuery("sessions", "abc", 1500*time.Millisecond)
+	if len(q) != 4 || q[0] != "Refresh" || q[3] != 1.5 {
+		t.Errorf("TestQueryBuilders got unexpected Refresh query: %v", q)
+	}
+	q = client.ScanQuery("users", "player:123:", "", 50)
+	if len(q) != 5 || q[0] != "Scan" || q[2] != "player:123:*" || q[4] != 50 {
+		t.Errorf("TestQueryBuilders got unexpected Scan query: %v", q)
+	}
+	q = client.RangeQuery("users", "a", "n", "f", 10)
+	if len(q) != 6 || q[0] != "Range" || q[3] != "n" || q[4] != "f" {
+		t.Errorf("TestQueryBuilders got unexpected Range query: %v", q)
+	}
+	q = client.TransactionQuery(client.UpdateQuery("users", "Maya", nil), client.DeleteQuery("items", "sword"))
+	if tq, ok := q[1].([][]interface{}); len(q) != 2 || q[0] != "Transaction" || !ok || len(tq) != 2 || tq[1][0] != "Delete" {
+		t.Errorf("TestQueryBuilders got unexpected Transaction query: %v", q)
+	}
+	q = client.ChangePasswordQuery("auth", "Maya", "old", "new")
+	if len(q) != 5 || q[0] != "ChangePassword" || q[4] != "new" {
+		t.Errorf("TestQueryBuilders got unexpected ChangePassword query: %v", q)
+	}
+}
+
+func TestClientQuery(t *testing.T) {
+	s := newTestServer()
+	defer s.Close()
+	c := client.New(s.URL, "", "pass", 4)
+	defer c.Close()
+	res, err := c.Get(context.Background(), "users", "Maya", client.Object(client.Path("mmr")))
+	if err.ID != 0 {
+		t.Errorf("TestClientQuery error: %v", err)
+		return
+	}
+	if q, ok := res["query"].([]interface{}); !ok || len(q) != 4 || q[2] != "Maya" {
+		t.Errorf("TestClientQuery expected the query to be echoed, but got: %v", res)
+	}
+}
+
+func TestClientError(t *testing.T) {
+	s := newTestServer()
+	defer s.Close()
+	c := client.New(s.URL, "", "wrong", 0)
+	if err := c.Delete(context.Background(), "users", "Maya"); err.ID != helpers.ErrorNotAuthenticated {
+		t.Errorf("TestClientError expected error %v, but got: %v", helpers.ErrorNotAuthenticated, err)
+	}
+}
+
+func TestClientCancel(t *testing.T) {
+	s := newTestServer()
+	defer s.Close()
+	c := client.New(s.URL, "", "pass", 0)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := c.Query(ctx, []interface{}{"Slow", "users"}); err.ID != helpers.ErrorClientConnection {
+		t.Errorf("TestClientCancel expected error %v, but got: %v", helpers.ErrorClientConnection, err)
+	}
+}
+
+func TestStream(t *testing.T) {
+	// Test stream server that accepts any password, then answers every two queries in reverse order
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Errorf("TestStream error: %v", err)
+		return
+	}
+	defer l.Close()
+	go func() {
+		c, aErr := l.Accept()
+		if aErr != nil {
+			return
+		}
+		defer c.Close()
+		s := bufio.NewScanner(c)
+		s.Scan()
+		c.Write([]byte("{\"ID\":0,\"R\":null,\"E\":{\"ID\":0,\"From\":\"\"}}\n"))
+		var pending [][]byte
+		for s.Scan() {
+			var q map[string]interface{}
+			helpers.Fjson.Unmarshal(s.Bytes(), &q)
+			res, _ := helpers.Fjson.Marshal(map[string]interface{}{"ID": q["ID"], "R": q["Q"], "E": helpers.Error{}})
+			pending = append(pending, append(res, '\n'))
+			if len(pending) == 2 {
+				c.Write(pending[1])
+				c.Write(pending[0])
+				pending = nil
+			}
+		}
+	}()
+	s, dErr := client.Dial(context.Background(), l.Addr().String(), "", "pass")
+	if dErr.ID != 0 {
+		t.Errorf("TestStream error: %v", dErr)
+		return
+	}
+	defer s.Close()
+	results := make(chan string, 2)
+	for _, key := range []string{"Maya", "Bill"} {
+		go func(key string) {
+			r, qErr := s.Query(context.Background(), client.DeleteQuery("users", key))
+			if q, ok := r.([]interface{}); qErr.ID != 0 || !ok || len(q) != 3 || q[2] != key {
+				t.Errorf("TestStream expected the query for '%v' to be echoed, but got: %v %v", key, r, qErr)
+			}
+			results <- key
+		}(key)
+	}
+	<-results
+	<-results
+}
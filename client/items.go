@@ -0,0 +1,164 @@
+package client
+
+import (
+	"github.com/hewiefreeman/GopherDB/schema"
+	"strconv"
+	"strings"
+)
+
+// Item builds the name and method parameters of a query object item. For example:
+//
+//     client.Path("friends", 0, "status")            // "friends.0.status": []
+//     client.Path("mmr").Add(10).Div(2)               // "mmr.*add.*div": [10, 2]
+//     client.Path("friends").AppendAt(3, newFriends)  // "friends.*append[3]": [newFriends]
+//     client.Path("mmr").Set(1500)                    // "mmr": 1500
+//
+// Use Object to make a query object from Items.
+type Item struct {
+	name   string
+	params []interface{}
+	value  interface{}
+	set    bool
+}
+
+// Path makes an Item for a (nested) item name. Each part can be a string item or Map key, or an int Array index.
+func Path(parts ...interface{}) *Item {
+	names := make([]string, len(parts))
+	for i, p := range parts {
+		switch v := p.(type) {
+		case string:
+			names[i] = v
+		case int:
+			names[i] = strconv.Itoa(v)
+		default:
+			names[i] = ""
+		}
+	}
+	return &Item{name: strings.Join(names, "."), params: []interface{}{}}
+}
+
+// Name gets the full item name, including methods.
+func (i *Item) Name() string {
+	return i.name
+}
+
+// Set makes the Item's value a plain value, for Insert and Update queries. Methods added to the Item are ignored.
+func (i *Item) Set(value interface{}) *Item {
+	i.value = value
+	i.set = true
+	return i
+}
+
+// Method adds a method and it's parameters to the Item. Prefer the named methods (Add, Append, etc.) where possible.
+func (i *Item) Method(method string, params ...interface{}) *Item {
+	i.name += "." + method
+	i.params = append(i.params, params...)
+	return i
+}
+
+// Object makes a query object from Items.
+func Object(items ...*Item) map[string]interface{} {
+	obj := make(map[string]interface{}, len(items))
+	for _, item := range items {
+		if item.set {
+			obj[item.name] = item.value
+		} else {
+			obj[item.name] = item.params
+		}
+	}
+	return obj
+}
+
+// Numeric methods
+
+// Add adds n to a number, or appends a string.
+func (i *Item) Add(n interface{}) *Item { return i.Method(schema.MethodOperatorAdd, n) }
+
+// Sub subtracts n from a number.
+func (i *Item) Sub(n interface{}) *Item { return i.Method(schema.MethodOperatorSub, n) }
+
+// Mul multiplies a number by n.
+func (i *Item) Mul(n interface{}) *Item { return i.Method(schema.MethodOperatorMul, n) }
+
+// Div divides a number by n.
+func (i *Item) Div(n interface{}) *Item { return i.Method(schema.MethodOperatorDiv, n) }
+
+// Mod gets the remainder of a number divided by n.
+func (i *Item) Mod(n interface{}) *Item { return i.Method(schema.MethodOperatorMod, n) }
+
+// Comparison methods
+
+// Eq checks if a value equals v.
+func (i *Item) Eq(v interface{}) *Item { return i.Method(schema.MethodEquals, v) }
+
+// Gt checks if a number is greater than n.
+func (i *Item) Gt(n interface{}) *Item { return i.Method(schema.MethodGreater, n) }
+
+// Lt checks if a number is less than n.
+func (i *Item) Lt(n interface{}) *Item { return i.Method(schema.MethodLess, n) }
+
+// Gte checks if a number is greater than or equal to n.
+func (i *Item) Gte(n interface{}) *Item { return i.Method(schema.MethodGreaterOE, n) }
+
+// Lte checks if a number is less than or equal to n.
+func (i *Item) Lte(n interface{}) *Item { return i.Method(schema.MethodLessOE, n) }
+
+// Array, Map and String methods
+
+// Len gets the length of an Array, Map or String.
+func (i *Item) Len() *Item { return i.Method(schema.MethodLength) }
+
+// Contains checks if an Array or Map contains v, or a String contains the sub-string v.
+func (i *Item) Contains(v interface{}) *Item { return i.Method(schema.MethodContains, v) }
+
+// IndexOf gets the index of v in an Array or String.
+func (i *Item) IndexOf(v interface{}) *Item { return i.Method(schema.MethodIndexOf, v) }
+
+// KeyOf gets the key of v in a Map.
+func (i *Item) KeyOf(v interface{}) *Item { return i.Method(schema.MethodKeyOf, v) }
+
+// Last selects the last item of an Array.
+func (i *Item) Last() *Item { return i.Method(schema.MethodLast) }
+
+// SortAsc sorts an Array in ascending order. by is the Object item name to sort an Array of Objects by.
+func (i *Item) SortAsc(by string) *Item { return i.Method(schema.MethodSortAsc, by) }
+
+// SortDesc sorts an Array in descending order. by is the Object item name to sort an Array of Objects by.
+func (i *Item) SortDesc(by string) *Item { return i.Method(schema.MethodSortDesc, by) }
+
+// Append appends v to an Array (v is a list of items), Map (v is an object) or String.
+func (i *Item) Append(v interface{}) *Item { return i.Method(schema.MethodAppend, v) }
+
+// AppendAt inserts v into an Array (v is a list of items) or String at index.
+func (i *Item) AppendAt(index int, v interface{}) *Item {
+	return i.Method(schema.MethodAppendAt+strconv.Itoa(index)+schema.MethodAppendAtFin, v)
+}
+
+// Prepend prepends v to an Array (v is a list of items) or String.
+func (i *Item) Prepend(v interface{}) *Item { return i.Method(schema.MethodPrepend, v) }
+
+// Delete deletes the indexes of an Array, or keys of a Map, in v (a list).
+func (i *Item) Delete(v interface{}) *Item { return i.Method(schema.MethodDelete, v) }
+
+// Time methods
+
+// Since gets the time since a Time. Follow with Days, Hours, Minutes, Seconds or Milliseconds to pick the unit.
+func (i *Item) Since() *Item { return i.Method(schema.MethodSince) }
+
+// Until gets the time until a Time. Follow with Days, Hours, Minutes, Seconds or Milliseconds to pick the unit.
+func (i *Item) Until() *Item { return i.Method(schema.MethodUntil) }
+
+// Days formats the result of Since or Until in days.
+func (i *Item) Days() *Item { return i.Method(schema.MethodDay) }
+
+// Hours formats the result of Since or Until in hours.
+func (i *Item) Hours() *Item { return i.Method(schema.MethodHour) }
+
+// Minutes formats the result of Since or Until in minutes.
+func (i *Item) Minutes() *Item { return i.Method(schema.MethodMinute) }
+
+// Seconds formats the result of Since or Until in seconds.
+func (i *Item) Seconds() *Item { return i.Method(schema.MethodSecond) }
+
+// Milliseconds formats the result of Since or Until in milliseconds.
+func (i *Item) Milliseconds() *Item { return i.Method(schema.MethodMillisecond) }
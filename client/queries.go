@@ -0,0 +1,284 @@
+package client
+
+import (
+	"context"
+	"github.com/hewiefreeman/GopherDB/helpers"
+	"time"
+)
+
+// Query types
+const (
+	QueryTypeGet            = "Get"
+	QueryTypeSelect         = "Select"
+	QueryTypeScan           = "Scan"
+	QueryTypeRange          = "Range"
+	QueryTypeInsert         = "Insert"
+	QueryTypeUpdate         = "Update"
+	QueryTypeUpsert         = "Upsert"
+	QueryTypeDelete         = "Delete"
+	QueryTypeRefresh        = "Refresh"
+	QueryTypeTransaction    = "Transaction"
+	QueryTypeChangePassword = "ChangePassword"
+	QueryTypeResetPassword  = "ResetPassword"
+	QueryTypeNewTable       = "NewTable"
+	QueryTypeDeleteTable    = "DeleteTable"
+)
+
+// Table types
+const (
+	TableTypeKeystore  = "Keystore"
+	TableTypeAuthTable = "AuthTable"
+)
+
+// SelectResult is the key and items of an entry from a Select query.
+type SelectResult struct {
+	Key   string
+	Items map[string]interface{}
+}
+
+// KeysResult is a page of keys from a Scan or Range query. Cursor gets the next page - empty when there are no more
+// keys.
+type KeysResult struct {
+	Keys   []string
+	Cursor string
+}
+
+//////////////////////////////////////////////////////////////////////////////////////////////////////
+//   QUERY BUILDERS   ////////////////////////////////////////////////////////////////////////////////
+//////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// GetQuery makes a Keystore Get query. A nil obj gets the whole entry. Use Object to make obj from Items.
+func GetQuery(table string, key string, obj map[string]interface{}) []interface{} {
+	return withObject([]interface{}{QueryTypeGet, table, key}, obj)
+}
+
+// InsertQuery makes a Keystore Insert query.
+func InsertQuery(table string, key string, obj map[string]interface{}) []interface{} {
+	return withObject([]interface{}{QueryTypeInsert, table, key}, obj)
+}
+
+// UpdateQuery makes a Keystore Update query.
+func UpdateQuery(table string, key string, obj map[string]interface{}) []interface{} {
+	return withObject([]interface{}{QueryTypeUpdate, table, key}, obj)
+}
+
+// UpsertQuery makes a Keystore Upsert query.
+func UpsertQuery(table string, key string, obj map[string]interface{}) []interface{} {
+	return withObject([]interface{}{QueryTypeUpsert, table, key}, obj)
+}
+
+// InsertTTLQuery makes a Keystore Insert query for an entry that expires after ttl.
+func InsertTTLQuery(table string, key string, obj map[string]interface{}, ttl time.Duration) []interface{} {
+	return []interface{}{QueryTypeInsert, table, key, obj, ttl.Seconds()}
+}
+
+// UpsertTTLQuery makes a Keystore Upsert query that sets the entry to expire after ttl.
+func UpsertTTLQuery(table string, key string, obj map[string]interface{}, ttl time.Duration) []interface{} {
+	return []interface{}{QueryTypeUpsert, table, key, obj, ttl.Seconds()}
+}
+
+// RefreshQuery makes a Keystore Refresh query, which sets an entry to expire after ttl. A ttl of 0 makes the entry
+// never expire.
+func RefreshQuery(table string, key string, ttl time.Duration) []interface{} {
+	return []interface{}{QueryTypeRefresh, table, key, ttl.Seconds()}
+}
+
+// DeleteQuery makes a Keystore Delete query.
+func DeleteQuery(table string, key string) []interface{} {
+	return []interface{}{QueryTypeDelete, table, key}
+}
+
+// TransactionQuery makes a Transaction query, which runs Keystore Insert, Update and Delete queries all-or-nothing.
+func TransactionQuery(queries ...[]interface{}) []interface{} {
+	return []interface{}{QueryTypeTransaction, queries}
+}
+
+// SelectQuery makes a Keystore Select query, which gets the items of every entry that matches where, sorted by the
+// sortBy item and split into pages of limit entries. A nil where matches every entry, a nil obj gets whole entries,
+// an empty sortBy keeps the entries in order of their keys, and a limit of 0 gets every matching entry.
+func SelectQuery(table string, where map[string]interface{}, obj map[string]interface{}, sortBy string, desc bool, limit int, page int) []interface{} {
+	return []interface{}{QueryTypeSelect, table, where, obj, sortBy, desc, limit, page}
+}
+
+// ScanQuery makes a Keystore Scan query, which gets the keys that start with prefix in order, split into pages of
+// limit keys. cursor is the Cursor of the previous page - empty for the first page. A limit of 0 gets every key.
+func ScanQuery(table string, prefix string, cursor string, limit int) []interface{} {
+	return []interface{}{QueryTypeScan, table, prefix + "*", cursor, limit}
+}
+
+// RangeQuery makes a Keystore Range query, which gets the keys from start up to, but not including, end in order,
+// split into pages of limit keys. An empty end has no upper bound. cursor is the Cursor of the previous page - empty
+// for the first page. A limit of 0 gets every key.
+func RangeQuery(table string, start string, end string, cursor string, limit int) []interface{} {
+	return []interface{}{QueryTypeRange, table, start, end, cursor, limit}
+}
+
+// GetUserQuery makes an AuthTable Get query. A nil obj gets the whole entry.
+func GetUserQuery(table string, name string, pass string, obj map[string]interface{}) []interface{} {
+	return withObject([]interface{}{QueryTypeGet, table, name, pass}, obj)
+}
+
+// NewUserQuery makes an AuthTable Insert query.
+func NewUserQuery(table string, name string, pass string, obj map[string]interface{}) []interface{} {
+	return withObject([]interface{}{QueryTypeInsert, table, name, pass}, obj)
+}
+
+// UpdateUserQuery makes an AuthTable Update query.
+func UpdateUserQuery(table string, name string, pass string, obj map[string]interface{}) []interface{} {
+	return withObject([]interface{}{QueryTypeUpdate, table, name, pass}, obj)
+}
+
+// DeleteUserQuery makes an AuthTable Delete query.
+func DeleteUserQuery(table string, name string, pass string) []interface{} {
+	return []interface{}{QueryTypeDelete, table, name, pass}
+}
+
+// ChangePasswordQuery makes an AuthTable ChangePassword query.
+func ChangePasswordQuery(table string, name string, pass string, newPass string) []interface{} {
+	return []interface{}{QueryTypeChangePassword, table, name, pass, newPass}
+}
+
+// ResetPasswordQuery makes an AuthTable ResetPassword query.
+func ResetPasswordQuery(table string, name string) []interface{} {
+	return []interface{}{QueryTypeResetPassword, table, name}
+}
+
+// NewTableQuery makes a NewTable query. tableType is TableTypeKeystore or TableTypeAuthTable, and schema is
+// the table's schema in the same format as schema.New.
+func NewTableQuery(table string, tableType string, schema map[string]interface{}, dataOnDrive bool, memOnly bool) []interface{} {
+	return []interface{}{QueryTypeNewTable, table, tableType, schema, dataOnDrive, memOnly}
+}
+
+// DeleteTableQuery makes a DeleteTable query.
+func DeleteTableQuery(table string) []interface{} {
+	return []interface{}{QueryTypeDeleteTable, table}
+}
+
+// Appends an optional object to a query
+func withObject(query []interface{}, obj map[string]interface{}) []interface{} {
+	if obj != nil {
+		query = append(query, obj)
+	}
+	return query
+}
+
+//////////////////////////////////////////////////////////////////////////////////////////////////////
+//   CLIENT QUERIES   ////////////////////////////////////////////////////////////////////////////////
+//////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Get gets items from a Keystore entry. A nil obj gets the whole entry.
+func (c *Client) Get(ctx context.Context, table string, key string, obj map[string]interface{}) (map[string]interface{}, helpers.Error) {
+	return c.queryObject(ctx, GetQuery(table, key, obj))
+}
+
+// Insert inserts a new entry into a Keystore.
+func (c *Client) Insert(ctx context.Context, table string, key string, obj map[string]interface{}) helpers.Error {
+	return c.queryNoResult(ctx, InsertQuery(table, key, obj))
+}
+
+// Update updates items of a Keystore entry.
+func (c *Client) Update(ctx context.Context, table string, key string, obj map[string]interface{}) helpers.Error {
+	return c.queryNoResult(ctx, UpdateQuery(table, key, obj))
+}
+
+// Upsert inserts a Keystore entry, or updates it if the key is already in use.
+func (c *Client) Upsert(ctx context.Context, table string, key string, obj map[string]interface{}) helpers.Error {
+	return c.queryNoResult(ctx, UpsertQuery(table, key, obj))
+}
+
+// InsertTTL inserts a new Keystore entry that expires after ttl.
+func (c *Client) InsertTTL(ctx context.Context, table string, key string, obj map[string]interface{}, ttl time.Duration) helpers.Error {
+	return c.queryNoResult(ctx, InsertTTLQuery(table, key, obj, ttl))
+}
+
+// UpsertTTL inserts or updates a Keystore entry, and sets it to expire after ttl.
+func (c *Client) UpsertTTL(ctx context.Context, table string, key string, obj map[string]interface{}, ttl time.Duration) helpers.Error {
+	return c.queryNoResult(ctx, UpsertTTLQuery(table, key, obj, ttl))
+}
+
+// Refresh sets a Keystore entry to expire after ttl. A ttl of 0 makes the entry never expire.
+func (c *Client) Refresh(ctx context.Context, table string, key string, ttl time.Duration) helpers.Error {
+	return c.queryNoResult(ctx, RefreshQuery(table, key, ttl))
+}
+
+// Delete deletes a Keystore entry.
+func (c *Client) Delete(ctx context.Context, table string, key string) helpers.Error {
+	return c.queryNoResult(ctx, DeleteQuery(table, key))
+}
+
+// Transaction runs Keystore Insert, Update and Delete queries all-or-nothing. See TransactionQuery.
+func (c *Client) Transaction(ctx context.Context, queries ...[]interface{}) helpers.Error {
+	return c.queryNoResult(ctx, TransactionQuery(queries...))
+}
+
+// Select gets the items of every Keystore entry that matches where. See SelectQuery.
+func (c *Client) Select(ctx context.Context, table string, where map[string]interface{}, obj map[string]interface{}, sortBy string, desc bool, limit int, page int) ([]SelectResult, helpers.Error) {
+	r, err := c.Query(ctx, SelectQuery(table, where, obj, sortBy, desc, limit, page))
+	if err.ID != 0 {
+		return nil, err
+	}
+	list, ok := r.([]interface{})
+	if !ok {
+		return nil, helpers.NewError(helpers.ErrorClientResponse, "")
+	}
+	results := make([]SelectResult, len(list))
+	for i, ri := range list {
+		obj, ok := ri.(map[string]interface{})
+		if !ok {
+			return nil, helpers.NewError(helpers.ErrorClientResponse, "")
+		}
+		results[i].Key, _ = obj["Key"].(string)
+		results[i].Items, _ = obj["Items"].(map[string]interface{})
+	}
+	return results, helpers.Error{}
+}
+
+// Scan gets a page of the Keystore keys that start with prefix. See ScanQuery.
+func (c *Client) Scan(ctx context.Context, table string, prefix string, cursor string, limit int) (KeysResult, helpers.Error) {
+	return c.queryKeys(ctx, ScanQuery(table, prefix, cursor, limit))
+}
+
+// Range gets a page of the Keystore keys from start up to, but not including, end. See RangeQuery.
+func (c *Client) Range(ctx context.Context, table string, start string, end string, cursor string, limit int) (KeysResult, helpers.Error) {
+	return c.queryKeys(ctx, RangeQuery(table, start, end, cursor, limit))
+}
+
+// GetUser gets items from an AuthTable entry. A nil obj gets the whole entry.
+func (c *Client) GetUser(ctx context.Context, table string, name string, pass string, obj map[string]interface{}) (map[string]interface{}, helpers.Error) {
+	return c.queryObject(ctx, GetUserQuery(table, name, pass, obj))
+}
+
+// NewUser inserts a new user into an AuthTable.
+func (c *Client) NewUser(ctx context.Context, table string, name string, pass string, obj map[string]interface{}) helpers.Error {
+	return c.queryNoResult(ctx, NewUserQuery(table, name, pass, obj))
+}
+
+// UpdateUser updates items of an AuthTable entry.
+func (c *Client) UpdateUser(ctx context.Context, table string, name string, pass string, obj map[string]interface{}) helpers.Error {
+	return c.queryNoResult(ctx, UpdateUserQuery(table, name, pass, obj))
+}
+
+// DeleteUser deletes an AuthTable entry.
+func (c *Client) DeleteUser(ctx context.Context, table string, name string, pass string) helpers.Error {
+	return c.queryNoResult(ctx, DeleteUserQuery(table, name, pass))
+}
+
+// ChangePassword changes the password of an AuthTable entry.
+func (c *Client) ChangePassword(ctx context.Context, table string, name string, pass string, newPass string) helpers.Error {
+	return c.queryNoResult(ctx, ChangePasswordQuery(table, name, pass, newPass))
+}
+
+// ResetPassword resets the password of an AuthTable entry and emails the new password to the user.
+func (c *Client) ResetPassword(ctx context.Context, table string, name string) helpers.Error {
+	return c.queryNoResult(ctx, ResetPasswordQuery(table, name))
+}
+
+// NewTable creates a new table on the server.
+func (c *Client) NewTable(ctx context.Context, table string, tableType string, schema map[string]interface{}, dataOnDrive bool, memOnly bool) helpers.Error {
+	return c.queryNoResult(ctx, NewTableQuery(table, tableType, schema, dataOnDrive, memOnly))
+}
+
+// DeleteTable deletes a table from the server.
+func (c *Client) DeleteTable(ctx context.Context, table string) helpers.Error {
+	return c.queryNoResult(ctx, DeleteTableQuery(table))
+}
@@ -0,0 +1,156 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"github.com/hewiefreeman/GopherDB/helpers"
+	"net"
+	"sync"
+)
+
+const (
+	// Maximum size of a stream response
+	maxStreamResponseSize int = 1 << 26
+)
+
+// Stream is a long-lived TCP connection to a GopherDB server. Queries sent on a Stream are tagged with a request ID,
+// so any number of goroutines can send queries at once and each gets it's own response. A Stream is safe for
+// concurrent use.
+type Stream struct {
+	conn net.Conn
+
+	writeMux sync.Mutex
+
+	mux     sync.Mutex
+	lastID  uint64
+	waiting map[uint64]chan streamResponse
+	err     helpers.Error // Set when the connection closes
+	done    chan struct{}
+}
+
+type streamRequest struct {
+	ID uint64
+	Q  []interface{}
+}
+
+type streamResponse struct {
+	ID uint64
+	R  interface{}
+	E  helpers.Error
+}
+
+// Dial opens a Stream to the server's TCP stream address (eg: "localhost:8083") and authenticates it with name and
+// pass - use an empty name for the master password.
+func Dial(ctx context.Context, address string, name string, pass string) (*Stream, helpers.Error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, helpers.NewError(helpers.ErrorClientConnection, err.Error())
+	}
+	s := &Stream{conn: conn, waiting: make(map[uint64]chan streamResponse), done: make(chan struct{})}
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 4096), maxStreamResponseSize)
+
+	// Authenticate
+	if aErr := s.write(map[string]string{"Name": name, "Pass": pass}); aErr.ID != 0 {
+		conn.Close()
+		return nil, aErr
+	}
+	if !scanner.Scan() {
+		conn.Close()
+		return nil, helpers.NewError(helpers.ErrorClientConnection, "")
+	}
+	var res streamResponse
+	if err := helpers.Fjson.Unmarshal(scanner.Bytes(), &res); err != nil {
+		conn.Close()
+		return nil, helpers.NewError(helpers.ErrorClientResponse, "")
+	} else if res.E.ID != 0 {
+		conn.Close()
+		return nil, res.E
+	}
+
+	go s.readResponses(scanner)
+	return s, helpers.Error{}
+}
+
+// Query sends a query on the Stream and waits for it's response. Cancelling ctx stops waiting for the response, but
+// the server may still run the query.
+func (s *Stream) Query(ctx context.Context, query []interface{}) (interface{}, helpers.Error) {
+	ch := make(chan streamResponse, 1)
+	s.mux.Lock()
+	if s.err.ID != 0 {
+		s.mux.Unlock()
+		return nil, s.err
+	}
+	s.lastID++
+	id := s.lastID
+	s.waiting[id] = ch
+	s.mux.Unlock()
+
+	if err := s.write(streamRequest{ID: id, Q: query}); err.ID != 0 {
+		s.forget(id)
+		return nil, err
+	}
+	select {
+	case res := <-ch:
+		return res.R, res.E
+	case <-s.done:
+		s.forget(id)
+		s.mux.Lock()
+		defer s.mux.Unlock()
+		return nil, s.err
+	case <-ctx.Done():
+		s.forget(id)
+		return nil, helpers.NewError(helpers.ErrorClientConnection, ctx.Err().Error())
+	}
+}
+
+// Close closes the Stream. Queries waiting for a response get an ErrorClientConnection.
+func (s *Stream) Close() {
+	s.conn.Close()
+}
+
+// Writes a JSON message to the connection
+func (s *Stream) write(msg interface{}) helpers.Error {
+	jBytes, jErr := helpers.Fjson.Marshal(msg)
+	if jErr != nil {
+		return helpers.NewError(helpers.ErrorJsonEncoding, jErr.Error())
+	}
+	s.writeMux.Lock()
+	defer s.writeMux.Unlock()
+	if _, err := s.conn.Write(append(jBytes, '\n')); err != nil {
+		return helpers.NewError(helpers.ErrorClientConnection, err.Error())
+	}
+	return helpers.Error{}
+}
+
+// Stops waiting for a response
+func (s *Stream) forget(id uint64) {
+	s.mux.Lock()
+	delete(s.waiting, id)
+	s.mux.Unlock()
+}
+
+// Sends responses to the queries waiting for them until the connection closes
+func (s *Stream) readResponses(scanner *bufio.Scanner) {
+	for scanner.Scan() {
+		var res streamResponse
+		if err := helpers.Fjson.Unmarshal(scanner.Bytes(), &res); err != nil {
+			continue
+		}
+		s.mux.Lock()
+		if ch, ok := s.waiting[res.ID]; ok {
+			delete(s.waiting, res.ID)
+			ch <- res
+		}
+		s.mux.Unlock()
+	}
+	s.mux.Lock()
+	s.err = helpers.NewError(helpers.ErrorClientConnection, "stream closed")
+	if err := scanner.Err(); err != nil {
+		s.err.From = err.Error()
+	}
+	s.mux.Unlock()
+	close(s.done)
+	s.conn.Close()
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"github.com/hewiefreeman/GopherDB/authtable"
+	"github.com/hewiefreeman/GopherDB/helpers"
+	"github.com/hewiefreeman/GopherDB/keystore"
+	"github.com/hewiefreeman/GopherDB/leaderboard"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+)
+
+var (
+	confMux  sync.Mutex
+	confFile *os.File
+	conf     serverConfig
+)
+
+// serverConfig is the structure of the db.conf file
+type serverConfig struct {
+	MasterPass    string   `json:"masterPass"`
+	Replica       bool     `json:"replica"`
+	ReadOnly      bool     `json:"readOnly"`
+	DataDirectory string   `json:"dataDirectory"` // root folder of the tables and logs - empty is the working directory
+	Metrics       bool     `json:"metrics"`       // serves storage metrics for Prometheus at /metrics
+	Replicas      []string `json:"replicas"`
+	Routers       []string `json:"routers"`
+	Keystores     []string
+	AuthTables    []string
+	Leaderboards  []string
+	Credentials   []credential `json:"credentials"`
+	Limits        limitsConfig `json:"limits"`
+}
+
+// loadConfig opens (or creates) the server's db.conf file and applies it's settings. The tables listed in it are
+// restored with restoreTables once the storage engine is initialized in the config's data directory.
+func loadConfig() int {
+	confMux.Lock()
+	defer confMux.Unlock()
+	// Open the File
+	var err error
+	if confFile, err = os.OpenFile(configFile, os.O_RDWR|os.O_CREATE, 0755); err != nil {
+		return helpers.ErrorFileOpen
+	}
+	// Get file stats
+	fs, fsErr := confFile.Stat()
+	if fsErr != nil {
+		return helpers.ErrorFileOpen
+	}
+	// Get file bytes
+	bytes := make([]byte, fs.Size())
+	if _, rErr := confFile.ReadAt(bytes, 0); rErr != nil && rErr != io.EOF {
+		return helpers.ErrorFileRead
+	}
+	if len(bytes) == 0 {
+		// New config file
+		bytes = []byte(defaultConfigFile)
+	}
+	conf = serverConfig{Replicas: []string{}, Routers: []string{}, Keystores: []string{}, AuthTables: []string{}, Leaderboards: []string{}, Credentials: []credential{}}
+	if err = helpers.Fjson.Unmarshal(bytes, &conf); err != nil {
+		return helpers.ErrorJsonDecoding
+	}
+
+	// Encrypt new passwords
+	if eErr := encryptConfigPasswords(); eErr != 0 {
+		return eErr
+	}
+	resetAuthCache()
+
+	// Apply settings
+	applyLimits(conf.Limits)
+	statusMux.Lock()
+	masterPass = []byte(conf.MasterPass)
+	replica = conf.Replica
+	readOnly = conf.ReadOnly
+	statusMux.Unlock()
+	replicasMux.Lock()
+	replicas = append([]string{}, conf.Replicas...)
+	replicasMux.Unlock()
+	balancersMux.Lock()
+	balancers = append([]string{}, conf.Routers...)
+	balancersMux.Unlock()
+
+	return writeConfig()
+}
+
+// restoreTables restores all of the tables listed in the config file.
+func restoreTables() {
+	confMux.Lock()
+	defer confMux.Unlock()
+	if conf.MasterPass == "" && len(conf.Credentials) == 0 {
+		helpers.LogAndPrint("No masterPass or credentials are set in '"+configFile+"'. Connection authentication is disabled!", 5)
+	} else if conf.MasterPass == "" {
+		helpers.LogAndPrint("No masterPass is set in '"+configFile+"'. Master logins are disabled.", 4)
+	}
+	for _, name := range conf.Keystores {
+		if _, tErr := keystore.Restore(name); tErr.ID != 0 {
+			helpers.LogAndPrint("Failed to restore Keystore '"+name+"' with error code: "+strconv.Itoa(tErr.ID)+" "+tErr.From, 5)
+		}
+	}
+	for _, name := range conf.AuthTables {
+		if _, tErr := authtable.Restore(name); tErr.ID != 0 {
+			helpers.LogAndPrint("Failed to restore Auth '"+name+"' with error code: "+strconv.Itoa(tErr.ID)+" "+tErr.From, 5)
+		}
+	}
+	for _, name := range conf.Leaderboards {
+		if _, tErr := leaderboard.Restore(name); tErr.ID != 0 {
+			helpers.LogAndPrint("Failed to restore Leaderboard '"+name+"' with error code: "+strconv.Itoa(tErr.ID)+" "+tErr.From, 5)
+		}
+	}
+}
+
+// configAddTable adds a table's name to the list for it's table type and saves the config file.
+func configAddTable(tableType string, name string) int {
+	confMux.Lock()
+	defer confMux.Unlock()
+	list := configTableList(tableType)
+	if list == nil {
+		return helpers.ErrorUnexpected
+	}
+	for _, n := range *list {
+		if n == name {
+			return 0
+		}
+	}
+	*list = append(*list, name)
+	return writeConfig()
+}
+
+// configRemoveTable removes a table's name from the list for it's table type and saves the config file.
+func configRemoveTable(tableType string, name string) int {
+	confMux.Lock()
+	defer confMux.Unlock()
+	list := configTableList(tableType)
+	if list == nil {
+		return helpers.ErrorUnexpected
+	}
+	for i, n := range *list {
+		if n == name {
+			*list = append((*list)[:i], (*list)[i+1:]...)
+			return writeConfig()
+		}
+	}
+	return 0
+}
+
+// Gets the config's table list for a table type - must lock confMux before-hand.
+func configTableList(tableType string) *[]string {
+	switch tableType {
+	case tableTypeKeystore:
+		return &conf.Keystores
+	case tableTypeAuthTable:
+		return &conf.AuthTables
+	}
+	return nil
+}
+
+// Writes conf to confFile and truncates the file - must lock confMux before-hand.
+func writeConfig() int {
+	if confFile == nil {
+		return helpers.ErrorFileUpdate
+	}
+	jBytes, jErr := helpers.Fjson.MarshalIndent(conf, "", "   ")
+	if jErr != nil {
+		return helpers.ErrorJsonEncoding
+	}
+	if _, wErr := confFile.WriteAt(jBytes, 0); wErr != nil {
+		return helpers.ErrorFileUpdate
+	}
+	confFile.Truncate(int64(len(jBytes)))
+	return 0
+}
@@ -1,29 +1,555 @@
 package datelist
 
 import (
-	"time"
+	"encoding/json"
 	"github.com/hewiefreeman/GopherDB/helpers"
 	"github.com/hewiefreeman/GopherDB/schema"
+	"github.com/hewiefreeman/GopherDB/storage"
+	"strconv"
+	"time"
 )
 
+type jsonEntry struct {
+	I uint64        // Entry ID
+	T int64         // Time stamp
+	N bool          `json:",omitempty"` // T is in Unix Nano
+	D []interface{} // Entry data
+}
+
+// Result is an entry returned from a DateList Get query.
+type Result struct {
+	ID   uint64
+	Time time.Time
+	Data map[string]interface{}
+}
+
+func makeJsonBytes(id uint64, t time.Time, unixNano bool, data []interface{}, jBytes *[]byte) int {
+	stamp := t.Unix()
+	if unixNano {
+		stamp = t.UnixNano()
+	}
+	var jErr error
+	if *jBytes, jErr = helpers.Fjson.Marshal(jsonEntry{
+		I: id,
+		T: stamp,
+		N: unixNano,
+		D: data,
+	}); jErr != nil {
+		return helpers.ErrorJsonEncoding
+	}
+	return 0
+}
+
+// Example JSON for insert query:
+//
+//     ["Insert", "tableName", { *items that match schema* }]
+//
+
+// Insert creates a new DateListEntry time stamped with the current time.
+func (d *DateList) Insert(insertObj map[string]interface{}) (*DateListEntry, helpers.Error) {
+	// Create entry
+	e := DateListEntry{
+		data: make([]interface{}, len(d.schema), len(d.schema)),
+	}
+
+	uniqueVals := make(map[string]interface{})
+
+	// Fill entry data with insertObj - Loop through schema to also check for required items
+	for itemName, schemaItem := range d.schema {
+		// Item filter
+		err := schema.ItemFilter(insertObj[itemName], nil, &e.data[schemaItem.DataIndex()], nil, schemaItem, &uniqueVals, d.EncryptCost(), false, false)
+		if err != 0 {
+			return nil, helpers.NewError(err, itemName)
+		}
+	}
+
+	// Lock table, check for max entries
+	maxEntries := d.maxEntries.Load().(uint64)
+	d.eMux.Lock()
+	if maxEntries > 0 && d.entryCount >= maxEntries {
+		// Table is full
+		d.eMux.Unlock()
+		return nil, helpers.NewError(helpers.ErrorTableFull, "")
+	}
+	e.id = d.lastID + 1
+	e.iTime = d.timeStamp(time.Now())
+
+	// Make JSON []byte for entry
+	var jBytes []byte
+	if !d.memOnly {
+		if jErr := makeJsonBytes(e.id, e.iTime, d.unixNano.Load().(bool), e.data, &jBytes); jErr != 0 {
+			d.eMux.Unlock()
+			return nil, helpers.NewError(jErr, "")
+		}
+	}
+
+	d.uMux.Lock()
+	// Check unique values
+	for itemName, itemVal := range uniqueVals {
+		if d.uniqueVals[itemName] != nil && d.uniqueVals[itemName][itemVal] {
+			d.uMux.Unlock()
+			d.eMux.Unlock()
+			return nil, helpers.NewError(helpers.ErrorUniqueValueDuplicate, itemName)
+		}
+	}
+	// Append jBytes to fileOn and get the persistIndex
+	var lineOn uint32
+	if !d.memOnly {
+		var aErr int
+		lineOn, aErr = storage.Insert(helpers.DataPath(dataFolderPrefix+d.name)+"/"+strconv.Itoa(int(d.fileOn))+helpers.FileTypeStorage, jBytes)
+		if aErr != 0 {
+			d.uMux.Unlock()
+			d.eMux.Unlock()
+			return nil, helpers.NewError(aErr, "")
+		}
+	}
+
+	// Apply unique values
+	for itemName, itemVal := range uniqueVals {
+		if d.uniqueVals[itemName] == nil {
+			d.uniqueVals[itemName] = make(map[interface{}]bool)
+		}
+		d.uniqueVals[itemName][itemVal] = true
+	}
+	d.uMux.Unlock()
+
+	//
+	e.persistIndex = lineOn
+	e.persistFile = d.fileOn
+
+	// Increase fileOn when the index has reached or surpassed partitionMax
+	if !d.memOnly && e.persistIndex >= d.partitionMax.Load().(uint32) {
+		d.fileOn++
+		writeConfigFile(d.configFile, d.makeDefaultConfig(d.fileOn))
+	}
+
+	// Remove data from memory if dataOnDrive is true
+	if d.dataOnDrive {
+		e.data = nil
+	}
+
+	// Insert item
+	d.lastID = e.id
+	d.addEntry(&e)
+	d.eMux.Unlock()
 
+	return &e, helpers.Error{}
+}
 
-func (d *Datelist) Get(
-		start *time.Time, sel []string, where map[string]interface{},
-		asc bool, limit int, page int) ([]*DateListEntry, int) {
+// Example JSON for get query:
+//
+//     ["Get", "tableName", "start", ["items", "to", "select"], { *where items* }, asc, limit, page]
+//
+// Where items are get query items that must result in true for an entry to be selected:
+//
+//     {"mmr.*gte": [1500], "name.*eq": ["Maya"]}
+//
 
-	// key is required
-	if len(key) == 0 {
-		return nil, helpers.ErrorKeyRequired
+// Get gets up to limit entries (on the given page of results) in time order, starting from start. A nil start starts
+// from the oldest entry when asc is true, or the newest entry when asc is false. sel is the list of items to get
+// from each entry (all items if empty), and where is a map of query items that must result in true for an entry
+// to be selected.
+func (d *DateList) Get(start *time.Time, sel []string, where map[string]interface{}, asc bool, limit int, page int) ([]Result, helpers.Error) {
+	if limit <= 0 {
+		limit = defaultGetLimit
+	}
+	if page < 0 {
+		page = 0
+	}
+	if start != nil {
+		st := start.In(timeLocation)
+		start = &st
 	}
 
-	// default start and end
-	if start == nil {
-		start = time.Now()
+	// Check query items
+	for _, itemName := range sel {
+		siName, _ := schema.GetQueryItemMethods(itemName)
+		if si := d.schema[siName]; !si.QuickValidate() {
+			return nil, helpers.NewError(helpers.ErrorInvalidItem, itemName)
+		}
+	}
+	for itemName := range where {
+		siName, _ := schema.GetQueryItemMethods(itemName)
+		if si := d.schema[siName]; !si.QuickValidate() {
+			return nil, helpers.NewError(helpers.ErrorInvalidItem, itemName)
+		}
 	}
 
-	var e []*DateListEntry
+	results := []Result{}
+	skip := limit * page
+	var err helpers.Error
+	d.eMux.Lock()
+	d.walk(start, asc, func(e *DateListEntry) bool {
+		data, dErr := d.entryData(e)
+		if dErr != 0 {
+			err = helpers.NewError(dErr, strconv.FormatUint(e.id, 10))
+			return false
+		}
+		// Check where items
+		var match bool
+		if match, err = d.matches(data, where); err.ID != 0 {
+			return false
+		} else if !match {
+			return true
+		}
+		// Skip previous pages
+		if skip > 0 {
+			skip--
+			return true
+		}
+		// Select items
+		r := Result{ID: e.id, Time: e.iTime, Data: make(map[string]interface{})}
+		if len(sel) == 0 {
+			for itemName, si := range d.schema {
+				var i interface{}
+				if fErr := schema.ItemFilter(nil, nil, &i, data[si.DataIndex()], si, nil, d.EncryptCost(), true, false); fErr != 0 {
+					err = helpers.NewError(fErr, itemName)
+					return false
+				}
+				r.Data[itemName] = i
+			}
+		} else {
+			for _, itemName := range sel {
+				siName, itemMethods := schema.GetQueryItemMethods(itemName)
+				si := d.schema[siName]
+				var i interface{}
+				if fErr := schema.ItemFilter([]interface{}{}, itemMethods, &i, data[si.DataIndex()], si, nil, d.EncryptCost(), true, false); fErr != 0 {
+					err = helpers.NewError(fErr, itemName)
+					return false
+				}
+				r.Data[itemName] = i
+			}
+		}
+		results = append(results, r)
+		return len(results) < limit
+	})
+	d.eMux.Unlock()
+	if err.ID != 0 {
+		return nil, err
+	}
+	return results, helpers.Error{}
+}
 
+// Checks if an entry's data results in true for all where items
+func (d *DateList) matches(data []interface{}, where map[string]interface{}) (bool, helpers.Error) {
+	for itemName, methodParams := range where {
+		siName, itemMethods := schema.GetQueryItemMethods(itemName)
+		si := d.schema[siName]
+		var i interface{}
+		if err := schema.ItemFilter(methodParams, itemMethods, &i, data[si.DataIndex()], si, nil, d.EncryptCost(), true, false); err != 0 {
+			return false, helpers.NewError(err, itemName)
+		}
+		b, ok := i.(bool)
+		if !ok {
+			return false, helpers.NewError(helpers.ErrorQueryInvalidFormat, itemName)
+		} else if !b {
+			return false, helpers.Error{}
+		}
+	}
+	return true, helpers.Error{}
+}
 
+// GetEntry gets a DateListEntry by it's ID
+func (d *DateList) GetEntry(id uint64) (*DateListEntry, int) {
+	d.eMux.Lock()
+	e := d.ids[id]
+	d.eMux.Unlock()
+	if e == nil {
+		return nil, helpers.ErrorNoEntryFound
+	}
 	return e, 0
-}
\ No newline at end of file
+}
+
+// GetTime gets the time stamp of an entry
+func (d *DateList) GetTime(e *DateListEntry) time.Time {
+	d.eMux.Lock()
+	t := e.iTime
+	d.eMux.Unlock()
+	return t
+}
+
+// Gets a copy of an entry's data from memory or disk
+func (d *DateList) entryData(e *DateListEntry) ([]interface{}, int) {
+	if d.dataOnDrive {
+		return d.dataFromDrive(helpers.DataPath(dataFolderPrefix+d.name)+"/"+strconv.Itoa(int(e.persistFile))+helpers.FileTypeStorage, e.persistIndex)
+	}
+	e.mux.Lock()
+	data := append([]interface{}{}, e.data...)
+	e.mux.Unlock()
+	return data, 0
+}
+
+func (d *DateList) dataFromDrive(file string, index uint32) ([]interface{}, int) {
+	// Read bytes from file
+	bytes, rErr := storage.Read(file, index)
+	if rErr != 0 {
+		return nil, rErr
+	}
+	var jEntry jsonEntry
+	jErr := json.Unmarshal(bytes, &jEntry)
+	if jErr != nil {
+		return nil, helpers.ErrorJsonDecoding
+	}
+	if jEntry.D == nil || len(jEntry.D) == 0 {
+		return nil, helpers.ErrorJsonDecoding
+	}
+	return jEntry.D, 0
+}
+
+// Example JSON for update query:
+//
+//     ["Update", "tableName", id, { *items to update* }]
+//
+
+// UpdateEntry updates an entry by it's ID. When the DateList's updateTime setting is on, the entry's time stamp is
+// set to the current time.
+func (d *DateList) UpdateEntry(id uint64, updateObj map[string]interface{}) helpers.Error {
+	if updateObj == nil || len(updateObj) == 0 {
+		return helpers.NewError(helpers.ErrorQueryInvalidFormat, "")
+	}
+
+	e, err := d.GetEntry(id)
+	if err != 0 {
+		return helpers.NewError(err, "")
+	}
+
+	// Get time stamp
+	updateTime := d.updateTime.Load().(bool)
+	iTime := d.GetTime(e)
+	if updateTime {
+		iTime = d.timeStamp(time.Now())
+	}
+
+	// Get entry data
+	var data []interface{}
+	if d.dataOnDrive {
+		data, err = d.dataFromDrive(helpers.DataPath(dataFolderPrefix+d.name)+"/"+strconv.Itoa(int(e.persistFile))+helpers.FileTypeStorage, e.persistIndex)
+		if err != 0 {
+			return helpers.NewError(err, "")
+		}
+		e.mux.Lock()
+	} else {
+		e.mux.Lock()
+		data = append([]interface{}{}, e.data...)
+	}
+
+	uniqueVals := make(map[string]interface{})
+	uniqueValsBefore := make(map[string]interface{})
+	// Iterate through updateObj
+	for updateName, updateItem := range updateObj {
+		uName, itemMethods := schema.GetQueryItemMethods(updateName)
+
+		// Check if valid schema item
+		schemaItem := d.schema[uName]
+		if !schemaItem.QuickValidate() {
+			e.mux.Unlock()
+			return helpers.NewError(helpers.ErrorSchemaInvalid, updateName)
+		}
+
+		itemBefore := data[schemaItem.DataIndex()]
+
+		// Item filter
+		err = schema.ItemFilter(updateItem, itemMethods, &data[schemaItem.DataIndex()], itemBefore, schemaItem, &uniqueVals, d.EncryptCost(), false, false)
+		if err != 0 {
+			e.mux.Unlock()
+			return helpers.NewError(err, updateName)
+		}
+		// Check for changed unique value to remove old value from table's uniqueVals
+		if uniqueVals[uName] != nil && data[schemaItem.DataIndex()] != itemBefore {
+			uniqueValsBefore[uName] = itemBefore
+		}
+	}
+
+	// Make JSON []byte for entry
+	var jBytes []byte
+	if !d.memOnly {
+		if jErr := makeJsonBytes(e.id, iTime, d.unixNano.Load().(bool), data, &jBytes); jErr != 0 {
+			e.mux.Unlock()
+			return helpers.NewError(jErr, "")
+		}
+	}
+	d.uMux.Lock()
+	// Check unique values
+	for itemName, itemVal := range uniqueVals {
+		if d.uniqueVals[itemName] != nil && d.uniqueVals[itemName][itemVal] {
+			d.uMux.Unlock()
+			e.mux.Unlock()
+			return helpers.NewError(helpers.ErrorUniqueValueDuplicate, itemName)
+		}
+	}
+
+	// Update entry on disk with jBytes
+	if !d.memOnly {
+		err = storage.Update(helpers.DataPath(dataFolderPrefix+d.name)+"/"+strconv.Itoa(int(e.persistFile))+helpers.FileTypeStorage, e.persistIndex, jBytes)
+		if err != 0 {
+			d.uMux.Unlock()
+			e.mux.Unlock()
+			return helpers.NewError(err, "")
+		}
+	}
+
+	// Apply unique values
+	for itemName, itemVal := range uniqueVals {
+		if d.uniqueVals[itemName] == nil {
+			d.uniqueVals[itemName] = make(map[interface{}]bool)
+		}
+		d.uniqueVals[itemName][itemVal] = true
+
+		// Remove old unique values
+		if uniqueValsBefore[itemName] != nil {
+			delete(d.uniqueVals[itemName], uniqueValsBefore[itemName])
+		}
+	}
+	d.uMux.Unlock()
+
+	//
+	if !d.dataOnDrive {
+		e.data = data
+	}
+	e.mux.Unlock()
+
+	// Move entry to it's new position
+	if updateTime {
+		d.eMux.Lock()
+		if d.ids[e.id] == e {
+			d.removeEntry(e)
+			e.iTime = iTime
+			d.addEntry(e)
+		}
+		d.eMux.Unlock()
+	}
+
+	return helpers.Error{}
+}
+
+// Example JSON for delete query:
+//
+//     ["Delete", "tableName", id]
+//
+
+// DeleteEntry deletes an entry by it's ID
+func (d *DateList) DeleteEntry(id uint64) helpers.Error {
+	e, err := d.GetEntry(id)
+	if err != 0 {
+		return helpers.NewError(err, "")
+	}
+
+	// Get entry data
+	var data []interface{}
+	if d.dataOnDrive {
+		data, err = d.dataFromDrive(helpers.DataPath(dataFolderPrefix+d.name)+"/"+strconv.Itoa(int(e.persistFile))+helpers.FileTypeStorage, e.persistIndex)
+		if err != 0 {
+			return helpers.NewError(err, "")
+		}
+		e.mux.Lock()
+	} else {
+		e.mux.Lock()
+		data = append([]interface{}{}, e.data...)
+	}
+
+	d.uMux.Lock()
+	uItems := []string{}
+	schema.GetUniqueItems(d.schema, &uItems, "")
+	for _, itemName := range uItems {
+		// Get entry's unique value for this unique item
+		siName, itemMethods := schema.GetQueryItemMethods(itemName)
+		//
+		si := d.schema[siName]
+		if !si.QuickValidate() {
+			e.mux.Unlock()
+			d.uMux.Unlock()
+			return helpers.NewError(helpers.ErrorUnexpected, "")
+		}
+		// Make get filter
+		var i interface{}
+		err := schema.ItemFilter(nil, itemMethods, &i, data[si.DataIndex()], si, nil, d.EncryptCost(), true, false)
+		if err != 0 {
+			e.mux.Unlock()
+			d.uMux.Unlock()
+			return helpers.NewError(helpers.ErrorUnexpected, "")
+		}
+		delete(d.uniqueVals[itemName], i)
+	}
+	e.mux.Unlock()
+	d.uMux.Unlock()
+
+	// Update entry on disk with []byte{}
+	if !d.memOnly {
+		err = storage.Update(helpers.DataPath(dataFolderPrefix+d.name)+"/"+strconv.Itoa(int(e.persistFile))+helpers.FileTypeStorage, e.persistIndex, []byte{})
+		if err != 0 {
+			return helpers.NewError(err, "")
+		}
+	}
+
+	// Delete entry
+	d.eMux.Lock()
+	d.removeEntry(e)
+	d.eMux.Unlock()
+
+	return helpers.Error{}
+}
+
+// Restores an entry from a storage file - NOT concurrently safe on it's own! Must lock DateList before-hand.
+func (d *DateList) restoreEntry(jEntry jsonEntry, fileOn uint32, lineOn uint32) int {
+	// Check for duplicate entry
+	if d.ids[jEntry.I] != nil {
+		return helpers.ErrorKeyInUse
+	}
+
+	// Create entry
+	e := DateListEntry{
+		id:   jEntry.I,
+		data: make([]interface{}, len(d.schema), len(d.schema)),
+	}
+	if jEntry.N {
+		e.iTime = time.Unix(0, jEntry.T).In(timeLocation)
+	} else {
+		e.iTime = time.Unix(jEntry.T, 0).In(timeLocation)
+	}
+
+	uniqueVals := make(map[string]interface{})
+
+	// Fill entry data with data
+	for _, schemaItem := range d.schema {
+		if int(schemaItem.DataIndex()) > len(jEntry.D)-1 {
+			return helpers.ErrorRestoreItemSchema
+		}
+
+		// Item filter
+		err := schema.ItemFilter(jEntry.D[schemaItem.DataIndex()], nil, &e.data[schemaItem.DataIndex()], nil, schemaItem, &uniqueVals, 0, false, true)
+		if err != 0 {
+			return err
+		}
+	}
+
+	// Check unique values
+	for itemName, itemVal := range uniqueVals {
+		if d.uniqueVals[itemName] != nil && d.uniqueVals[itemName][itemVal] {
+			return helpers.ErrorUniqueValueDuplicate
+		}
+	}
+
+	// Apply unique values
+	for itemName, itemVal := range uniqueVals {
+		if d.uniqueVals[itemName] == nil {
+			d.uniqueVals[itemName] = make(map[interface{}]bool)
+		}
+		d.uniqueVals[itemName][itemVal] = true
+	}
+
+	//
+	e.persistIndex = lineOn
+	e.persistFile = fileOn
+
+	// Remove data from memory if dataOnDrive is true
+	if d.dataOnDrive {
+		e.data = nil
+	}
+
+	// Insert item
+	if e.id > d.lastID {
+		d.lastID = e.id
+	}
+	d.addEntry(&e)
+	return 0
+}
@@ -1,38 +1,65 @@
+/*
+datelist package Copyright 2020 Dominique Debergue
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at:
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+either express or implied. See the License for the specific
+language governing permissions and limitations under the License.
+*/
+
 package datelist
 
 import (
+	"encoding/json"
+	"fmt"
 	"github.com/hewiefreeman/GopherDB/helpers"
 	"github.com/hewiefreeman/GopherDB/schema"
+	"github.com/hewiefreeman/GopherDB/storage"
+	"github.com/schollz/progressbar"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // Month names
 const (
-	January = "January"
-	February = "February"
-	March = "March"
-	April = "April"
-	May = "May"
-	June = "June"
-	July = "July"
-	August = "August"
+	January   = "January"
+	February  = "February"
+	March     = "March"
+	April     = "April"
+	May       = "May"
+	June      = "June"
+	July      = "July"
+	August    = "August"
 	September = "September"
-	October = "October"
-	November = "November"
-	December = "December"
+	October   = "October"
+	November  = "November"
+	December  = "December"
 
 	// Alias names (May doesn't need one ?)
-	JanuaryAlias = "Jan"
-	FebruaryAlias = "Feb"
-	MarchAlias = "Mar"
-	AprilAlias = "Apr"
-	JuneAlias = "Jun"
-	JulyAlias = "Jul"
-	AugustAlias = "Aug"
+	JanuaryAlias   = "Jan"
+	FebruaryAlias  = "Feb"
+	MarchAlias     = "Mar"
+	AprilAlias     = "Apr"
+	JuneAlias      = "Jun"
+	JulyAlias      = "Jul"
+	AugustAlias    = "Aug"
 	SeptemberAlias = "Sep"
-	OctoberAlias = "Oct"
-	NovemberAlias = "Nov"
-	DecemberAlias = "Dec"
+	OctoberAlias   = "Oct"
+	NovemberAlias  = "Nov"
+	DecemberAlias  = "Dec"
 )
 
 // Month IDs
@@ -53,37 +80,45 @@ const (
 
 const (
 	monthsInYear = 12
-	daysInMonth  = 32
-	hoursInDay   = 24
+	daysInMonth  = 32 // Indexed by day of the month (1-31)
 
 	dataFolderPrefix = "DL-"
+
+	// Default Get limit
+	defaultGetLimit int = 100
 )
 
 var (
 	listsMux     sync.Mutex
 	lists        map[string]*DateList = make(map[string]*DateList)
-	timeLocation *time.Location = time.UTC
+	timeLocation *time.Location       = time.UTC
 )
 
+// DateList is a table of entries ordered by their time stamp, grouped into Year, Month, and Day buckets.
 type DateList struct {
-	fileOn    uint16 // locked by eMux - placed for memory efficiency
+	fileOn uint32 // locked by eMux - placed for memory efficiency
 
 	// Settings and schema - read only
-	memOnly       bool // Store data in memory only (overrides dataOnDrive)
-	dataOnDrive   bool // when true, entry data is not stored in memory, only indexing
-	persistName   string // table's logger/persist folder name
-	schema        *schema.Schema // table's schema
+	// Changing some of these setting requires a reformat and/or restore of the DateList
+	memOnly     bool          // Store data in memory only (overrides dataOnDrive)
+	dataOnDrive bool          // when true, entry data is not stored in memory, only indexing
+	name        string        // table's logger/persist folder name
+	schema      schema.Schema // table's schema
+	configFile  *os.File      // configuration file
 
 	// Atomic changeable settings values - 99% read
-	partitionMax  atomic.Value // *uint16* maximum entries per data file
-	maxEntries    atomic.Value // *uint64* maximum amount of entries in the Datelist
-	encryptCost   atomic.Value // *int* encryption cost of encrypted items
-	updateTime    atomic.Value // *bool* when true, inserts as well as updates will set an entry's time stamp and database position
-	unixNano      atomic.Value // *bool* when true, time stamps will be stored in Unix Nano instead of the default Unix
+	partitionMax atomic.Value // *uint32* maximum entries per data file
+	maxEntries   atomic.Value // *uint64* maximum amount of entries in the DateList
+	encryptCost  atomic.Value // *int* encryption cost of encrypted items
+	syncPolicy   atomic.Value // *uint8* storage sync policy of data files
+	updateTime   atomic.Value // *bool* when true, inserts as well as updates will set an entry's time stamp and database position
+	unixNano     atomic.Value // *bool* when true, time stamps will be stored in Unix Nano instead of the default Unix
 
 	// date list & entry counter
-	eMux       sync.Mutex // entries lock
-	datelist   []Year
+	eMux       sync.Mutex // entries/configFile lock
+	years      []*Year    // Years in ascending order
+	ids        map[uint64]*DateListEntry
+	lastID     uint64
 	entryCount uint64
 
 	// unique values
@@ -91,38 +126,62 @@ type DateList struct {
 	uniqueVals map[string]map[interface{}]bool
 }
 
+// Year is a DateList bucket holding a year's Months.
 type Year struct {
-
-	months []Month
+	year       int
+	entryCount uint64
+	months     [monthsInYear]*Month
 }
 
+// Month is a DateList bucket holding a month's Days.
 type Month struct {
-	entryCount uin64
-	days []Day
+	entryCount uint64
+	days       [daysInMonth]*Day
 }
 
+// Day is a DateList bucket holding a day's entries in ascending time order.
 type Day struct {
-	entryCount uint64
-	entries    []*DateListEntry
+	entries []*DateListEntry
 }
 
+// DateListEntry is an entry in a DateList.
 type DateListEntry struct {
-	persistFile  uint16
-	persistIndex uint16
+	id           uint64
+	persistFile  uint32
+	persistIndex uint32
 
 	mux   sync.Mutex
-	iTime time.Time
+	iTime time.Time // locked by the DateList's eMux
 	data  []interface{}
 }
 
-// New creates a new Datelist
-func New(name string, s *schema.Schema, fileOn uint16, dataOnDrive bool, memOnly bool) (*DateList, int) {
+type dateListConfig struct {
+	FormatVersion uint8
+	Name          string
+	Schema        []schema.SchemaConfigItem
+	FileOn        uint32
+	DataOnDrive   bool
+	MemOnly       bool
+	PartitionMax  uint32
+	EncryptCost   int
+	MaxEntries    uint64
+	SyncPolicy    uint8
+	UpdateTime    bool
+	UnixNano      bool
+}
+
+//////////////////////////////////////////////////////////////////////////////////////////////////////
+//   DateList   //////////////////////////////////////////////////////////////////////////////////////
+//////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// New creates a new DateList with the provided name, schema, and other parameters.
+func New(name string, configFile *os.File, s schema.Schema, fileOn uint32, dataOnDrive bool, memOnly bool) (*DateList, helpers.Error) {
 	if len(name) == 0 {
-		return nil, helpers.ErrorTableNameRequired
+		return nil, helpers.NewError(helpers.ErrorTableNameRequired, name)
 	} else if Get(name) != nil {
-		return nil, helpers.ErrorTableExists
+		return nil, helpers.NewError(helpers.ErrorTableExists, name)
 	} else if !s.Validate() {
-		return nil, helpers.ErrorTableExists
+		return nil, helpers.NewError(helpers.ErrorSchemaInvalid, name)
 	}
 
 	// memOnly overrides dataOnDrive
@@ -130,63 +189,607 @@ func New(name string, s *schema.Schema, fileOn uint16, dataOnDrive bool, memOnly
 		dataOnDrive = false
 	}
 
-	namePre := dataFolderPrefix + name
-
-	// Make table folder   & update config file !!!
-	mkErr := storage.MakeDir(namePre)
-	if mkErr != nil {
-		return nil, helpers.ErrorCreatingFolder
+	// Table name with prefix
+	namePre := helpers.DataPath(dataFolderPrefix + name)
+
+	// Restoring if configFile is not nil
+	if configFile == nil {
+		var err error
+		// Make table storage folder
+		err = storage.MakeDir(namePre)
+		if err != nil {
+			return nil, helpers.NewError(helpers.ErrorCreatingFolder, namePre+helpers.FileTypeConfig+": "+err.Error())
+		}
+
+		// Create/open config file
+		configFile, err = os.OpenFile(namePre+helpers.FileTypeConfig, os.O_RDWR|os.O_CREATE, 0755)
+		if err != nil {
+			return nil, helpers.NewError(helpers.ErrorFileOpen, namePre+helpers.FileTypeConfig+": "+err.Error())
+		}
+
+		// Write config file
+		if wErr := writeConfigFile(configFile, dateListConfig{
+			FormatVersion: helpers.FormatVersion,
+			Name:          name,
+			Schema:        s.MakeConfig(),
+			FileOn:        fileOn,
+			DataOnDrive:   dataOnDrive,
+			MemOnly:       memOnly,
+			PartitionMax:  helpers.DefaultPartitionMax,
+			EncryptCost:   helpers.DefaultEncryptCost,
+			MaxEntries:    helpers.DefaultMaxEntries,
+			SyncPolicy:    helpers.DefaultSyncPolicy,
+		}); wErr != 0 {
+			return nil, helpers.NewError(wErr, namePre+helpers.FileTypeConfig)
+		}
 	}
 
-	// make table
-	d := Datelist{
-		persistName:   namePre,
-		memOnly:       memOnly,
-		dataOnDrive:   dataOnDrive,
-		schema:        s,
-		datelist:      make([]Year),
-		entryCount:    0,
-		uniqueVals:    make(map[string]map[interface{}]bool),
-		fileOn:        fileOn,
+	// Make table
+	d := DateList{
+		name:        name,
+		memOnly:     memOnly,
+		dataOnDrive: dataOnDrive,
+		schema:      s,
+		configFile:  configFile,
+		years:       []*Year{},
+		ids:         make(map[uint64]*DateListEntry),
+		uniqueVals:  make(map[string]map[interface{}]bool),
+		fileOn:      fileOn,
 	}
 
-	// set defaults
+	// Set defaults
 	d.partitionMax.Store(helpers.DefaultPartitionMax)
 	d.maxEntries.Store(helpers.DefaultMaxEntries)
 	d.encryptCost.Store(helpers.DefaultEncryptCost)
+	d.syncPolicy.Store(helpers.DefaultSyncPolicy)
+	storage.SetSyncPolicy(namePre, helpers.DefaultSyncPolicy)
 	d.updateTime.Store(false)
 	d.unixNano.Store(false)
 
-	// push to stores map
+	// Push to lists map
 	listsMux.Lock()
 	lists[name] = &d
 	listsMux.Unlock()
 
-	return &d, 0
+	return &d, helpers.Error{}
 }
 
-// Delete deletes a Datelist with the given name.
-func Delete(name string) int {
+// Get retrieves a DateList by name
+func Get(name string) *DateList {
 	if len(name) == 0 {
-		return helpers.ErrorTableNameRequired
-	} else if Get(name) == nil {
-		return helpers.ErrorTableDoesntExist
+		return nil
 	}
 
 	listsMux.Lock()
-	delete(lists, name)
+	d := lists[name]
 	listsMux.Unlock()
 
-	// !!!!!! delete data folder from system & delete log file & update config file
-
-	return 0
+	return d
 }
 
-// Get retrieves a Datelist by name
-func Get(name string) *DateList {
+// Close a DateList and save current settings to a config file if `save` is true
+func (d *DateList) Close(save bool) {
+	if save {
+		d.eMux.Lock()
+		fileOn := d.fileOn
+		d.eMux.Unlock()
+		conf := d.makeDefaultConfig(fileOn)
+		if err := writeConfigFile(d.configFile, conf); err != 0 {
+			helpers.LogAndPrint("Failed to write config file for DateList '"+d.name+"' while closing, with error code: "+strconv.Itoa(err), 5)
+		}
+	}
+
 	listsMux.Lock()
-	d := lists[name]
+	delete(lists, d.name)
 	listsMux.Unlock()
+}
 
-	return d
-}
\ No newline at end of file
+// Delete a DateList and it's data.
+func (d *DateList) Delete() int {
+	d.Close(false)
+	if d.configFile != nil {
+		d.configFile.Close()
+	}
+
+	// Delete data directory
+	if err := os.RemoveAll(helpers.DataPath(dataFolderPrefix + d.name)); err != nil {
+		helpers.LogAndPrint("Failed delete DateList '"+d.name+"' with error: "+err.Error(), 5)
+		return helpers.ErrorFileDelete
+	}
+
+	// Delete config file
+	if err := os.Remove(helpers.DataPath(dataFolderPrefix+d.name) + helpers.FileTypeConfig); err != nil {
+		helpers.LogAndPrint("Failed delete DateList '"+d.name+"' with error: "+err.Error(), 5)
+		return helpers.ErrorFileDelete
+	}
+
+	return 0
+}
+
+// Size returns the number of entries in the DateList
+func (d *DateList) Size() int {
+	d.eMux.Lock()
+	s := int(d.entryCount)
+	d.eMux.Unlock()
+	return s
+}
+
+// MemOnly returns the current memory-only preference saved for this DateList
+func (d *DateList) MemOnly() bool {
+	return d.memOnly
+}
+
+// DataOnDrive returns the current dataOnDrive preference saved for this DateList
+func (d *DateList) DataOnDrive() bool {
+	return d.dataOnDrive
+}
+
+func (d *DateList) EncryptCost() int {
+	return d.encryptCost.Load().(int)
+}
+
+// SyncPolicy returns the storage sync policy for this DateList's data files
+func (d *DateList) SyncPolicy() uint8 {
+	return d.syncPolicy.Load().(uint8)
+}
+
+// UpdateTime returns true if updates set an entry's time stamp
+func (d *DateList) UpdateTime() bool {
+	return d.updateTime.Load().(bool)
+}
+
+// UnixNano returns true if time stamps are stored in Unix Nano instead of Unix
+func (d *DateList) UnixNano() bool {
+	return d.unixNano.Load().(bool)
+}
+
+// ID returns the entry's DateList ID
+func (e *DateListEntry) ID() uint64 {
+	return e.id
+}
+
+//////////////////////////////////////////////////////////////////////////////////////////////////////
+//   DateList Buckets   //////////////////////////////////////////////////////////////////////////////
+//////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Makes a time stamp with the DateList's time stamp precision
+func (d *DateList) timeStamp(t time.Time) time.Time {
+	if d.unixNano.Load().(bool) {
+		return t.In(timeLocation)
+	}
+	return time.Unix(t.Unix(), 0).In(timeLocation)
+}
+
+// Gets the Day bucket for a time, making the Year, Month, and Day when make is true - must lock eMux before-hand.
+func (d *DateList) getDay(t time.Time, make bool) *Day {
+	t = t.In(timeLocation)
+	yi := sort.Search(len(d.years), func(i int) bool { return d.years[i].year >= t.Year() })
+	if yi == len(d.years) || d.years[yi].year != t.Year() {
+		if !make {
+			return nil
+		}
+		d.years = append(d.years, nil)
+		copy(d.years[yi+1:], d.years[yi:])
+		d.years[yi] = &Year{year: t.Year()}
+	}
+	y := d.years[yi]
+	m := y.months[int(t.Month())-1]
+	if m == nil {
+		if !make {
+			return nil
+		}
+		m = &Month{}
+		y.months[int(t.Month())-1] = m
+	}
+	day := m.days[t.Day()]
+	if day == nil {
+		if !make {
+			return nil
+		}
+		day = &Day{entries: []*DateListEntry{}}
+		m.days[t.Day()] = day
+	}
+	return day
+}
+
+// Adds an entry to it's Day bucket. Entries with the same time stamp are ordered by ID - must lock eMux before-hand.
+func (d *DateList) addEntry(e *DateListEntry) {
+	day := d.getDay(e.iTime, true)
+	i := sort.Search(len(day.entries), func(i int) bool {
+		de := day.entries[i]
+		return de.iTime.After(e.iTime) || (de.iTime.Equal(e.iTime) && de.id > e.id)
+	})
+	day.entries = append(day.entries, nil)
+	copy(day.entries[i+1:], day.entries[i:])
+	day.entries[i] = e
+	d.countEntry(e.iTime, 1)
+	d.ids[e.id] = e
+}
+
+// Removes an entry from it's Day bucket - must lock eMux before-hand.
+func (d *DateList) removeEntry(e *DateListEntry) {
+	day := d.getDay(e.iTime, false)
+	if day == nil {
+		return
+	}
+	for i, de := range day.entries {
+		if de == e {
+			day.entries = append(day.entries[:i], day.entries[i+1:]...)
+			d.countEntry(e.iTime, -1)
+			break
+		}
+	}
+	delete(d.ids, e.id)
+}
+
+// Changes the entry counts of the buckets for a time stamp, and removes empty buckets - must lock eMux before-hand.
+func (d *DateList) countEntry(t time.Time, n int) {
+	t = t.In(timeLocation)
+	yi := sort.Search(len(d.years), func(i int) bool { return d.years[i].year >= t.Year() })
+	if yi == len(d.years) || d.years[yi].year != t.Year() {
+		return
+	}
+	y := d.years[yi]
+	m := y.months[int(t.Month())-1]
+	y.entryCount = uint64(int64(y.entryCount) + int64(n))
+	m.entryCount = uint64(int64(m.entryCount) + int64(n))
+	d.entryCount = uint64(int64(d.entryCount) + int64(n))
+	if len(m.days[t.Day()].entries) == 0 {
+		m.days[t.Day()] = nil
+	}
+	if m.entryCount == 0 {
+		y.months[int(t.Month())-1] = nil
+	}
+	if y.entryCount == 0 {
+		d.years = append(d.years[:yi], d.years[yi+1:]...)
+	}
+}
+
+// Calls f on every entry from start (nil for the first or last entry) in ascending or descending time order,
+// until f returns false - must lock eMux before-hand.
+func (d *DateList) walk(start *time.Time, asc bool, f func(*DateListEntry) bool) {
+	if asc {
+		for _, y := range d.years {
+			if start != nil && y.year < start.Year() {
+				continue
+			}
+			for _, m := range y.months {
+				if m == nil {
+					continue
+				}
+				for _, day := range m.days {
+					if day == nil {
+						continue
+					}
+					for _, e := range day.entries {
+						if start != nil && e.iTime.Before(*start) {
+							continue
+						} else if !f(e) {
+							return
+						}
+					}
+				}
+			}
+		}
+		return
+	}
+	for yi := len(d.years) - 1; yi >= 0; yi-- {
+		y := d.years[yi]
+		if start != nil && y.year > start.Year() {
+			continue
+		}
+		for mi := monthsInYear - 1; mi >= 0; mi-- {
+			m := y.months[mi]
+			if m == nil {
+				continue
+			}
+			for di := daysInMonth - 1; di >= 0; di-- {
+				day := m.days[di]
+				if day == nil {
+					continue
+				}
+				for i := len(day.entries) - 1; i >= 0; i-- {
+					e := day.entries[i]
+					if start != nil && e.iTime.After(*start) {
+						continue
+					} else if !f(e) {
+						return
+					}
+				}
+			}
+		}
+	}
+}
+
+//////////////////////////////////////////////////////////////////////////////////////////////////////
+//   DateList Setters   //////////////////////////////////////////////////////////////////////////////
+//////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// SetEncryptionCost sets the bcrypt encrytion cost
+func (d *DateList) SetEncryptionCost(cost int) int {
+	if cost > helpers.EncryptCostMax {
+		cost = helpers.EncryptCostMax
+	} else if cost < helpers.EncryptCostMin {
+		cost = helpers.EncryptCostMin
+	}
+	// Write to configFile
+	d.eMux.Lock()
+	fileOn := d.fileOn
+	d.eMux.Unlock()
+	conf := d.makeDefaultConfig(fileOn)
+	conf.EncryptCost = cost
+	if err := writeConfigFile(d.configFile, conf); err != 0 {
+		helpers.LogAndPrint("Failed to set encryption cost for DateList '"+d.name+"' with error code: "+strconv.Itoa(err), 4)
+		return err
+	}
+	d.encryptCost.Store(cost)
+	return 0
+}
+
+// SetMaxEntries sets the maximum entries for the DateList
+func (d *DateList) SetMaxEntries(max uint64) int {
+	// Write to configFile
+	d.eMux.Lock()
+	fileOn := d.fileOn
+	d.eMux.Unlock()
+	conf := d.makeDefaultConfig(fileOn)
+	conf.MaxEntries = max
+	if err := writeConfigFile(d.configFile, conf); err != 0 {
+		helpers.LogAndPrint("Failed to set maximum entries for DateList '"+d.name+"' with error code: "+strconv.Itoa(err), 4)
+		return err
+	}
+	d.maxEntries.Store(max)
+	return 0
+}
+
+// SetPartitionMax sets the maximum entries stored in a data file
+func (d *DateList) SetPartitionMax(max uint32) int {
+	if max < helpers.PartitionMin {
+		max = helpers.DefaultPartitionMax
+	}
+
+	// Write to configFile
+	d.eMux.Lock()
+	fileOn := d.fileOn
+	d.eMux.Unlock()
+	conf := d.makeDefaultConfig(fileOn)
+	conf.PartitionMax = max
+	if err := writeConfigFile(d.configFile, conf); err != 0 {
+		helpers.LogAndPrint("Failed to set partition max size for DateList '"+d.name+"' with error code: "+strconv.Itoa(err), 4)
+		return err
+	}
+	d.partitionMax.Store(max)
+	return 0
+}
+
+// SetSyncPolicy sets how writes to the DateList's data files are flushed to the disk
+func (d *DateList) SetSyncPolicy(policy uint8) int {
+	if policy > helpers.SyncPolicyAlways {
+		policy = helpers.DefaultSyncPolicy
+	}
+
+	// Write to configFile
+	d.eMux.Lock()
+	fileOn := d.fileOn
+	d.eMux.Unlock()
+	conf := d.makeDefaultConfig(fileOn)
+	conf.SyncPolicy = policy
+	if err := writeConfigFile(d.configFile, conf); err != 0 {
+		helpers.LogAndPrint("Failed to set sync policy for DateList '"+d.name+"' with error code: "+strconv.Itoa(err), 4)
+		return err
+	}
+	d.syncPolicy.Store(policy)
+	storage.SetSyncPolicy(helpers.DataPath(dataFolderPrefix+d.name), policy)
+	return 0
+}
+
+// SetUpdateTime sets whether updates set an entry's time stamp (and position in the DateList) to the current time
+func (d *DateList) SetUpdateTime(updateTime bool) int {
+	// Write to configFile
+	d.eMux.Lock()
+	fileOn := d.fileOn
+	d.eMux.Unlock()
+	conf := d.makeDefaultConfig(fileOn)
+	conf.UpdateTime = updateTime
+	if err := writeConfigFile(d.configFile, conf); err != 0 {
+		helpers.LogAndPrint("Failed to set update time for DateList '"+d.name+"' with error code: "+strconv.Itoa(err), 4)
+		return err
+	}
+	d.updateTime.Store(updateTime)
+	return 0
+}
+
+// SetUnixNano sets whether new time stamps are stored in Unix Nano instead of Unix. Existing time stamps keep
+// their precision.
+func (d *DateList) SetUnixNano(unixNano bool) int {
+	// Write to configFile
+	d.eMux.Lock()
+	fileOn := d.fileOn
+	d.eMux.Unlock()
+	conf := d.makeDefaultConfig(fileOn)
+	conf.UnixNano = unixNano
+	if err := writeConfigFile(d.configFile, conf); err != 0 {
+		helpers.LogAndPrint("Failed to set unix nano for DateList '"+d.name+"' with error code: "+strconv.Itoa(err), 4)
+		return err
+	}
+	d.unixNano.Store(unixNano)
+	return 0
+}
+
+func (d *DateList) makeDefaultConfig(fileOn uint32) dateListConfig {
+	return dateListConfig{
+		FormatVersion: helpers.FormatVersion,
+		Name:          d.name,
+		Schema:        d.schema.MakeConfig(),
+		FileOn:        fileOn,
+		DataOnDrive:   d.dataOnDrive,
+		MemOnly:       d.memOnly,
+		PartitionMax:  d.partitionMax.Load().(uint32),
+		EncryptCost:   d.encryptCost.Load().(int),
+		MaxEntries:    d.maxEntries.Load().(uint64),
+		SyncPolicy:    d.syncPolicy.Load().(uint8),
+		UpdateTime:    d.updateTime.Load().(bool),
+		UnixNano:      d.unixNano.Load().(bool),
+	}
+}
+
+// Writes c to f and truncates file
+func writeConfigFile(f *os.File, c dateListConfig) int {
+	jBytes, jErr := helpers.Fjson.MarshalIndent(c, "", "   ")
+	if jErr != nil {
+		return helpers.ErrorJsonEncoding
+	}
+
+	// Write to config file
+	if _, wErr := f.WriteAt(jBytes, 0); wErr != nil {
+		return helpers.ErrorFileUpdate
+	}
+	f.Truncate(int64(len(jBytes)))
+	return 0
+}
+
+//////////////////////////////////////////////////////////////////////////////////////////////////////
+//   DateList Restoring   ////////////////////////////////////////////////////////////////////////////
+//////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Restore restores a DateList by name; requires a valid config file and data folder.
+func Restore(name string) (*DateList, helpers.Error) {
+	fmt.Printf("Restoring DateList '%v'...\n", name)
+	namePre := helpers.DataPath(dataFolderPrefix + name)
+	// Open the File
+	f, err := os.OpenFile(namePre+helpers.FileTypeConfig, os.O_RDWR, 0755)
+	if err != nil {
+		return nil, helpers.NewError(helpers.ErrorFileOpen, "Config file missing for DateList '"+name+"'")
+	}
+	// Get file stats
+	fs, fsErr := f.Stat()
+	if fsErr != nil {
+		f.Close()
+		return nil, helpers.NewError(helpers.ErrorFileOpen, "Error reading config file for DateList '"+name+"'")
+	}
+	// Get file bytes
+	bytes := make([]byte, fs.Size())
+	_, rErr := f.ReadAt(bytes, 0)
+	if rErr != nil && rErr != io.EOF {
+		f.Close()
+		return nil, helpers.NewError(helpers.ErrorFileRead, "Config data is corrupt for DateList '"+name+"'")
+	}
+	// Make confStruct from json bytes
+	confStruct := dateListConfig{SyncPolicy: helpers.DefaultSyncPolicy}
+	mErr := json.Unmarshal(bytes, &confStruct)
+	if mErr != nil {
+		f.Close()
+		return nil, helpers.NewError(helpers.ErrorJsonDecoding,
+			"Config contains JSON syntax errors for DateList '"+name+"': "+mErr.Error())
+	}
+	if confStruct.FormatVersion > helpers.FormatVersion {
+		f.Close()
+		return nil, helpers.NewError(helpers.ErrorFormatVersion, "DateList '"+name+"' was made by a newer version (format version "+strconv.Itoa(int(confStruct.FormatVersion))+")")
+	}
+	// Make schema with the schemaList
+	s, schemaErr := schema.Restore(confStruct.Schema)
+	if schemaErr.ID != 0 {
+		f.Close()
+		schemaErr.From = "(DateList '" + name + "') " + schemaErr.From
+		return nil, schemaErr
+	}
+	// Make DateList table
+	d, dErr := New(name, f, s, confStruct.FileOn, confStruct.DataOnDrive, confStruct.MemOnly)
+	if dErr.ID != 0 {
+		f.Close()
+		return nil, dErr
+	}
+	d.eMux.Lock()
+	d.uMux.Lock()
+	// Set optional settings if different from defaults
+	if confStruct.EncryptCost != helpers.DefaultEncryptCost {
+		d.encryptCost.Store(confStruct.EncryptCost)
+	}
+	if confStruct.MaxEntries != helpers.DefaultMaxEntries {
+		d.maxEntries.Store(confStruct.MaxEntries)
+	}
+	if confStruct.PartitionMax != helpers.DefaultPartitionMax {
+		d.partitionMax.Store(confStruct.PartitionMax)
+	}
+	if confStruct.SyncPolicy != helpers.DefaultSyncPolicy {
+		d.syncPolicy.Store(confStruct.SyncPolicy)
+		storage.SetSyncPolicy(namePre, confStruct.SyncPolicy)
+	}
+	d.updateTime.Store(confStruct.UpdateTime)
+	d.unixNano.Store(confStruct.UnixNano)
+	// Open data folder
+	df, err := os.Open(namePre)
+	if err != nil {
+		d.eMux.Unlock()
+		d.uMux.Unlock()
+		d.Close(false)
+		return nil, helpers.NewError(helpers.ErrorFileOpen, "Missing data folder for DateList '"+name+"'")
+	}
+	// Get file names
+	files, err := df.Readdir(-1)
+	df.Close()
+	if err != nil {
+		d.eMux.Unlock()
+		d.uMux.Unlock()
+		d.Close(false)
+		return nil, helpers.NewError(helpers.ErrorFileRead, "Error reading files in data folder for DateList '"+name+"'")
+	}
+	fmt.Printf("Loading DateList data for '%v'...\n", name)
+	// Make progress bar
+	pBar := progressbar.New(len(files))
+	// Go through files & restore entries
+	for _, fileStats := range files {
+		// Get file number
+		fileNameSplit := strings.Split(fileStats.Name(), ".")
+		fileNum, fnErr := strconv.Atoi(fileNameSplit[0])
+		if fnErr != nil || len(fileNameSplit) < 2 || "."+fileNameSplit[1] != helpers.FileTypeStorage {
+			// Not a valid storage file
+			pBar.Add(1)
+			continue
+		}
+		var of *storage.OpenFile
+		var err int
+		if of, err = storage.GetOpenFile(namePre + "/" + fileStats.Name()); err != 0 {
+			helpers.LogAndPrint("Error: DateList '"+name+"':: Could not read data file '"+namePre+"/"+fileStats.Name()+"'!\n", 4)
+			pBar.Add(1)
+			continue
+		}
+		for i := 0; i < of.Lines(); i++ {
+			// Get line bytes
+			var lb []byte
+			if lb, err = of.Read(uint32(i + 1)); err != 0 {
+				helpers.LogAndPrint("Error: DateList '"+name+"':: Could not read line "+strconv.Itoa(i+1)+" of '"+fileStats.Name()+"'!\n", 4)
+				continue
+			} else if len(lb) == 0 {
+				// Deleted entry
+				continue
+			}
+			jEntry, ok := restoreDataLine(lb)
+			if !ok {
+				helpers.LogAndPrint("Error: DateList '"+name+"':: Incorrect JSON format on line "+strconv.Itoa(i+1)+" of '"+fileStats.Name()+"'!\n", 4)
+				continue
+			}
+			if err = d.restoreEntry(jEntry, uint32(fileNum), uint32(i+1)); err != 0 {
+				helpers.LogAndPrint("Error: DateList '"+name+"':: Line "+strconv.Itoa(i+1)+" of '"+fileStats.Name()+"', with error code "+strconv.Itoa(err), 4)
+				continue
+			}
+		}
+		pBar.Add(1)
+	}
+	d.uMux.Unlock()
+	d.eMux.Unlock()
+	fmt.Printf("Successfully restored table '%v'!\n", name)
+	return d, helpers.Error{}
+}
+
+// Restore a line of data from a storage file
+func restoreDataLine(line []byte) (jsonEntry, bool) {
+	var jEntry jsonEntry
+	if mErr := json.Unmarshal(line, &jEntry); mErr != nil {
+		return jEntry, false
+	}
+	if jEntry.D == nil || jEntry.I == 0 {
+		return jEntry, false
+	}
+	return jEntry, true
+}
@@ -0,0 +1,206 @@
+package datelist
+
+import (
+	"github.com/hewiefreeman/GopherDB/datelist"
+	"github.com/hewiefreeman/GopherDB/helpers"
+	"github.com/hewiefreeman/GopherDB/schema"
+	"github.com/hewiefreeman/GopherDB/storage"
+	"testing"
+	"time"
+)
+
+const (
+	// Test settings
+	tableName string = "test"
+)
+
+var (
+	// Test variables
+	setupComplete bool
+	table         *datelist.DateList
+	ids           map[string]uint64 = make(map[string]uint64)
+)
+
+// TO TEST:
+// go test -v datelist_test.go
+//
+// Use -v to display fmt output
+
+func TestNew(t *testing.T) {
+	storage.Init("")
+	// Remove a DateList left behind by a failed test
+	if d, err := datelist.Restore(tableName); err.ID == 0 {
+		d.Delete()
+	}
+	s, sErr := schema.New(map[string]interface{}{
+		"name": []interface{}{"String", "", 32.0, false, true, true},
+		"mmr":  []interface{}{"Uint16", 1500.0, 0.0, 0.0, false, false},
+	}, false)
+	if sErr.ID != 0 {
+		t.Errorf("Error making schema: %v", sErr)
+		return
+	}
+	var tErr helpers.Error
+	if table, tErr = datelist.New(tableName, nil, s, 0, false, false); tErr.ID != 0 {
+		t.Errorf("Error making DateList: %v", tErr)
+		return
+	}
+	setupComplete = true
+}
+
+func TestInsert(t *testing.T) {
+	if !setupComplete {
+		t.Skip()
+	}
+	for i, name := range []string{"a", "b", "c", "d", "e"} {
+		e, err := table.Insert(map[string]interface{}{"name": name, "mmr": 1000 + (i * 100)})
+		if err.ID != 0 {
+			t.Errorf("TestInsert error: %v", err)
+			return
+		}
+		ids[name] = e.ID()
+	}
+	if table.Size() != 5 {
+		t.Errorf("TestInsert expected 5 entries, but got: %v", table.Size())
+	}
+}
+
+func TestInsertDuplicateUniqueValue(t *testing.T) {
+	if !setupComplete {
+		t.Skip()
+	}
+	if _, err := table.Insert(map[string]interface{}{"name": "a"}); err.ID != helpers.ErrorUniqueValueDuplicate {
+		t.Errorf("TestInsertDuplicateUniqueValue expected error %v, but got: %v", helpers.ErrorUniqueValueDuplicate, err)
+	}
+}
+
+func TestGetOrder(t *testing.T) {
+	if !setupComplete {
+		t.Skip()
+	}
+	checkNames(t, "TestGetOrder (asc)", nil, nil, true, 0, 0, "a", "b", "c", "d", "e")
+	checkNames(t, "TestGetOrder (desc)", nil, nil, false, 0, 0, "e", "d", "c", "b", "a")
+}
+
+func TestGetLimitAndPage(t *testing.T) {
+	if !setupComplete {
+		t.Skip()
+	}
+	checkNames(t, "TestGetLimitAndPage", nil, nil, true, 2, 1, "c", "d")
+	checkNames(t, "TestGetLimitAndPage (last page)", nil, nil, false, 2, 2, "a")
+}
+
+func TestGetWhere(t *testing.T) {
+	if !setupComplete {
+		t.Skip()
+	}
+	checkNames(t, "TestGetWhere", nil, map[string]interface{}{"mmr.*gte": []interface{}{1200}}, true, 0, 0, "c", "d", "e")
+}
+
+func TestGetStart(t *testing.T) {
+	if !setupComplete {
+		t.Skip()
+	}
+	future := time.Now().Add(time.Hour)
+	past := time.Now().Add(-time.Hour)
+	checkNames(t, "TestGetStart (future asc)", &future, nil, true, 0, 0)
+	checkNames(t, "TestGetStart (past desc)", &past, nil, false, 0, 0)
+	checkNames(t, "TestGetStart (future desc)", &future, nil, false, 1, 0, "e")
+}
+
+func TestUpdateTime(t *testing.T) {
+	if !setupComplete {
+		t.Skip()
+	}
+	if err := table.SetUpdateTime(true); err != 0 {
+		t.Errorf("TestUpdateTime error setting updateTime: %v", err)
+		return
+	}
+	if err := table.SetUnixNano(true); err != 0 {
+		t.Errorf("TestUpdateTime error setting unixNano: %v", err)
+		return
+	}
+	if err := table.UpdateEntry(ids["a"], map[string]interface{}{"mmr.*add": []interface{}{50}}); err.ID != 0 {
+		t.Errorf("TestUpdateTime error: %v", err)
+		return
+	}
+	// "a" should now be the newest entry
+	checkNames(t, "TestUpdateTime", nil, nil, true, 0, 0, "b", "c", "d", "e", "a")
+}
+
+func TestDeleteEntry(t *testing.T) {
+	if !setupComplete {
+		t.Skip()
+	}
+	if err := table.DeleteEntry(ids["b"]); err.ID != 0 {
+		t.Errorf("TestDeleteEntry error: %v", err)
+		return
+	}
+	if _, err := table.GetEntry(ids["b"]); err != helpers.ErrorNoEntryFound {
+		t.Errorf("TestDeleteEntry expected error %v, but got: %v", helpers.ErrorNoEntryFound, err)
+	}
+	checkNames(t, "TestDeleteEntry", nil, nil, true, 0, 0, "c", "d", "e", "a")
+}
+
+func TestRestore(t *testing.T) {
+	if !setupComplete {
+		t.Skip()
+	}
+	table.Close(true)
+	var err helpers.Error
+	if table, err = datelist.Restore(tableName); err.ID != 0 {
+		t.Errorf("TestRestore error: %v", err)
+		setupComplete = false
+		return
+	}
+	if !table.UpdateTime() || !table.UnixNano() {
+		t.Errorf("TestRestore expected updateTime and unixNano settings to be restored")
+	}
+	checkNames(t, "TestRestore", nil, nil, true, 0, 0, "c", "d", "e", "a")
+	res, gErr := table.Get(nil, []string{"mmr"}, map[string]interface{}{"name.*eq": []interface{}{"a"}}, true, 0, 0)
+	if gErr.ID != 0 || len(res) != 1 {
+		t.Errorf("TestRestore error getting 'a': %v %v", res, gErr)
+	} else if res[0].Data["mmr"] != uint16(1050) {
+		t.Errorf("TestRestore expected mmr 1050 for 'a', but got: %v", res[0].Data["mmr"])
+	}
+	// Inserting after restore must not reuse IDs
+	e, iErr := table.Insert(map[string]interface{}{"name": "f"})
+	if iErr.ID != 0 {
+		t.Errorf("TestRestore insert error: %v", iErr)
+	} else if e.ID() <= ids["e"] {
+		t.Errorf("TestRestore expected a new ID after %v, but got: %v", ids["e"], e.ID())
+	}
+}
+
+func TestDelete(t *testing.T) {
+	if !setupComplete {
+		t.Skip()
+	}
+	if err := table.Delete(); err != 0 {
+		t.Errorf("TestDelete error: %v", err)
+	}
+	storage.ShutDown()
+}
+
+// Checks the "name" of every entry in the results of a Get query
+func checkNames(t *testing.T, test string, start *time.Time, where map[string]interface{}, asc bool, limit int, page int, names ...string) {
+	res, err := table.Get(start, []string{"name"}, where, asc, limit, page)
+	if err.ID != 0 {
+		t.Errorf("%v error: %v", test, err)
+		return
+	}
+	got := make([]interface{}, len(res))
+	for i, r := range res {
+		got[i] = r.Data["name"]
+	}
+	if len(got) != len(names) {
+		t.Errorf("%v expected %v, but got: %v", test, names, got)
+		return
+	}
+	for i := range names {
+		if got[i] != names[i] {
+			t.Errorf("%v expected %v, but got: %v", test, names, got)
+			return
+		}
+	}
+}
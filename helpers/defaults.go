@@ -2,12 +2,35 @@ package helpers
 
 const (
 	// Shared table defaults
-	DefaultPartitionMax uint16 = 250
-	PartitionMin uint16        = 1
+	DefaultPartitionMax uint32 = 250
+	PartitionMin uint32        = 1
 	DefaultMaxEntries uint64   = 0
 	DefaultEncryptCost int     = 4
 	EncryptCostMax int         = 31
 	EncryptCostMin int         = 4
+	DefaultSyncPolicy uint8    = SyncPolicyInterval
+	DefaultCompactRatio float64 = 0.5
+)
+
+// Storage sync policies
+const (
+	SyncPolicyNever    uint8 = iota // Leave flushing writes to the disk up to the OS
+	SyncPolicyInterval              // Flush writes to the disk on an interval
+	SyncPolicyAlways                // Flush every write to the disk before it returns
+)
+
+// FormatVersion is the version of the table config and data file format written by this build. Configs without a
+// FormatVersion are version 1, which addressed data file lines with 16 bits. Version 2 data files are the same
+// as version 1, but lines are addressed with 32 bits so PartitionMax can be over 65535. Version 3 data file lines
+// are padded with spaces so they can be updated in place, and a line that outgrows it's padding is moved to the
+// end of the data lines, so lines are no longer in order. Version 4 data file lines start with a checksum. Version 5
+// data file lines can hold any bytes, and tables can store their entries as binary records.
+const FormatVersion uint8 = 5
+
+// Table data encodings
+const (
+	EncodingJSON   = "json"   // Entries are stored as JSON
+	EncodingBinary = "binary" // Entries are stored as binary records made with the table's schema
 )
 
 // File types
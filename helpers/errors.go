@@ -17,6 +17,7 @@ const (
 	ErrorTableFull
 	ErrorQueryInvalidFormat
 	ErrorNoEntryFound
+	ErrorTableInconsistent // A transaction left the table's data files out of line with it's entries - it takes no writes until it's restored
 )
 
 const (
@@ -52,6 +53,7 @@ const (
 	ErrorInvalidTimeFormat
 	ErrorUniqueValueDuplicate
 	ErrorRestoreItemSchema
+	ErrorInvalidWhereItem
 )
 
 const (
@@ -78,6 +80,20 @@ const (
 	ErrorLeaderboardDoesntExist
 )
 
+const (
+	// Server errors
+	ErrorNotAuthenticated = 6001 + iota
+	ErrorNoPrivileges
+	ErrorRateLimited
+	ErrorTooManyConnections
+)
+
+const (
+	// Client errors
+	ErrorClientConnection = 7001 + iota
+	ErrorClientResponse
+)
+
 const (
 	// Storage errors
 	ErrorStorageNotInitialized = 9001 + iota
@@ -94,6 +110,12 @@ const (
 	ErrorJsonDataFormat
 	ErrorJsonIndexingFormat
 	ErrorInternalFormatting
+	ErrorFormatVersion
+	ErrorChecksumMismatch
+	ErrorInvalidEngine
+	ErrorInvalidEncoding
+	ErrorBinaryEncoding
+	ErrorBinaryDecoding
 )
 
 // NewError creates a new Error message with given ID and From message
@@ -49,13 +49,14 @@ func InitLogger(prio int, maxSize int) error {
 	if logFile == nil {
 		now := time.Now()
 		var today string = strconv.Itoa(now.Day()) + "-" + strconv.Itoa(int(now.Month())) + "-" + strconv.Itoa(now.Year())
+		logsPath := DataPath(logsFolder)
 		// Check if log folder exists
-		if _, err := os.Stat(logsFolder); os.IsNotExist(err) {
+		if _, err := os.Stat(logsPath); os.IsNotExist(err) {
 			// Create logs folder
-			os.MkdirAll(logsFolder, os.ModePerm)
+			os.MkdirAll(logsPath, os.ModePerm)
 		} else {
 			// Open log folder
-			df, err := os.Open(logsFolder)
+			df, err := os.Open(logsPath)
 			if err != nil {
 				logMux.Unlock()
 				return err
@@ -84,7 +85,7 @@ func InitLogger(prio int, maxSize int) error {
 		}
 		// Create new log file
 		var err error
-		if logFile, err = os.OpenFile(logsFolder + "/" + today + " (" + strconv.Itoa(logNum) + ")" + FileTypeLog, os.O_RDWR | os.O_CREATE, 0755); err != nil {
+		if logFile, err = os.OpenFile(logsPath + "/" + today + " (" + strconv.Itoa(logNum) + ")" + FileTypeLog, os.O_RDWR | os.O_CREATE, 0755); err != nil {
 			logMux.Unlock()
 			return err
 		}
@@ -117,7 +118,7 @@ func Log(in string, priority int) bool {
 		logNum++
 		// Create new log file
 		var err error
-		if logFile, err = os.OpenFile(logsFolder + "/" + today + " (" + strconv.Itoa(logNum) + ")" + FileTypeLog, os.O_RDWR | os.O_CREATE, 0755); err != nil {
+		if logFile, err = os.OpenFile(DataPath(logsFolder) + "/" + today + " (" + strconv.Itoa(logNum) + ")" + FileTypeLog, os.O_RDWR | os.O_CREATE, 0755); err != nil {
 			logMux.Unlock()
 			return false
 		}
@@ -0,0 +1,42 @@
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+	"sync/atomic"
+)
+
+var (
+	// Root folder of every table and log file. Empty is the working directory.
+	dataDirectory atomic.Value // string
+)
+
+// SetDataDirectory sets the root folder that table and log paths are resolved under, and makes the folder if it
+// doesn't exist. An empty dir is the working directory.
+func SetDataDirectory(dir string) int {
+	if dir != "" {
+		dir = filepath.Clean(dir)
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			return ErrorCreatingFolder
+		}
+	}
+	dataDirectory.Store(dir)
+	return 0
+}
+
+// DataDirectory gets the root folder that table and log paths are resolved under.
+func DataDirectory() string {
+	if dir, ok := dataDirectory.Load().(string); ok {
+		return dir
+	}
+	return ""
+}
+
+// DataPath gets the path of a file or folder in the data directory.
+func DataPath(name string) string {
+	dir := DataDirectory()
+	if dir == "" {
+		return name
+	}
+	return filepath.Join(dir, name)
+}
@@ -1,30 +1,76 @@
 package keystore
 
 import (
-	"encoding/json"
+	"encoding/binary"
 	"github.com/hewiefreeman/GopherDB/helpers"
 	"github.com/hewiefreeman/GopherDB/schema"
-	"github.com/hewiefreeman/GopherDB/storage"
-	"strconv"
 	"strings"
+	"time"
 )
 
 type jsonEntry struct {
 	K string
 	D []interface{}
+	X int64 `json:",omitempty"` // unix nano time the entry expires - 0 never expires
 }
 
-func makeJsonBytes(key string, data []interface{}, jBytes *[]byte) int {
+func makeJsonBytes(key string, data []interface{}, expires int64, jBytes *[]byte) int {
 	var jErr error
 	if *jBytes, jErr = helpers.Fjson.Marshal(jsonEntry{
 		K: key,
 		D: data,
+		X: expires,
 	}); jErr != nil {
 		return helpers.ErrorJsonEncoding
 	}
 	return 0
 }
 
+// Makes the bytes stored for an entry in the Keystore's encoding. Binary records of entries that expire end with the
+// expiry time as a varint.
+func (k *Keystore) makeEntryBytes(key string, data []interface{}, expires int64, eBytes *[]byte) int {
+	if k.encoding != helpers.EncodingBinary {
+		return makeJsonBytes(key, data, expires, eBytes)
+	}
+	var err int
+	*eBytes, err = k.schema.AppendBinary(schema.AppendBinaryString(nil, key), data)
+	if err == 0 && expires != 0 {
+		var x [binary.MaxVarintLen64]byte
+		*eBytes = append(*eBytes, x[:binary.PutVarint(x[:], expires)]...)
+	}
+	return err
+}
+
+// Reads the key, data and expiry time of an entry from it's stored bytes. Data from binary records already has the
+// types the Keystore keeps in memory.
+func (k *Keystore) readEntryBytes(b []byte) (string, []interface{}, int64, int) {
+	if k.encoding != helpers.EncodingBinary {
+		key, data, expires := restoreDataLine(b)
+		if data == nil {
+			return "", nil, 0, helpers.ErrorJsonDecoding
+		}
+		return key, data, expires, 0
+	}
+	key, rest, err := schema.ReadBinaryString(b)
+	if err != 0 {
+		return "", nil, 0, err
+	}
+	data, rest, err := k.schema.ReadBinary(rest)
+	if err != 0 {
+		return "", nil, 0, err
+	} else if key == "" {
+		return "", nil, 0, helpers.ErrorBinaryDecoding
+	}
+	var expires int64
+	if len(rest) > 0 {
+		var n int
+		if expires, n = binary.Varint(rest); n != len(rest) {
+			return "", nil, 0, helpers.ErrorBinaryDecoding
+		}
+	}
+	return key, data, expires, 0
+}
+
 // Examples of nested Get queries
 /*
 
@@ -35,21 +81,30 @@ func makeJsonBytes(key string, data []interface{}, jBytes *[]byte) int {
 
 // Example JSON for new key query:
 //
-//     ["Insert", "tableName", "key", { *items that match schema* }]
+//     ["Insert", "tableName", "key", { *items that match schema* }, ttl /* optional */]
 //
 
 // Insert creates a new keystoreEntry in the Keystore, as long as one doesnt already exist
 func (k *Keystore) InsertKey(key string, insertObj map[string]interface{}) (*keystoreEntry, helpers.Error) {
+	return k.InsertKeyTTL(key, insertObj, 0)
+}
+
+// InsertKeyTTL creates a new keystoreEntry in the Keystore that expires after ttl, as long as one doesnt already
+// exist. A ttl of 0 never expires.
+func (k *Keystore) InsertKeyTTL(key string, insertObj map[string]interface{}, ttl time.Duration) (*keystoreEntry, helpers.Error) {
 	// Key is required
 	if len(key) == 0 {
 		return nil, helpers.NewError(helpers.ErrorKeyRequired, "")
 	} else if strings.ContainsAny(key, ".*\t\n\r") {
 		return nil, helpers.NewError(helpers.ErrorInvalidKeyCharacters, key)
+	} else if ttl < 0 {
+		return nil, helpers.NewError(helpers.ErrorQueryInvalidFormat, key)
 	}
 
 	// Create entry
 	e := keystoreEntry{
-		data: make([]interface{}, len(k.schema), len(k.schema)),
+		data:    make([]interface{}, len(k.schema), len(k.schema)),
+		expires: expiresAt(ttl),
 	}
 
 	uniqueVals := make(map[string]interface{})
@@ -63,22 +118,33 @@ func (k *Keystore) InsertKey(key string, insertObj map[string]interface{}) (*key
 		}
 	}
 
-	// Make JSON []byte for entry
+	indexVals := k.indexValues(e.data)
+
+	// Make []byte for entry
 	var jBytes []byte
 	if !k.memOnly {
-		if jErr := makeJsonBytes(key, e.data, &jBytes); jErr != 0 {
+		if jErr := k.makeEntryBytes(key, e.data, e.expires, &jBytes); jErr != 0 {
 			return nil, helpers.NewError(jErr, key)
 		}
 	}
 
+	// An expired entry with the same key is deleted first
+	k.deleteIfExpired(key)
+
 	// Lock table, check for duplicate entry
 	maxEntries := k.maxEntries.Load().(uint64)
+	k.pMux.RLock()
+	defer k.pMux.RUnlock()
 	k.eMux.Lock()
-	if k.entries[key] != nil {
+	if err := k.writable(); err != 0 {
+		k.eMux.Unlock()
+		return nil, helpers.NewError(err, k.name)
+	} else if k.entries[key] != nil || k.pending[key] {
 		k.eMux.Unlock()
 		return nil, helpers.NewError(helpers.ErrorKeyInUse, key)
-	} else if maxEntries > 0 && len(k.entries) >= int(maxEntries) {
+	} else if maxEntries > 0 && len(k.entries)+len(k.pending) >= int(maxEntries) {
 		// Table is full
+		k.eMux.Unlock()
 		return nil, helpers.NewError(helpers.ErrorTableFull, "")
 	}
 	k.uMux.Lock()
@@ -93,10 +159,10 @@ func (k *Keystore) InsertKey(key string, insertObj map[string]interface{}) (*key
 		}*/
 	}
 	// Append jBytes to fileOn and get the persistIndex
-	var lineOn uint16
+	var lineOn uint32
 	if !k.memOnly {
 		var aErr int
-		lineOn, aErr = storage.Insert(dataFolderPrefix+k.name+"/"+strconv.Itoa(int(k.fileOn))+helpers.FileTypeStorage, jBytes)
+		lineOn, aErr = k.engine.Insert(k.fileOn, jBytes)
 		if aErr != 0 {
 			k.uMux.Unlock()
 			k.eMux.Unlock()
@@ -111,6 +177,7 @@ func (k *Keystore) InsertKey(key string, insertObj map[string]interface{}) (*key
 		}
 		k.uniqueVals[itemName][itemVal] = true
 	}
+	k.addToIndexes(key, indexVals)
 	k.uMux.Unlock()
 
 	//
@@ -118,18 +185,9 @@ func (k *Keystore) InsertKey(key string, insertObj map[string]interface{}) (*key
 	e.persistFile = k.fileOn
 
 	// Increase fileOn when the index has reached or surpassed partitionMax
-	if e.persistIndex >= k.partitionMax.Load().(uint16) {
+	if e.persistIndex >= k.partitionMax.Load().(uint32) {
 		k.fileOn++
-		writeConfigFile(k.configFile, keystoreConfig{
-			Name:         k.name,
-			Schema:       k.schema.MakeConfig(),
-			FileOn:       k.fileOn,
-			DataOnDrive:  k.dataOnDrive,
-			MemOnly:      k.memOnly,
-			PartitionMax: k.partitionMax.Load().(uint16),
-			EncryptCost:  k.encryptCost.Load().(int),
-			MaxEntries:   k.maxEntries.Load().(uint64),
-		})
+		writeConfigFile(k.configFile, k.makeDefaultConfig(k.fileOn))
 	}
 
 	// Remove data from memory if dataOnDrive is true
@@ -139,6 +197,8 @@ func (k *Keystore) InsertKey(key string, insertObj map[string]interface{}) (*key
 
 	// Insert item
 	k.entries[key] = &e
+	k.addKey(key)
+	k.scheduleExpiry(key, &e)
 	k.eMux.Unlock()
 
 	return &e, helpers.Error{}
@@ -157,20 +217,31 @@ func (k *Keystore) GetKey(key string, items map[string]interface{}) (map[string]
 		return nil, helpers.NewError(err, "")
 	}
 
-	var data []interface{}
-
 	// Get entry data
+	data, err := k.entryData(e)
+	if err != 0 {
+		return nil, helpers.NewError(err, "")
+	}
+
+	return k.getItems(data, items)
+}
+
+// Gets a copy of an entry's data from memory or disk
+func (k *Keystore) entryData(e *keystoreEntry) ([]interface{}, int) {
 	if k.dataOnDrive {
-		data, err = k.dataFromDrive(dataFolderPrefix+k.name+"/"+strconv.Itoa(int(e.persistFile))+helpers.FileTypeStorage, e.persistIndex)
-		if err != 0 {
-			return nil, helpers.NewError(err, "")
-		}
-	} else {
-		e.mux.Lock()
-		data = append([]interface{}{}, e.data...)
-		e.mux.Unlock()
+		k.pMux.RLock()
+		data, err := k.dataFromDrive(e.persistFile, e.persistIndex)
+		k.pMux.RUnlock()
+		return data, err
 	}
+	e.mux.Lock()
+	data := append([]interface{}{}, e.data...)
+	e.mux.Unlock()
+	return data, 0
+}
 
+// Filters the items of a get query from an entry's data. Gets every item when items is empty.
+func (k *Keystore) getItems(data []interface{}, items map[string]interface{}) (map[string]interface{}, helpers.Error) {
 	// Check for specific items to get
 	if items != nil && len(items) > 0 {
 		for itemName, methodParams := range items {
@@ -182,7 +253,7 @@ func (k *Keystore) GetKey(key string, items map[string]interface{}) (map[string]
 			}
 			// Item filter
 			var i interface{}
-			err = schema.ItemFilter(methodParams, itemMethods, &i, data[si.DataIndex()], si, nil, k.EncryptCost(), true, false)
+			err := schema.ItemFilter(methodParams, itemMethods, &i, data[si.DataIndex()], si, nil, k.EncryptCost(), true, false)
 			if err != 0 {
 				return nil, helpers.NewError(err, itemName)
 			}
@@ -193,7 +264,7 @@ func (k *Keystore) GetKey(key string, items map[string]interface{}) (map[string]
 		for itemName, si := range k.schema {
 			// Item filter
 			var i interface{}
-			err = schema.ItemFilter(nil, nil, &i, data[si.DataIndex()], si, nil, k.EncryptCost(), true, false)
+			err := schema.ItemFilter(nil, nil, &i, data[si.DataIndex()], si, nil, k.EncryptCost(), true, false)
 			if err != 0 {
 				return nil, helpers.NewError(err, itemName)
 			}
@@ -203,21 +274,14 @@ func (k *Keystore) GetKey(key string, items map[string]interface{}) (map[string]
 	return items, helpers.Error{}
 }
 
-func (k *Keystore) dataFromDrive(file string, index uint16) ([]interface{}, int) {
-	// Read bytes from file
-	bytes, rErr := storage.Read(file, index)
+func (k *Keystore) dataFromDrive(partition uint32, index uint32) ([]interface{}, int) {
+	// Read bytes from storage
+	bytes, rErr := k.engine.Read(partition, index)
 	if rErr != 0 {
 		return nil, rErr
 	}
-	var jEntry jsonEntry
-	jErr := json.Unmarshal(bytes, &jEntry)
-	if jErr != nil {
-		return nil, helpers.ErrorJsonDecoding
-	}
-	if jEntry.D == nil || len(jEntry.D) == 0 {
-		return nil, helpers.ErrorJsonDecoding
-	}
-	return jEntry.D, 0
+	_, data, _, dErr := k.readEntryBytes(bytes)
+	return data, dErr
 }
 
 // Example JSON for update query:
@@ -255,6 +319,11 @@ func (k *Keystore) dataFromDrive(file string, index uint16) ([]interface{}, int)
 
 // Update
 func (k *Keystore) UpdateKey(key string, updateObj map[string]interface{}) helpers.Error {
+	return k.updateKey(key, updateObj, 0)
+}
+
+// Updates an entry, and sets it to expire after ttl when ttl isn't 0
+func (k *Keystore) updateKey(key string, updateObj map[string]interface{}, ttl time.Duration) helpers.Error {
 	if updateObj == nil || len(updateObj) == 0 {
 		return helpers.NewError(helpers.ErrorQueryInvalidFormat, "")
 	}
@@ -264,11 +333,15 @@ func (k *Keystore) UpdateKey(key string, updateObj map[string]interface{}) helpe
 		return helpers.NewError(err, "")
 	}
 
+	// Keep the entry in it's line until the update is done
+	k.pMux.RLock()
+	defer k.pMux.RUnlock()
+
 	var data []interface{}
 
 	// Get entry data
 	if k.dataOnDrive {
-		data, err = k.dataFromDrive(dataFolderPrefix+k.name+"/"+strconv.Itoa(int(e.persistFile))+helpers.FileTypeStorage, e.persistIndex)
+		data, err = k.dataFromDrive(e.persistFile, e.persistIndex)
 		if err != 0 {
 			return helpers.NewError(err, "")
 		}
@@ -277,9 +350,23 @@ func (k *Keystore) UpdateKey(key string, updateObj map[string]interface{}) helpe
 		e.mux.Lock()
 		data = append([]interface{}{}, e.data...)
 	}
+	if e.deleted {
+		e.mux.Unlock()
+		return helpers.NewError(helpers.ErrorNoEntryFound, "")
+	} else if err = k.writable(); err != 0 {
+		e.mux.Unlock()
+		return helpers.NewError(err, k.name)
+	}
+
+	// Keep the entry's expiry time unless there's a new ttl
+	expires := e.expires
+	if ttl != 0 {
+		expires = expiresAt(ttl)
+	}
 
 	uniqueVals := make(map[string]interface{})
 	uniqueValsBefore := make(map[string]interface{})
+	indexValsBefore := k.indexValues(data)
 	// Iterate through updateObj
 	for updateName, updateItem := range updateObj {
 		var itemMethods []string
@@ -306,11 +393,13 @@ func (k *Keystore) UpdateKey(key string, updateObj map[string]interface{}) helpe
 			uniqueValsBefore[uName] = itemBefore
 		}
 	}
+	indexVals := k.indexValues(data)
 
-	// Make JSON []byte for entry
+	// Make []byte for entry
 	var jBytes []byte
 	if !k.memOnly {
-		if jErr := makeJsonBytes(key, data, &jBytes); jErr != 0 {
+		if jErr := k.makeEntryBytes(key, data, expires, &jBytes); jErr != 0 {
+			e.mux.Unlock()
 			return helpers.NewError(jErr, "")
 		}
 	}
@@ -328,7 +417,7 @@ func (k *Keystore) UpdateKey(key string, updateObj map[string]interface{}) helpe
 
 	// Update entry on disk with jBytes
 	if !k.memOnly {
-		err = storage.Update(dataFolderPrefix+k.name+"/"+strconv.Itoa(int(e.persistFile))+helpers.FileTypeStorage, e.persistIndex, jBytes)
+		err = k.engine.Update(e.persistFile, e.persistIndex, jBytes)
 		if err != 0 {
 			k.uMux.Unlock()
 			e.mux.Unlock()
@@ -348,12 +437,19 @@ func (k *Keystore) UpdateKey(key string, updateObj map[string]interface{}) helpe
 			delete(k.uniqueVals[itemName], uniqueValsBefore[itemName])
 		}
 	}
+
+	// Move entry to it's new index values
+	k.removeFromIndexes(key, indexValsBefore)
+	k.addToIndexes(key, indexVals)
 	k.uMux.Unlock()
 
 	//
 	if !k.dataOnDrive {
 		e.data = data
 	}
+	if expires != e.expires {
+		k.setExpiry(key, e, expires)
+	}
 	e.mux.Unlock()
 
 	return helpers.Error{}
@@ -361,17 +457,25 @@ func (k *Keystore) UpdateKey(key string, updateObj map[string]interface{}) helpe
 
 // UpsertKey
 func (k *Keystore) UpsertKey(key string, upsertObj map[string]interface{}) (*keystoreEntry, helpers.Error) {
+	return k.UpsertKeyTTL(key, upsertObj, 0)
+}
+
+// UpsertKeyTTL inserts or updates an entry, and sets it to expire after ttl. An update with a ttl of 0 keeps the
+// entry's expiry time, and an insert with a ttl of 0 never expires.
+func (k *Keystore) UpsertKeyTTL(key string, upsertObj map[string]interface{}, ttl time.Duration) (*keystoreEntry, helpers.Error) {
 	// Key is required
 	if len(key) == 0 {
 		return nil, helpers.NewError(helpers.ErrorKeyRequired, "")
+	} else if ttl < 0 {
+		return nil, helpers.NewError(helpers.ErrorQueryInvalidFormat, key)
 	}
 
 	ke, err := k.Get(key)
 	if err != 0 {
 		// Insert
-		return k.InsertKey(key, upsertObj)
+		return k.InsertKeyTTL(key, upsertObj, ttl)
 	}
-	return ke, k.UpdateKey(key, upsertObj)
+	return ke, k.updateKey(key, upsertObj, ttl)
 }
 
 // Delete
@@ -380,12 +484,23 @@ func (k *Keystore) DeleteKey(key string) helpers.Error {
 	if err != 0 {
 		return helpers.NewError(err, "")
 	}
+	return k.deleteEntry(key, ue, false)
+}
+
+// Deletes an entry, with it's unique values, index values and line on disk. When onlyExpired is true, the entry is
+// only deleted if it has expired.
+func (k *Keystore) deleteEntry(key string, ue *keystoreEntry, onlyExpired bool) helpers.Error {
+	var err int
+
+	// Keep the entry in it's line until the delete is done
+	k.pMux.RLock()
+	defer k.pMux.RUnlock()
 
 	var data []interface{}
 
 	// Get entry data
 	if k.dataOnDrive {
-		data, err = k.dataFromDrive(dataFolderPrefix+k.name+"/"+strconv.Itoa(int(ue.persistFile))+helpers.FileTypeStorage, ue.persistIndex)
+		data, err = k.dataFromDrive(ue.persistFile, ue.persistIndex)
 		if err != 0 {
 			return helpers.NewError(err, "")
 		}
@@ -394,52 +509,78 @@ func (k *Keystore) DeleteKey(key string) helpers.Error {
 		ue.mux.Lock()
 		data = append([]interface{}{}, ue.data...)
 	}
+	// Already deleted, or refreshed since it expired
+	if ue.deleted || (onlyExpired && !ue.expired(time.Now().UnixNano())) {
+		ue.mux.Unlock()
+		return helpers.NewError(helpers.ErrorNoEntryFound, "")
+	} else if err = k.writable(); err != 0 {
+		ue.mux.Unlock()
+		return helpers.NewError(err, k.name)
+	}
 
-	k.uMux.Lock()
-	uItems := []string{}
-	schema.GetUniqueItems(k.schema, &uItems, "")
-	for _, itemName := range uItems {
-		// Get entry's unique value for this unique item
-		siName, itemMethods := schema.GetQueryItemMethods(itemName)
-		//
-		si := k.schema[siName]
-		if !si.QuickValidate() {
-			ue.mux.Unlock()
-			k.uMux.Unlock()
-			return helpers.NewError(helpers.ErrorUnexpected, "")
-		}
-		// Make get filter
-		var i interface{}
-		err := schema.ItemFilter(nil, itemMethods, &i, data[si.DataIndex()], si, nil, k.EncryptCost(), true, false)
-		if err != 0 {
-			ue.mux.Unlock()
-			k.uMux.Unlock()
-			return helpers.NewError(helpers.ErrorUnexpected, "")
-		}
-		delete(k.uniqueVals[itemName], i)
+	// Get entry's unique values
+	uniqueVals, uErr := k.uniqueValues(data)
+	if uErr != 0 {
+		ue.mux.Unlock()
+		return helpers.NewError(helpers.ErrorUnexpected, "")
 	}
-	ue.mux.Unlock()
-	k.uMux.Unlock()
 
-	// Update entry on disk with []byte{}
+	// Update entry on disk with []byte{} - the entry is kept whole if the write fails
 	if !k.memOnly {
-		err = storage.Update(dataFolderPrefix+k.name+"/"+strconv.Itoa(int(ue.persistFile))+helpers.FileTypeStorage, ue.persistIndex, []byte{})
+		err = k.engine.Update(ue.persistFile, ue.persistIndex, []byte{})
 		if err != 0 {
+			ue.mux.Unlock()
 			return helpers.NewError(err, "")
 		}
 	}
+	ue.deleted = true
+
+	k.uMux.Lock()
+	for itemName, i := range uniqueVals {
+		delete(k.uniqueVals[itemName], i)
+	}
+	k.removeFromIndexes(key, k.indexValues(data))
+	k.uMux.Unlock()
+	ue.mux.Unlock()
 
 	k.eMux.Lock()
 	// Delete entry
 	delete(k.entries, key)
+	k.removeKey(key)
+	k.removeExpiry(ue)
 	k.eMux.Unlock()
 
+	// Count the deleted line towards compaction
+	if !k.memOnly {
+		k.addDeadLine(ue.persistFile)
+	}
+
 	//
 	return helpers.Error{}
 }
 
+// Gets the unique values of an entry's data by item name, as they are kept in the Keystore's uniqueVals
+func (k *Keystore) uniqueValues(data []interface{}) (map[string]interface{}, int) {
+	uItems := []string{}
+	schema.GetUniqueItems(k.schema, &uItems, "")
+	vals := make(map[string]interface{}, len(uItems))
+	for _, itemName := range uItems {
+		siName, itemMethods := schema.GetQueryItemMethods(itemName)
+		si := k.schema[siName]
+		if !si.QuickValidate() {
+			return nil, helpers.ErrorUnexpected
+		}
+		var i interface{}
+		if err := schema.ItemFilter(nil, itemMethods, &i, data[si.DataIndex()], si, nil, k.EncryptCost(), true, false); err != 0 {
+			return nil, err
+		}
+		vals[itemName] = i
+	}
+	return vals, 0
+}
+
 // Restores a key from a config file - NOT concurrently safe on it's own! Must lock Keystore before-hand.
-func (k *Keystore) restoreKey(key string, data []interface{}, fileOn uint32, lineOn uint16) int {
+func (k *Keystore) restoreKey(key string, data []interface{}, expires int64, fileOn uint32, lineOn uint32) int {
 	// Check for duplicate entry
 	if k.entries[key] != nil {
 		return helpers.ErrorKeyInUse
@@ -447,21 +588,31 @@ func (k *Keystore) restoreKey(key string, data []interface{}, fileOn uint32, lin
 
 	// Create entry
 	e := keystoreEntry{
-		data: make([]interface{}, len(k.schema), len(k.schema)),
+		data:    make([]interface{}, len(k.schema), len(k.schema)),
+		expires: expires,
 	}
 
 	uniqueVals := make(map[string]interface{})
 
-	// Fill entry data with data
-	for _, schemaItem := range k.schema {
-		if int(schemaItem.DataIndex()) > len(data)-1 {
+	if k.encoding == helpers.EncodingBinary {
+		// Binary data already has the right types - only get it's unique values
+		if len(data) != len(k.schema) {
 			return helpers.ErrorRestoreItemSchema
 		}
+		e.data = data
+		schema.GetUniqueValues(k.schema, data, &uniqueVals, "")
+	} else {
+		// Fill entry data with data
+		for _, schemaItem := range k.schema {
+			if int(schemaItem.DataIndex()) > len(data)-1 {
+				return helpers.ErrorRestoreItemSchema
+			}
 
-		// Item filter
-		err := schema.ItemFilter(data[schemaItem.DataIndex()], nil, &e.data[schemaItem.DataIndex()], nil, schemaItem, &uniqueVals, 0, false, true)
-		if err != 0 {
-			return err
+			// Item filter
+			err := schema.ItemFilter(data[schemaItem.DataIndex()], nil, &e.data[schemaItem.DataIndex()], nil, schemaItem, &uniqueVals, 0, false, true)
+			if err != 0 {
+				return err
+			}
 		}
 	}
 
@@ -482,6 +633,7 @@ func (k *Keystore) restoreKey(key string, data []interface{}, fileOn uint32, lin
 		}
 		k.uniqueVals[itemName][itemVal] = true
 	}
+	k.addToIndexes(key, k.indexValues(e.data))
 
 	//
 	e.persistIndex = lineOn
@@ -492,7 +644,11 @@ func (k *Keystore) restoreKey(key string, data []interface{}, fileOn uint32, lin
 		e.data = nil
 	}
 
-	// Insert item
+	// Insert item - Restore orders the keys and expiries once every entry is restored
 	k.entries[key] = &e
+	if expires != 0 {
+		e.expiry = &expiry{key: key, at: expires, index: len(k.expiries)}
+		k.expiries = append(k.expiries, e.expiry)
+	}
 	return 0
 }
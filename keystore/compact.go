@@ -0,0 +1,50 @@
+/*
+keystore package Copyright 2020 Dominique Debergue
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at:
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+either express or implied. See the License for the specific
+language governing permissions and limitations under the License.
+*/
+
+package keystore
+
+// Compaction
+//
+// Deleting an entry leaves an empty line in it's data file. The Keystore's storage.Compactor counts the empty lines
+// of each data file, and compacts a file in the background once it's ratio of empty lines reaches the Keystore's
+// compactRatio. Compact() does the same for every data file.
+
+// Compact rewrites every data file of the Keystore without the lines of deleted entries.
+func (k *Keystore) Compact() int {
+	if k.memOnly {
+		return 0
+	}
+	return k.compactor.Compact()
+}
+
+// Counts a deleted line in a data file, and compacts the file in the background when it's ratio of deleted lines
+// reaches the compactRatio.
+func (k *Keystore) addDeadLine(fileNum uint32) {
+	k.compactor.AddDeadLine(fileNum, k.compactRatio.Load().(float64))
+}
+
+// Moves the entries of a compacted data file to their new lines - called by the compactor with pMux write locked
+func (k *Keystore) moveEntries(fileNum uint32, moved map[uint32]uint32) {
+	k.eMux.Lock()
+	for _, e := range k.entries {
+		if e.persistFile == fileNum {
+			if line, ok := moved[e.persistIndex]; ok {
+				e.persistIndex = line
+			}
+		}
+	}
+	k.eMux.Unlock()
+}
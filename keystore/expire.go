@@ -0,0 +1,244 @@
+/*
+keystore package Copyright 2020 Dominique Debergue
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at:
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+either express or implied. See the License for the specific
+language governing permissions and limitations under the License.
+*/
+
+package keystore
+
+import (
+	"container/heap"
+	"github.com/hewiefreeman/GopherDB/helpers"
+	"strconv"
+	"time"
+)
+
+// Expiration
+//
+// Entries inserted or upserted with a TTL expire once the TTL has passed. An expired entry can't be found right away,
+// and every Keystore has an expirer that deletes it's expired entries every expireInterval, the same way as
+// DeleteKey. The time an entry expires is stored with it's data, so it's kept after a Restore.
+
+const (
+	expireInterval = time.Second // time between each run of a Keystore's expirer
+)
+
+// Example JSON for TTL queries (the TTL is in seconds):
+//
+//     ["Insert", "tableName", "key", { *items that match schema* }, ttl]
+//     ["Upsert", "tableName", "key", { *items that match schema* }, ttl]
+//     ["Refresh", "tableName", "key", ttl] // a TTL of 0 makes the entry never expire
+//
+
+// When an entry expires
+type expiry struct {
+	key   string
+	at    int64 // unix nano time
+	index int   // index in the expiryHeap
+}
+
+// Heap of expiry times, soonest first. Every entry that expires has one expiry in the heap, which is moved in place
+// when the entry is refreshed, and removed when the entry is deleted.
+type expiryHeap []*expiry
+
+func (h expiryHeap) Len() int           { return len(h) }
+func (h expiryHeap) Less(i, j int) bool { return h[i].at < h[j].at }
+func (h expiryHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+func (h *expiryHeap) Push(x interface{}) {
+	x.(*expiry).index = len(*h)
+	*h = append(*h, x.(*expiry))
+}
+func (h *expiryHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	x.index = -1
+	*h = old[:len(old)-1]
+	return x
+}
+
+// Counts the expiries that are due at now, only looking at the due expiries and their children
+func (h expiryHeap) countDue(i int, now int64) int {
+	if i >= len(h) || h[i].at > now {
+		return 0
+	}
+	return 1 + h.countDue(2*i+1, now) + h.countDue(2*i+2, now)
+}
+
+// Gets the unix nano time an entry expires with ttl - 0 when ttl is 0
+func expiresAt(ttl time.Duration) int64 {
+	if ttl == 0 {
+		return 0
+	}
+	return time.Now().Add(ttl).UnixNano()
+}
+
+// Checks if an entry has expired - must lock eMux or the entry before-hand
+func (e *keystoreEntry) expired(now int64) bool {
+	return e.expires != 0 && e.expires <= now
+}
+
+// Sets when an entry expires - must lock the entry before-hand
+func (k *Keystore) setExpiry(key string, e *keystoreEntry, expires int64) {
+	k.eMux.Lock()
+	e.expires = expires
+	if k.entries[key] == e {
+		k.scheduleExpiry(key, e)
+	}
+	k.eMux.Unlock()
+}
+
+// Adds, moves or removes an entry's expiry in the expiryHeap to match when it expires - must lock eMux before-hand
+func (k *Keystore) scheduleExpiry(key string, e *keystoreEntry) {
+	switch {
+	case e.expiry == nil && e.expires != 0:
+		e.expiry = &expiry{key: key, at: e.expires}
+		heap.Push(&k.expiries, e.expiry)
+	case e.expiry != nil && e.expires != 0:
+		e.expiry.at = e.expires
+		heap.Fix(&k.expiries, e.expiry.index)
+	case e.expiry != nil:
+		k.removeExpiry(e)
+	}
+}
+
+// Removes an entry's expiry from the expiryHeap - must lock eMux before-hand
+func (k *Keystore) removeExpiry(e *keystoreEntry) {
+	if e.expiry != nil && e.expiry.index >= 0 {
+		heap.Remove(&k.expiries, e.expiry.index)
+	}
+	e.expiry = nil
+}
+
+// RefreshKey sets an entry to expire after ttl from now. A ttl of 0 makes the entry never expire.
+func (k *Keystore) RefreshKey(key string, ttl time.Duration) helpers.Error {
+	if ttl < 0 {
+		return helpers.NewError(helpers.ErrorQueryInvalidFormat, key)
+	}
+
+	e, err := k.Get(key)
+	if err != 0 {
+		return helpers.NewError(err, key)
+	}
+
+	// Keep the entry in it's line until the refresh is done
+	k.pMux.RLock()
+	defer k.pMux.RUnlock()
+
+	var data []interface{}
+
+	// Get entry data
+	if k.dataOnDrive {
+		data, err = k.dataFromDrive(e.persistFile, e.persistIndex)
+		if err != 0 {
+			return helpers.NewError(err, key)
+		}
+		e.mux.Lock()
+	} else {
+		e.mux.Lock()
+		data = e.data
+	}
+	defer e.mux.Unlock()
+	if e.deleted {
+		return helpers.NewError(helpers.ErrorNoEntryFound, key)
+	} else if err = k.writable(); err != 0 {
+		return helpers.NewError(err, k.name)
+	}
+
+	// Store the new expiry time with the entry's data
+	expires := expiresAt(ttl)
+	if !k.memOnly {
+		var eBytes []byte
+		if err = k.makeEntryBytes(key, data, expires, &eBytes); err != 0 {
+			return helpers.NewError(err, key)
+		}
+		if err = k.engine.Update(e.persistFile, e.persistIndex, eBytes); err != 0 {
+			return helpers.NewError(err, key)
+		}
+	}
+	k.setExpiry(key, e, expires)
+
+	return helpers.Error{}
+}
+
+// DeleteExpired deletes every entry that has expired, and returns how many were deleted. The Keystore's expirer runs
+// it every expireInterval.
+func (k *Keystore) DeleteExpired() int {
+	if k.writable() != 0 {
+		// Expired entries are deleted once the Keystore is restored
+		return 0
+	}
+	var deleted int
+	var failed []*expiry
+	for {
+		now := time.Now().UnixNano()
+		k.eMux.Lock()
+		if len(k.expiries) == 0 || k.expiries[0].at > now {
+			// Failed deletes are tried again on the next run, unless the entry was refreshed or deleted since
+			for _, x := range failed {
+				if e := k.entries[x.key]; e != nil && e.expiry == nil {
+					k.scheduleExpiry(x.key, e)
+				}
+			}
+			k.eMux.Unlock()
+			return deleted
+		}
+		x := heap.Pop(&k.expiries).(*expiry)
+		e := k.entries[x.key]
+		if e == nil || e.expiry != x {
+			k.eMux.Unlock()
+			continue
+		}
+		// A refresh after this point gives the entry a new expiry
+		e.expiry = nil
+		k.eMux.Unlock()
+		if err := k.deleteEntry(x.key, e, true); err.ID == helpers.ErrorNoEntryFound {
+			// Refreshed or deleted since it was popped
+			continue
+		} else if err.ID != 0 {
+			helpers.LogAndPrint("Failed to delete expired key '"+x.key+"' of Keystore '"+k.name+"' with error code: "+strconv.Itoa(err.ID), 4)
+			failed = append(failed, x)
+			continue
+		}
+		deleted++
+	}
+}
+
+// Deletes a key's entry if it has expired, so the key can be inserted again before the expirer gets to it
+func (k *Keystore) deleteIfExpired(key string) {
+	k.eMux.Lock()
+	e := k.entries[key]
+	if e == nil || !e.expired(time.Now().UnixNano()) {
+		k.eMux.Unlock()
+		return
+	}
+	k.eMux.Unlock()
+	k.deleteEntry(key, e, true)
+}
+
+// Runs DeleteExpired every expireInterval until the Keystore is closed
+func (k *Keystore) runExpirer() {
+	ticker := time.NewTicker(expireInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-k.closed:
+			return
+		case <-ticker.C:
+			k.DeleteExpired()
+		}
+	}
+}
@@ -0,0 +1,204 @@
+/*
+keystore package Copyright 2020 Dominique Debergue
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at:
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+either express or implied. See the License for the specific
+language governing permissions and limitations under the License.
+*/
+
+package keystore
+
+import (
+	"github.com/hewiefreeman/GopherDB/schema"
+	"sort"
+)
+
+// Indexes
+//
+// Every indexed item of a Keystore's schema has an itemIndex of the keys of the entries by their value for the
+// item, kept like the table's unique values. A where query item with a single *eq method gets it's entries from a
+// hash or ordered index, and *gt, *gte, *lt and *lte get them from an ordered index. Select queries sorted by an
+// item with an ordered index get their entries in order from the index, with equal values in order of their keys.
+
+type itemIndex struct {
+	item   schema.SchemaItem
+	keys   map[interface{}]map[string]bool // keys of the entries by value
+	values *skipList                       // every value in keys, in order - only for ordered indexes
+}
+
+// Makes an itemIndex for every indexed item of a Schema
+func makeIndexes(s schema.Schema) map[string]*itemIndex {
+	indexes := make(map[string]*itemIndex)
+	for itemName, si := range s {
+		if si.Index() != "" {
+			ix := &itemIndex{item: si, keys: make(map[interface{}]map[string]bool)}
+			if ix.ordered() {
+				ix.values = newSkipList(schema.CompareIndexValues)
+			}
+			indexes[itemName] = ix
+		}
+	}
+	return indexes
+}
+
+func (ix *itemIndex) ordered() bool {
+	return ix.item.Index() == schema.IndexOrdered
+}
+
+func (ix *itemIndex) add(v interface{}, key string) {
+	keys := ix.keys[v]
+	if keys == nil {
+		keys = make(map[string]bool)
+		ix.keys[v] = keys
+		if ix.ordered() {
+			ix.values.insert(v)
+		}
+	}
+	keys[key] = true
+}
+
+func (ix *itemIndex) remove(v interface{}, key string) {
+	keys := ix.keys[v]
+	if keys == nil {
+		return
+	}
+	delete(keys, key)
+	if len(keys) == 0 {
+		delete(ix.keys, v)
+		if ix.ordered() {
+			ix.values.remove(v)
+		}
+	}
+}
+
+// Gets the keys of the entries with a value that compares to v with method. Returns false when the index can't be
+// used with method.
+func (ix *itemIndex) find(method string, v interface{}, found map[string]bool) bool {
+	if method == schema.MethodEquals {
+		for key := range ix.keys[v] {
+			found[key] = true
+		}
+		return true
+	} else if !ix.ordered() {
+		return false
+	}
+	// Walk the values from the first node in range, until done returns true
+	var n *skipNode
+	var done func(value interface{}) bool
+	switch method {
+	case schema.MethodGreater:
+		n = ix.values.seek(v)
+		if n != nil && schema.CompareIndexValues(n.value, v) == 0 {
+			n = n.next[0]
+		}
+		done = func(value interface{}) bool { return false }
+	case schema.MethodGreaterOE:
+		n = ix.values.seek(v)
+		done = func(value interface{}) bool { return false }
+	case schema.MethodLess:
+		n = ix.values.first()
+		done = func(value interface{}) bool { return schema.CompareIndexValues(value, v) >= 0 }
+	case schema.MethodLessOE:
+		n = ix.values.first()
+		done = func(value interface{}) bool { return schema.CompareIndexValues(value, v) > 0 }
+	default:
+		return false
+	}
+	for ; n != nil && !done(n.value); n = n.next[0] {
+		for key := range ix.keys[n.value] {
+			found[key] = true
+		}
+	}
+	return true
+}
+
+// Gets the keys of the entries in order of their values, and in order of their keys for equal values. Only gets
+// the keys in filter when filter isn't nil.
+func (ix *itemIndex) orderedKeys(desc bool, filter map[string]bool) []string {
+	values := make([]interface{}, 0, ix.values.size())
+	for n := ix.values.first(); n != nil; n = n.next[0] {
+		values = append(values, n.value)
+	}
+	var keys []string
+	for i := range values {
+		if desc {
+			i = len(values) - 1 - i
+		}
+		valueKeys := make([]string, 0, len(ix.keys[values[i]]))
+		for key := range ix.keys[values[i]] {
+			if filter == nil || filter[key] {
+				valueKeys = append(valueKeys, key)
+			}
+		}
+		sort.Strings(valueKeys)
+		keys = append(keys, valueKeys...)
+	}
+	return keys
+}
+
+// Gets an entry's values for every index of the Keystore
+func (k *Keystore) indexValues(data []interface{}) map[string]interface{} {
+	vals := make(map[string]interface{}, len(k.indexes))
+	for itemName, ix := range k.indexes {
+		if v, ok := ix.item.IndexValue(data[ix.item.DataIndex()]); ok {
+			vals[itemName] = v
+		}
+	}
+	return vals
+}
+
+// Adds an entry's index values to the Keystore's indexes - must lock uMux before-hand
+func (k *Keystore) addToIndexes(key string, vals map[string]interface{}) {
+	for itemName, v := range vals {
+		k.indexes[itemName].add(v, key)
+	}
+}
+
+// Removes an entry's index values from the Keystore's indexes - must lock uMux before-hand
+func (k *Keystore) removeFromIndexes(key string, vals map[string]interface{}) {
+	for itemName, v := range vals {
+		k.indexes[itemName].remove(v, key)
+	}
+}
+
+// Gets the keys of the entries that can match where from the indexes of where's items. Returns false when none of
+// where's items can use an index. The entries still need to be checked with matchesWhere. Must lock uMux
+// before-hand.
+func (k *Keystore) indexedKeys(where map[string]interface{}) (map[string]bool, bool) {
+	var keys map[string]bool
+	for itemName, methodParams := range where {
+		siName, itemMethods := schema.GetQueryItemMethods(itemName)
+		ix := k.indexes[siName]
+		params, ok := methodParams.([]interface{})
+		if ix == nil || !ok || len(itemMethods) != 1 || len(params) != 1 {
+			continue
+		}
+		v, ok := ix.item.IndexValue(params[0])
+		if !ok {
+			continue
+		}
+		found := make(map[string]bool)
+		if !ix.find(itemMethods[0], v, found) {
+			continue
+		}
+		// Only keep the keys found by every index
+		if keys != nil {
+			for key := range keys {
+				if !found[key] {
+					delete(keys, key)
+				}
+			}
+		} else {
+			keys = found
+		}
+	}
+	return keys, keys != nil
+}
@@ -0,0 +1,57 @@
+/*
+keystore package Copyright 2020 Dominique Debergue
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at:
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+either express or implied. See the License for the specific
+language governing permissions and limitations under the License.
+*/
+
+package keystore
+
+import (
+	"github.com/hewiefreeman/GopherDB/helpers"
+	"strconv"
+)
+
+// CheckIntegrity reads every line of the Keystore's data, and reports the lines that can't be read, don't
+// match their checksum, or aren't a valid entry.
+func (k *Keystore) CheckIntegrity() []helpers.Error {
+	errs := []helpers.Error{}
+	if k.memOnly {
+		return errs
+	}
+	k.pMux.RLock()
+	defer k.pMux.RUnlock()
+	partitions, err := k.engine.Partitions()
+	if err != 0 {
+		return append(errs, helpers.NewError(err, "Keystore '"+k.name+"'"))
+	}
+	for _, i := range partitions {
+		lines, err := k.engine.Lines(i)
+		if err != 0 {
+			errs = append(errs, helpers.NewError(err, "Keystore '"+k.name+"' partition "+strconv.Itoa(int(i))))
+			continue
+		}
+		for l := 1; l <= lines; l++ {
+			from := "Keystore '" + k.name + "' partition " + strconv.Itoa(int(i)) + " line " + strconv.Itoa(l)
+			b, err := k.engine.Read(i, uint32(l))
+			if err != 0 {
+				errs = append(errs, helpers.NewError(err, from))
+			} else if len(b) == 0 {
+				// Deleted entry
+				continue
+			} else if _, _, _, err := k.readEntryBytes(b); err != 0 {
+				errs = append(errs, helpers.NewError(err, from))
+			}
+		}
+	}
+	return errs
+}
@@ -0,0 +1,164 @@
+/*
+keystore package Copyright 2020 Dominique Debergue
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at:
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+either express or implied. See the License for the specific
+language governing permissions and limitations under the License.
+*/
+
+package keystore
+
+import (
+	"github.com/hewiefreeman/GopherDB/helpers"
+	"sort"
+	"time"
+)
+
+// Keys
+//
+// A Keystore keeps the keys of it's entries in order in a skipList next to the entries map, so they can be iterated
+// and scanned by prefix or by range. Scans are split into pages with a cursor, which is the key the next page starts from.
+
+const (
+	iterateBatch = 100 // keys copied at a time by Iterate
+)
+
+// KeyPage is a page of keys from a key scan. Cursor is the key the next page starts from - empty when there are no
+// more keys.
+type KeyPage struct {
+	Keys   []string
+	Cursor string
+}
+
+// Example JSON for key scan queries:
+//
+//     ["Scan", "tableName", "prefix*", cursor /* optional */, limit /* optional */]
+//     ["Range", "tableName", start, end, cursor /* optional */, limit /* optional */]
+//
+//  Get the first 50 keys that start with "player:123:":
+//     ["Scan", "players", "player:123:*", "", 50]
+//
+//  Get the next 50 with the Cursor from the first page:
+//     ["Scan", "players", "player:123:*", "player:123:fox", 50]
+//
+//  Get every key from "a" up to, but not including, "n" (an empty end has no upper bound):
+//     ["Range", "players", "a", "n"]
+
+// Iterate calls fn with every key from start up to, but not including, end in order, until fn returns false. An
+// empty end has no upper bound, and keys of expired entries are skipped. The Keystore isn't locked while fn runs,
+// so keys inserted or deleted during the iteration may or may not be seen.
+func (k *Keystore) Iterate(start string, end string, fn func(key string) bool) {
+	batch := make([]string, 0, iterateBatch)
+	from := start
+	for {
+		// Copy the next batch of keys
+		k.eMux.Lock()
+		now := time.Now().UnixNano()
+		n := k.keys.seek(from)
+		batch = batch[:0]
+		var last string
+		for ; n != nil && len(batch) < iterateBatch && (end == "" || n.value.(string) < end); n = n.next[0] {
+			last = n.value.(string)
+			// Expired entries are hidden the same way as from Get
+			if e := k.entries[last]; e != nil && e.expired(now) {
+				continue
+			}
+			batch = append(batch, last)
+		}
+		more := n != nil && (end == "" || n.value.(string) < end)
+		k.eMux.Unlock()
+		for _, key := range batch {
+			if !fn(key) {
+				return
+			}
+		}
+		if !more {
+			return
+		}
+		// Start the next batch right after the last key
+		from = last + "\x00"
+	}
+}
+
+// ScanKeys gets the keys from start up to, but not including, end in order, split into pages of limit keys. An
+// empty end has no upper bound, and a limit of 0 gets every key. cursor is the Cursor of the previous page - empty
+// for the first page.
+func (k *Keystore) ScanKeys(start string, end string, cursor string, limit int) (KeyPage, helpers.Error) {
+	if limit < 0 || (end != "" && end < start) {
+		return KeyPage{}, helpers.NewError(helpers.ErrorQueryInvalidFormat, "")
+	}
+	if cursor > start {
+		start = cursor
+	}
+	page := KeyPage{Keys: []string{}}
+	k.Iterate(start, end, func(key string) bool {
+		if limit > 0 && len(page.Keys) == limit {
+			page.Cursor = key
+			return false
+		}
+		page.Keys = append(page.Keys, key)
+		return true
+	})
+	return page, helpers.Error{}
+}
+
+// PrefixKeys gets the keys that start with prefix in order, split into pages of limit keys. A limit of 0 gets every
+// key. cursor is the Cursor of the previous page - empty for the first page.
+func (k *Keystore) PrefixKeys(prefix string, cursor string, limit int) (KeyPage, helpers.Error) {
+	return k.ScanKeys(prefix, prefixEnd(prefix), cursor, limit)
+}
+
+// Gets the first key after every key that starts with prefix - empty when there is none
+func prefixEnd(prefix string) string {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return string(end[:i+1])
+		}
+	}
+	return ""
+}
+
+// Adds a key to the ordered keys - must lock eMux before-hand
+func (k *Keystore) addKey(key string) {
+	k.keys.insert(key)
+}
+
+// Removes a key from the ordered keys - must lock eMux before-hand
+func (k *Keystore) removeKey(key string) {
+	k.keys.remove(key)
+}
+
+// Gets every key in order - must lock eMux before-hand
+func (k *Keystore) allKeys() []string {
+	keys := make([]string, 0, k.keys.size())
+	for n := k.keys.first(); n != nil; n = n.next[0] {
+		keys = append(keys, n.value.(string))
+	}
+	return keys
+}
+
+// Fills the ordered keys with the keys of every entry, sorting them once - used by Restore. Must lock eMux
+// before-hand.
+func (k *Keystore) buildKeys() {
+	keys := make([]string, 0, len(k.entries))
+	for key := range k.entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	values := make([]interface{}, len(keys))
+	for i, key := range keys {
+		values[i] = key
+	}
+	k.keys = newSkipList(compareStrings)
+	k.keys.build(values)
+}
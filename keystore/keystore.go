@@ -17,6 +17,7 @@ language governing permissions and limitations under the License.
 package keystore
 
 import (
+	"container/heap"
 	"github.com/hewiefreeman/GopherDB/helpers"
 	"github.com/hewiefreeman/GopherDB/schema"
 	"github.com/hewiefreeman/GopherDB/storage"
@@ -24,9 +25,9 @@ import (
 	"io"
 	"os"
 	"strconv"
-	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 	"encoding/json"
 	"fmt"
 )
@@ -50,17 +51,23 @@ type Keystore struct {
 	memOnly     bool          // Store data in memory only (overrides dataOnDrive)
 	dataOnDrive bool          // when true, entry data is not stored in memory, only indexing
 	name        string        // table's logger/persist folder name
-	schema      schema.Schema // table's schema
-	configFile  *os.File      // configuration file
+	schema      schema.Schema  // table's schema
+	configFile  *os.File       // configuration file
+	engine      storage.Engine // storage engine of the table's data
+	encoding    string         // encoding of entries in storage
 
 	// Atomic changeable settings values - 99% read
-	partitionMax atomic.Value // *uint16* maximum entries per data file
+	partitionMax atomic.Value // *uint32* maximum entries per data file
 	maxEntries   atomic.Value // *uint64* maximum amount of entries in the AuthTable
 	encryptCost  atomic.Value // *int* encryption cost of encrypted items
+	syncPolicy   atomic.Value // *uint8* storage sync policy of data files
+	compactRatio atomic.Value // *float64* ratio of deleted lines that triggers a partition's compaction - 0 turns it off
 
 	// entries
 	eMux    sync.Mutex                // entries/configFile lock
 	entries map[string]*keystoreEntry // Keystore map
+	keys    *skipList                 // keys of entries in order
+	pending map[string]bool           // keys held by transactions that are inserting them
 	// entries as map = 8 + (len(entries) * 8)
 	// entries total  = (entries as map) + (len(entries) * keystoreEntry)
 	// keystoreEntry  = 38 + (len(data) * (data.size))
@@ -68,36 +75,59 @@ type Keystore struct {
 	// data.size      = 50
 	// 10,000 entries = (80008) + (3,380,000) = 3,460,008 bytes = 3.46 MB
 
-	// unique values
+	// expiration
+	expiries  expiryHeap    // when entries expire, soonest first - locked by eMux
+	closed    chan struct{} // closed when the Keystore is closed, to stop it's expirer
+	closeOnce sync.Once
+
+	// unique values & indexes
 	uMux       sync.Mutex
 	uniqueVals map[string]map[interface{}]bool
+	indexes    map[string]*itemIndex // indexes by item name - the map is never changed after New
+
+	// compaction
+	pMux      sync.RWMutex       // entry persistFile/persistIndex lock - write locked while compacting a partition
+	compactor *storage.Compactor // counts deleted lines, and compacts data files
+
+	// transactions
+	inconsistent int32 // *atomic* 1 once a transaction leaves the data files out of line with the entries - stops writes until a Restore
 }
 
 type keystoreEntry struct {
 	persistFile  uint32
-	persistIndex uint16
+	persistIndex uint32
 
-	mux  sync.Mutex
-	data []interface{}
+	mux     sync.Mutex
+	data    []interface{}
+	expires int64   // unix nano time the entry expires - 0 never expires. Changed with both mux and the Keystore's eMux locked
+	deleted bool    // set by the delete that removes the entry
+	expiry  *expiry // the entry's expiry in the Keystore's expiries - locked by the Keystore's eMux
 }
 
 type keystoreConfig struct {
-	Name         string
-	Schema       []schema.SchemaConfigItem
-	FileOn       uint32
-	DataOnDrive  bool
-	MemOnly      bool
-	PartitionMax uint16
-	EncryptCost  int
-	MaxEntries   uint64
+	FormatVersion uint8
+	Name          string
+	Schema        []schema.SchemaConfigItem
+	FileOn        uint32
+	DataOnDrive   bool
+	MemOnly       bool
+	Engine        string
+	Encoding      string
+	PartitionMax  uint32
+	EncryptCost   int
+	MaxEntries    uint64
+	SyncPolicy    uint8
+	CompactRatio  float64
 }
 
 //////////////////////////////////////////////////////////////////////////////////////////////////////
 //   Keystore   //////////////////////////////////////////////////////////////////////////////////////
 //////////////////////////////////////////////////////////////////////////////////////////////////////
 
-// New creates a new Keystore with the provided name, schema, and other parameters.
-func New(name string, configFile *os.File, s schema.Schema, fileOn uint32, dataOnDrive bool, memOnly bool) (*Keystore, helpers.Error) {
+// New creates a new Keystore with the provided name, schema, and other parameters. engine is the name of the storage
+// engine for the Keystore's data - an empty engine uses the file engine. encoding is how entries are stored (see
+// helpers.EncodingJSON and helpers.EncodingBinary) - an empty encoding uses JSON.
+func New(name string, configFile *os.File, s schema.Schema, fileOn uint32, dataOnDrive bool, memOnly bool, engine string, encoding string) (*Keystore, helpers.Error) {
 	if len(name) == 0 {
 		return nil, helpers.NewError(helpers.ErrorTableNameRequired, name)
 	} else if Get(name) != nil {
@@ -111,16 +141,28 @@ func New(name string, configFile *os.File, s schema.Schema, fileOn uint32, dataO
 		dataOnDrive = false
 	}
 
+	// Check encoding
+	if encoding == "" {
+		encoding = helpers.EncodingJSON
+	} else if encoding != helpers.EncodingJSON && encoding != helpers.EncodingBinary {
+		return nil, helpers.NewError(helpers.ErrorInvalidEncoding, encoding)
+	}
+
 	// Table name with prefix
-	namePre := dataFolderPrefix + name
+	namePre := helpers.DataPath(dataFolderPrefix + name)
+
+	// Get storage engine
+	se, seErr := storage.NewEngine(engine, namePre)
+	if seErr != 0 {
+		return nil, helpers.NewError(seErr, engine)
+	}
 
 	// Restoring if configFile is not nil
 	if configFile == nil {
 		var err error
-		// Make table storage folder
-		err = storage.MakeDir(namePre)
-		if err != nil {
-			return nil, helpers.NewError(helpers.ErrorCreatingFolder, namePre + helpers.FileTypeConfig + ": " + err.Error())
+		// Make table storage
+		if cErr := se.Create(); cErr != 0 {
+			return nil, helpers.NewError(cErr, namePre)
 		}
 
 		// Create/open config file
@@ -132,14 +174,19 @@ func New(name string, configFile *os.File, s schema.Schema, fileOn uint32, dataO
 
 		// Write config file
 		if wErr := writeConfigFile(configFile, keystoreConfig{
-			Name:         name,
-			Schema:       s.MakeConfig(),
-			FileOn:       fileOn,
-			DataOnDrive:  dataOnDrive,
-			MemOnly:      memOnly,
-			PartitionMax: helpers.DefaultPartitionMax,
-			EncryptCost:  helpers.DefaultEncryptCost,
-			MaxEntries:   helpers.DefaultMaxEntries,
+			FormatVersion: helpers.FormatVersion,
+			Name:          name,
+			Schema:        s.MakeConfig(),
+			FileOn:        fileOn,
+			DataOnDrive:   dataOnDrive,
+			MemOnly:       memOnly,
+			Engine:        se.Name(),
+			Encoding:      encoding,
+			PartitionMax:  helpers.DefaultPartitionMax,
+			EncryptCost:   helpers.DefaultEncryptCost,
+			MaxEntries:    helpers.DefaultMaxEntries,
+			SyncPolicy:    helpers.DefaultSyncPolicy,
+			CompactRatio:  helpers.DefaultCompactRatio,
 		}); wErr != 0 {
 			return nil, helpers.NewError(wErr, namePre + helpers.FileTypeConfig)
 		}
@@ -152,21 +199,35 @@ func New(name string, configFile *os.File, s schema.Schema, fileOn uint32, dataO
 		dataOnDrive: dataOnDrive,
 		schema:      s,
 		configFile:  configFile,
+		engine:      se,
+		encoding:    encoding,
 		entries:     make(map[string]*keystoreEntry),
+		keys:        newSkipList(compareStrings),
+		pending:     make(map[string]bool),
+		closed:      make(chan struct{}),
 		uniqueVals:  make(map[string]map[interface{}]bool),
+		indexes:     makeIndexes(s),
 		fileOn:      fileOn,
 	}
 
+	t.compactor = storage.NewCompactor(se, "Keystore '"+name+"'", &t.pMux, t.moveEntries)
+
 	// Set defaults
 	t.partitionMax.Store(helpers.DefaultPartitionMax)
 	t.maxEntries.Store(helpers.DefaultMaxEntries)
 	t.encryptCost.Store(helpers.DefaultEncryptCost)
+	t.syncPolicy.Store(helpers.DefaultSyncPolicy)
+	t.compactRatio.Store(helpers.DefaultCompactRatio)
+	se.SetSyncPolicy(helpers.DefaultSyncPolicy)
 
 	// Push to stores map
 	storesMux.Lock()
 	stores[name] = &t
 	storesMux.Unlock()
 
+	// Start deleting expired entries
+	go t.runExpirer()
+
 	return &t, helpers.Error{}
 }
 
@@ -195,6 +256,9 @@ func (k *Keystore) Close(save bool) {
 		}
 	}
 
+	// Stop the expirer
+	k.closeOnce.Do(func() { close(k.closed) })
+
 	storesMux.Lock()
 	stores[k.name] = nil
 	delete(stores, k.name)
@@ -205,14 +269,14 @@ func (k *Keystore) Close(save bool) {
 func (k *Keystore) Delete() int {
 	k.Close(false)
 
-	// Delete data directory
-	if err := os.RemoveAll(dataFolderPrefix + k.name); err != nil {
-		helpers.LogAndPrint("Failed delete Keystore '" + k.name + "' with error: " + err.Error(), 5)
-		return helpers.ErrorFileDelete
+	// Delete data
+	if err := k.engine.Delete(); err != 0 {
+		helpers.LogAndPrint("Failed delete Keystore '" + k.name + "' data with error code: " + strconv.Itoa(err), 5)
+		return err
 	}
 
 	// Delete config file
-	if err := os.Remove(dataFolderPrefix + k.name + helpers.FileTypeConfig); err != nil {
+	if err := os.Remove(helpers.DataPath(dataFolderPrefix + k.name) + helpers.FileTypeConfig); err != nil {
 		helpers.LogAndPrint("Failed delete Keystore '" + k.name + "' with error: " + err.Error(), 5)
 		return helpers.ErrorFileDelete
 	}
@@ -227,9 +291,12 @@ func (k *Keystore) Get(key string) (*keystoreEntry, int) {
 		return nil, helpers.ErrorKeyRequired
 	}
 
-	// Find entry
+	// Find entry - expired entries can't be found before the expirer deletes them
 	k.eMux.Lock()
 	e := k.entries[key]
+	if e != nil && e.expired(time.Now().UnixNano()) {
+		e = nil
+	}
 	k.eMux.Unlock()
 
 	if e == nil {
@@ -239,10 +306,10 @@ func (k *Keystore) Get(key string) (*keystoreEntry, int) {
 	return e, 0
 }
 
-// Size returns the number of entries in the Keystore
+// Size returns the number of entries in the Keystore, leaving out expired entries the expirer hasn't deleted yet
 func (k *Keystore) Size() int {
 	k.eMux.Lock()
-	s := len(k.entries)
+	s := len(k.entries) - k.expiries.countDue(0, time.Now().UnixNano())
 	k.eMux.Unlock()
 	return s
 }
@@ -261,6 +328,16 @@ func (k *Keystore) EncryptCost() int {
 	return k.encryptCost.Load().(int)
 }
 
+// SyncPolicy returns the storage sync policy for this Keystore's data files
+func (k *Keystore) SyncPolicy() uint8 {
+	return k.syncPolicy.Load().(uint8)
+}
+
+// CompactRatio returns the ratio of deleted lines in a data file that triggers it's compaction
+func (k *Keystore) CompactRatio() float64 {
+	return k.compactRatio.Load().(float64)
+}
+
 //////////////////////////////////////////////////////////////////////////////////////////////////////
 //   Keystore Setters   //////////////////////////////////////////////////////////////////////////////
 //////////////////////////////////////////////////////////////////////////////////////////////////////
@@ -303,7 +380,7 @@ func (k *Keystore) SetMaxEntries(max uint64) int {
 }
 
 // SetPartitionMax sets the maximum entries stored in a data file
-func (k *Keystore) SetPartitionMax(max uint16) int {
+func (k *Keystore) SetPartitionMax(max uint32) int {
 	if max < helpers.PartitionMin {
 		max = helpers.DefaultPartitionMax
 	}
@@ -322,16 +399,62 @@ func (k *Keystore) SetPartitionMax(max uint16) int {
 	return 0
 }
 
+// SetSyncPolicy sets how writes to the Keystore's data files are flushed to the disk
+func (k *Keystore) SetSyncPolicy(policy uint8) int {
+	if policy > helpers.SyncPolicyAlways {
+		policy = helpers.DefaultSyncPolicy
+	}
+
+	// Write to configFile
+	k.eMux.Lock()
+	fileOn := k.fileOn
+	k.eMux.Unlock()
+	conf := k.makeDefaultConfig(fileOn)
+	conf.SyncPolicy = policy
+	if err := writeConfigFile(k.configFile, conf); err != 0 {
+		helpers.LogAndPrint("Failed to set sync policy for Keystore '"+k.name+"' with error code: "+strconv.Itoa(err), 4)
+		return err
+	}
+	k.syncPolicy.Store(policy)
+	k.engine.SetSyncPolicy(policy)
+	return 0
+}
+
+// SetCompactRatio sets the ratio of deleted lines in a data file that triggers it's compaction. 0 turns automatic compaction off.
+func (k *Keystore) SetCompactRatio(ratio float64) int {
+	if ratio < 0 || ratio > 1 {
+		ratio = helpers.DefaultCompactRatio
+	}
+
+	// Write to configFile
+	k.eMux.Lock()
+	fileOn := k.fileOn
+	k.eMux.Unlock()
+	conf := k.makeDefaultConfig(fileOn)
+	conf.CompactRatio = ratio
+	if err := writeConfigFile(k.configFile, conf); err != 0 {
+		helpers.LogAndPrint("Failed to set compact ratio for Keystore '" + k.name + "' with error code: " + strconv.Itoa(err), 4)
+		return err
+	}
+	k.compactRatio.Store(ratio)
+	return 0
+}
+
 func (k *Keystore) makeDefaultConfig(fileOn uint32) keystoreConfig {
 	return keystoreConfig {
-		Name:         k.name,
-		Schema:       k.schema.MakeConfig(),
-		FileOn:       fileOn,
-		DataOnDrive:  k.dataOnDrive,
-		MemOnly:      k.memOnly,
-		PartitionMax: k.partitionMax.Load().(uint16),
-		EncryptCost:  k.encryptCost.Load().(int),
-		MaxEntries:   k.maxEntries.Load().(uint64),
+		FormatVersion: helpers.FormatVersion,
+		Name:          k.name,
+		Schema:        k.schema.MakeConfig(),
+		FileOn:        fileOn,
+		DataOnDrive:   k.dataOnDrive,
+		MemOnly:       k.memOnly,
+		Engine:        k.engine.Name(),
+		Encoding:      k.encoding,
+		PartitionMax:  k.partitionMax.Load().(uint32),
+		EncryptCost:   k.encryptCost.Load().(int),
+		MaxEntries:    k.maxEntries.Load().(uint64),
+		SyncPolicy:    k.syncPolicy.Load().(uint8),
+		CompactRatio:  k.compactRatio.Load().(float64),
 	}
 }
 
@@ -357,7 +480,7 @@ func writeConfigFile(f *os.File, k keystoreConfig) int {
 // Restore restores a Keystore by name; requires a valid config file and data folder.
 func Restore(name string) (*Keystore, helpers.Error) {
 	fmt.Printf("Restoring Keystore '%v'...\n", name)
-	namePre := dataFolderPrefix + name
+	namePre := helpers.DataPath(dataFolderPrefix + name)
 	// Open the File
 	f, err := os.OpenFile(namePre+helpers.FileTypeConfig, os.O_RDWR, 0755)
 	if err != nil {
@@ -377,13 +500,17 @@ func Restore(name string) (*Keystore, helpers.Error) {
 		return nil, helpers.NewError(helpers.ErrorFileRead, "Config data is corrupt for Keystore '" + name + "'")
 	}
 	// Make confStruct from json bytes
-	var confStruct keystoreConfig
+	confStruct := keystoreConfig{SyncPolicy: helpers.DefaultSyncPolicy, CompactRatio: helpers.DefaultCompactRatio}
 	mErr := json.Unmarshal(bytes, &confStruct)
 	if mErr != nil {
 		f.Close()
 		return nil, helpers.NewError(helpers.ErrorJsonDecoding,
 			"Config contains JSON syntax errors for Keystore '" + name + "': " + mErr.Error())
 	}
+	if confStruct.FormatVersion > helpers.FormatVersion {
+		f.Close()
+		return nil, helpers.NewError(helpers.ErrorFormatVersion, "Keystore '" + name + "' was made by a newer version (format version " + strconv.Itoa(int(confStruct.FormatVersion)) + ")")
+	}
 	// Make schema with the schemaList
 	s, schemaErr := schema.Restore(confStruct.Schema)
 	if schemaErr.ID != 0 {
@@ -392,7 +519,7 @@ func Restore(name string) (*Keystore, helpers.Error) {
 		return nil, schemaErr
 	}
 	// Make Keystore table
-	ks, ksErr := New(name, f, s, confStruct.FileOn, confStruct.DataOnDrive, confStruct.MemOnly)
+	ks, ksErr := New(name, f, s, confStruct.FileOn, confStruct.DataOnDrive, confStruct.MemOnly, confStruct.Engine, confStruct.Encoding)
 	if ksErr.ID != 0 {
 		f.Close()
 		return nil, ksErr
@@ -409,63 +536,66 @@ func Restore(name string) (*Keystore, helpers.Error) {
 	if confStruct.PartitionMax != helpers.DefaultPartitionMax {
 		ks.partitionMax.Store(confStruct.PartitionMax)
 	}
-	// Open data folder
-	df, err := os.Open(namePre)
-	if err != nil {
-		ks.eMux.Unlock()
-		ks.uMux.Unlock()
-		df.Close()
-		ks.Close(false)
-		return nil, helpers.NewError(helpers.ErrorFileOpen, "Missing data folder for Keystore '" + name + "'")
+	if confStruct.SyncPolicy != helpers.DefaultSyncPolicy {
+		ks.syncPolicy.Store(confStruct.SyncPolicy)
+		ks.engine.SetSyncPolicy(confStruct.SyncPolicy)
 	}
-	// Get file names
-	files, err := df.Readdir(-1)
-	df.Close()
-	if err != nil {
+	if confStruct.CompactRatio != helpers.DefaultCompactRatio {
+		ks.compactRatio.Store(confStruct.CompactRatio)
+	}
+	// Finish the writes of transactions cut off by a crash
+	if !ks.memOnly {
+		if txErr := recoverTransactions(name, ks.engine); txErr != 0 {
+			ks.eMux.Unlock()
+			ks.uMux.Unlock()
+			ks.Close(false)
+			return nil, helpers.NewError(txErr, "Could not recover transactions for Keystore '" + name + "'")
+		}
+	}
+	// Get data partitions
+	partitions, pErr := ks.engine.Partitions()
+	if pErr != 0 {
 		ks.eMux.Unlock()
 		ks.uMux.Unlock()
 		ks.Close(false)
-		return nil, helpers.NewError(helpers.ErrorFileRead, "Error reading files in data folder for Keystore '" + name + "'")
+		return nil, helpers.NewError(pErr, "Missing data for Keystore '" + name + "'")
 	}
 	fmt.Printf("Loading Keystore data for '%v'...\n", name)
 	// Make progress bar
-	pBar := progressbar.New(len(files))
-	// Go through files & restore entries
-	for _, fileStats := range files {
-		// Get file number
-		fileNameSplit := strings.Split(fileStats.Name(), ".")
-		fileNum, fnErr := strconv.Atoi(fileNameSplit[0])
-		if fnErr != nil || len(fileNameSplit) < 2 || "."+fileNameSplit[1] != helpers.FileTypeStorage {
-			// Not a valid storage file
+	pBar := progressbar.New(len(partitions))
+	// Go through partitions & restore entries
+	for _, fileNum := range partitions {
+		lines, err := ks.engine.Lines(fileNum)
+		if err != 0 {
+			helpers.LogAndPrint("Error: Keystore '" + name + "':: Could not read data partition " + strconv.Itoa(int(fileNum)) + "!\n", 4)
 			pBar.Add(1)
 			continue
 		}
-		var of *storage.OpenFile
-		var err int
-		if of, err = storage.GetOpenFile(namePre + "/" + fileStats.Name()); err != 0 {
-			helpers.LogAndPrint("Error: Keystore '" + name + "':: Could not read data file '" + namePre + "/" + fileStats.Name() + "'!\n", 4)
-			pBar.Add(1)
-			continue
-		}
-		for i := 0; i < of.Lines(); i++ {
+		for i := 0; i < lines; i++ {
 			// Get line bytes
 			var lb []byte
-			if lb, err = of.Read(uint16(i+1)); err != 0 {
-				helpers.LogAndPrint("Error: Keystore '" + name + "':: Could not read line " + strconv.Itoa(i + 1) + " of '" + fileStats.Name() + "'!\n", 4)
+			if lb, err = ks.engine.Read(fileNum, uint32(i+1)); err != 0 {
+				helpers.LogAndPrint("Error: Keystore '" + name + "':: Could not read line " + strconv.Itoa(i + 1) + " of partition " + strconv.Itoa(int(fileNum)) + "!\n", 4)
+				continue
+			} else if len(lb) == 0 {
+				// Deleted entry
+				ks.compactor.AddDead(fileNum, 1)
 				continue
 			}
-			eKey, eData := restoreDataLine(lb)
-			if eData == nil {
-				helpers.LogAndPrint("Error: Keystore '" + name + "':: Incorrect JSON format on line " + strconv.Itoa(i + 1) + " of '" + fileStats.Name() + "'!\n", 4)
+			eKey, eData, eExpires, dErr := ks.readEntryBytes(lb)
+			if dErr != 0 {
+				helpers.LogAndPrint("Error: Keystore '" + name + "':: Incorrect " + ks.encoding + " format on line " + strconv.Itoa(i + 1) + " of partition " + strconv.Itoa(int(fileNum)) + "!\n", 4)
 				continue
 			}
-			if err = ks.restoreKey(eKey, eData, uint32(fileNum), uint16(i+1)); err != 0 {
-				fmt.Printf("Error: Keystore '" + name + "':: Line " + strconv.Itoa(i + 1) + " of '" + fileStats.Name() + "', with error code " + strconv.Itoa(err) + "\n", 4)
+			if err = ks.restoreKey(eKey, eData, eExpires, fileNum, uint32(i+1)); err != 0 {
+				fmt.Printf("Error: Keystore '" + name + "':: Line " + strconv.Itoa(i + 1) + " of partition " + strconv.Itoa(int(fileNum)) + ", with error code " + strconv.Itoa(err) + "\n", 4)
 				continue
 			}
 		}
 		pBar.Add(1)
 	}
+	ks.buildKeys()
+	heap.Init(&ks.expiries)
 	ks.uMux.Unlock()
 	ks.eMux.Unlock()
 	fmt.Printf("Successfully restored table '%v'!\n", name)
@@ -473,16 +603,16 @@ func Restore(name string) (*Keystore, helpers.Error) {
 }
 
 // Resore a line of data from
-func restoreDataLine(line []byte) (string, []interface{}) {
+func restoreDataLine(line []byte) (string, []interface{}, int64) {
 	var jEntry jsonEntry
 	mErr := json.Unmarshal(line, &jEntry)
 	if mErr != nil {
-		return "", nil
+		return "", nil, 0
 	}
 
 	if jEntry.D == nil || jEntry.K == "" {
-		return "", nil
+		return "", nil, 0
 	}
 
-	return jEntry.K, jEntry.D
+	return jEntry.K, jEntry.D, jEntry.X
 }
@@ -35,7 +35,7 @@ var (
 
 func restore() (bool, error) {
 	// Initialize storage engine
-	storage.Init()
+	storage.Init("")
 
 	//
 	if !setupComplete {
@@ -1,11 +1,19 @@
 package keystore
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/hewiefreeman/GopherDB/helpers"
 	"github.com/hewiefreeman/GopherDB/keystore"
+	"github.com/hewiefreeman/GopherDB/schema"
 	"github.com/hewiefreeman/GopherDB/storage"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strconv"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -13,7 +21,7 @@ import (
 const (
 	// Test settings
 	tableName           string = "test"
-	tablePartitionMax   uint16 = 250
+	tablePartitionMax   uint32 = 250
 	tableMaxEntries     uint64 = 1000000
 	tableEncryptionCost int    = 4
 )
@@ -33,7 +41,7 @@ var (
 
 func TestChangeStorageSettings(t *testing.T) {
 	// Initialize storage engine
-	storage.Init()
+	storage.Init("")
 
 	// Set fileOpenTime to 3 seconds (needs 3 sec or lower for testing file closing at end)
 	storage.SetFileOpenTime(3 * time.Second)
@@ -686,6 +694,982 @@ func TestSortArray(t *testing.T) {
 	}
 }*/
 
+func TestCompact(t *testing.T) {
+	s, sErr := schema.New(map[string]interface{}{
+		"mmr": []interface{}{"Uint16", 0.0, 0.0, 0.0, false, false},
+	}, false)
+	if sErr.ID != 0 {
+		t.Errorf("TestCompact error making schema: %v", sErr)
+		return
+	}
+	// Remove a Keystore left behind by a failed test
+	if k, err := keystore.Restore(tableName + "-compact"); err.ID == 0 {
+		k.Delete()
+	}
+	k, kErr := keystore.New(tableName+"-compact", nil, s, 0, true, false, "", "")
+	if kErr.ID != 0 {
+		t.Errorf("TestCompact error making Keystore: %v", kErr)
+		return
+	}
+	defer k.Delete()
+	k.SetCompactRatio(0)
+	for i := 0; i < 6; i++ {
+		k.InsertKey("key"+strconv.Itoa(i), map[string]interface{}{"mmr": i})
+	}
+	k.DeleteKey("key0")
+	k.DeleteKey("key2")
+	if err := k.Compact(); err != 0 {
+		t.Errorf("TestCompact error: %v", err)
+		return
+	}
+	checkCompacted(t, k, "TestCompact", 4, 1, 3, 4, 5)
+	// Deleting half of the lines triggers a compaction
+	k.SetCompactRatio(0.5)
+	k.DeleteKey("key1")
+	k.DeleteKey("key3")
+	time.Sleep(100 * time.Millisecond)
+	checkCompacted(t, k, "TestCompact (automatic)", 2, 4, 5)
+	// Entries must be restored from their new lines
+	k.Close(true)
+	var err helpers.Error
+	if k, err = keystore.Restore(tableName + "-compact"); err.ID != 0 {
+		t.Errorf("TestCompact restore error: %v", err)
+		return
+	}
+	if k.CompactRatio() != 0.5 {
+		t.Errorf("TestCompact expected compact ratio 0.5 to be restored, but got: %v", k.CompactRatio())
+	}
+	checkCompacted(t, k, "TestCompact (restore)", 2, 4, 5)
+}
+
+// Checks the number of lines in a compacted Keystore's first data file, and the mmr of the entries left in it
+func checkCompacted(t *testing.T, k *keystore.Keystore, test string, lines int, keys ...int) {
+	f, fErr := storage.GetOpenFile("Keystore-" + tableName + "-compact/0" + helpers.FileTypeStorage)
+	if fErr != 0 {
+		t.Errorf("%v error opening data file: %v", test, fErr)
+	} else if f.Lines() != lines {
+		t.Errorf("%v expected %v lines, but got: %v", test, lines, f.Lines())
+	}
+	for _, i := range keys {
+		data, err := k.GetKey("key"+strconv.Itoa(i), map[string]interface{}{"mmr": nil})
+		if err.ID != 0 || data["mmr"] != uint16(i) {
+			t.Errorf("%v expected mmr %v for key%v, but got: %v %v", test, i, i, data, err)
+		}
+	}
+}
+
+func TestCheckIntegrity(t *testing.T) {
+	s, sErr := schema.New(map[string]interface{}{
+		"mmr": []interface{}{"Uint16", 0.0, 0.0, 0.0, false, false},
+	}, false)
+	if sErr.ID != 0 {
+		t.Errorf("TestCheckIntegrity error making schema: %v", sErr)
+		return
+	}
+	// Remove a Keystore left behind by a failed test
+	if k, err := keystore.Restore(tableName + "-integrity"); err.ID == 0 {
+		k.Delete()
+	}
+	k, kErr := keystore.New(tableName+"-integrity", nil, s, 0, true, false, "", "")
+	if kErr.ID != 0 {
+		t.Errorf("TestCheckIntegrity error making Keystore: %v", kErr)
+		return
+	}
+	defer k.Delete()
+	for i := 0; i < 3; i++ {
+		k.InsertKey("key"+strconv.Itoa(i), map[string]interface{}{"mmr": i})
+	}
+	if errs := k.CheckIntegrity(); len(errs) != 0 {
+		t.Errorf("TestCheckIntegrity expected no errors, but got: %v", errs)
+	}
+	// Change a byte of key1 on the disk
+	file := "Keystore-" + tableName + "-integrity/0" + helpers.FileTypeStorage
+	b, _ := ioutil.ReadFile(file)
+	b[bytes.Index(b, []byte("key1"))+2] = 'z'
+	ioutil.WriteFile(file, b, 0755)
+	errs := k.CheckIntegrity()
+	if len(errs) != 1 || errs[0].ID != helpers.ErrorChecksumMismatch {
+		t.Errorf("TestCheckIntegrity expected one checksum error, but got: %v", errs)
+	}
+	if _, err := k.GetKey("key1", nil); err.ID != helpers.ErrorChecksumMismatch {
+		t.Errorf("TestCheckIntegrity expected a checksum error getting key1, but got: %v", err)
+	}
+}
+
+func TestBinaryEncoding(t *testing.T) {
+	s, sErr := schema.New(map[string]interface{}{
+		"id":      []interface{}{"Int64", 0.0, 0.0, 0.0, false, false, true},
+		"balance": []interface{}{"Uint64", 0.0, 0.0, 0.0, false, false},
+		"joined":  []interface{}{"Time", "RFC3339Nano", false},
+		"name":    []interface{}{"String", "", 0.0, false, false, false},
+		"tags":    []interface{}{"Array", []interface{}{"String", "", 0.0, false, false, false}, 0.0, false},
+		"stats":   []interface{}{"Map", []interface{}{"Float32", 0.0, 0.0, 0.0, false, false, false}, 0.0, false},
+		"friend":  []interface{}{"Object", map[string]interface{}{
+			"name":  []interface{}{"String", "", 0.0, false, false, false},
+			"level": []interface{}{"Int8", 0.0, 0.0, 0.0, false, false, false},
+		}},
+	}, false)
+	if sErr.ID != 0 {
+		t.Errorf("TestBinaryEncoding error making schema: %v", sErr)
+		return
+	}
+	// Remove a Keystore left behind by a failed test
+	if k, err := keystore.Restore(tableName + "-binary"); err.ID == 0 {
+		k.Delete()
+	}
+	if _, err := keystore.New(tableName+"-binary", nil, s, 0, true, false, "", "xml"); err.ID != helpers.ErrorInvalidEncoding {
+		t.Errorf("TestBinaryEncoding expected error %v for an unknown encoding, but got: %v", helpers.ErrorInvalidEncoding, err)
+	}
+	k, kErr := keystore.New(tableName+"-binary", nil, s, 0, true, false, "", helpers.EncodingBinary)
+	if kErr.ID != 0 {
+		t.Errorf("TestBinaryEncoding error making Keystore: %v", kErr)
+		return
+	}
+	defer func() { k.Delete() }()
+	joined := "2020-06-01T12:30:45.123456789+02:00"
+	if _, err := k.InsertKey("gopher", map[string]interface{}{
+		"id":      int64(9007199254740993), // too big for a float64
+		"balance": uint64(18446744073709551615),
+		"joined":  joined,
+		"name":    "Go\nPher ",
+		"tags":    []interface{}{"a", "b"},
+		"stats":   map[string]interface{}{"kd": 1.5},
+		"friend":  map[string]interface{}{"name": "Mary", "level": 5},
+	}); err.ID != 0 {
+		t.Errorf("TestBinaryEncoding insert error: %v", err)
+		return
+	}
+	checkBinaryEntry(t, k, "TestBinaryEncoding", joined)
+	if err := k.UpdateKey("gopher", map[string]interface{}{"tags.*append": []interface{}{[]interface{}{"c"}}}); err.ID != 0 {
+		t.Errorf("TestBinaryEncoding update error: %v", err)
+	}
+	// Restore must read the same types back, and restore unique values
+	k.Close(true)
+	var err helpers.Error
+	if k, err = keystore.Restore(tableName + "-binary"); err.ID != 0 {
+		t.Errorf("TestBinaryEncoding restore error: %v", err)
+		return
+	}
+	checkBinaryEntry(t, k, "TestBinaryEncoding (restore)", joined)
+	if _, err = k.InsertKey("gopher2", map[string]interface{}{"id": int64(9007199254740993)}); err.ID != helpers.ErrorUniqueValueDuplicate {
+		t.Errorf("TestBinaryEncoding expected a duplicate unique value error, but got: %v", err)
+	}
+	if errs := k.CheckIntegrity(); len(errs) != 0 {
+		t.Errorf("TestBinaryEncoding expected no integrity errors, but got: %v", errs)
+	}
+}
+
+// Checks the items of the entry made by TestBinaryEncoding
+func checkBinaryEntry(t *testing.T, k *keystore.Keystore, test string, joined string) {
+	data, err := k.GetKey("gopher", nil)
+	if err.ID != 0 {
+		t.Errorf("%v get error: %v", test, err)
+		return
+	}
+	if data["id"] != int64(9007199254740993) || data["balance"] != uint64(18446744073709551615) {
+		t.Errorf("%v expected exact 64 bit integers, but got: %v %v", test, data["id"], data["balance"])
+	}
+	if data["joined"] != joined || data["name"] != "Go\nPher " {
+		t.Errorf("%v expected joined %v and name %q, but got: %v %q", test, joined, "Go\nPher ", data["joined"], data["name"])
+	}
+	if stats, ok := data["stats"].(map[string]interface{}); !ok || stats["kd"] != float32(1.5) {
+		t.Errorf("%v expected stats.kd 1.5, but got: %v", test, data["stats"])
+	}
+	if friend, ok := data["friend"].(map[string]interface{}); !ok || friend["name"] != "Mary" || friend["level"] != int8(5) {
+		t.Errorf("%v expected friend Mary at level 5, but got: %v", test, data["friend"])
+	}
+}
+
+func TestBinaryEncodingSize(t *testing.T) {
+	s, sErr := schema.New(map[string]interface{}{
+		"id":        []interface{}{"Int64", 0.0, 0.0, 0.0, false, false, false},
+		"level":     []interface{}{"Uint8", 0.0, 0.0, 0.0, false, false},
+		"coins":     []interface{}{"Uint32", 0.0, 0.0, 0.0, false, false},
+		"name":      []interface{}{"String", "", 0.0, false, false, false},
+		"lastLogin": []interface{}{"Time", "RFC3339", false},
+		"friends":   []interface{}{"Array", []interface{}{"String", "", 0.0, false, false, false}, 0.0, false},
+	}, false)
+	if sErr.ID != 0 {
+		t.Errorf("TestBinaryEncodingSize error making schema: %v", sErr)
+		return
+	}
+	sizes := make(map[string]int64)
+	for _, encoding := range []string{helpers.EncodingJSON, helpers.EncodingBinary} {
+		name := tableName + "-size-" + encoding
+		k, kErr := keystore.New(name, nil, s, 0, false, false, "", encoding)
+		if kErr.ID != 0 {
+			t.Errorf("TestBinaryEncodingSize error making Keystore: %v", kErr)
+			return
+		}
+		// Levels and coins of 10 and 32 put new lines and spaces in binary records
+		for i := 0; i < 100; i++ {
+			if _, err := k.InsertKey("player"+strconv.Itoa(i), map[string]interface{}{
+				"id":        int64(i),
+				"level":     uint8(10),
+				"coins":     uint32(32 + i*10),
+				"name":      "Player " + strconv.Itoa(i),
+				"lastLogin": "2020-06-01T12:30:45Z",
+				"friends":   []interface{}{"Mary", "Bill"},
+			}); err.ID != 0 {
+				t.Errorf("TestBinaryEncodingSize insert error: %v", err)
+			}
+		}
+		files, _ := filepath.Glob(filepath.Join(helpers.DataPath("Keystore-"+name), "*"+helpers.FileTypeStorage))
+		for _, file := range files {
+			if info, err := os.Stat(file); err == nil {
+				sizes[encoding] += info.Size()
+			}
+		}
+		k.Delete()
+	}
+	// Binary records with new lines and spaces are escaped, not base64 encoded, so they stay smaller than JSON
+	if sizes[helpers.EncodingBinary] == 0 || sizes[helpers.EncodingBinary] > sizes[helpers.EncodingJSON]*4/5 {
+		t.Errorf("TestBinaryEncodingSize expected binary data files to be under 4/5 the size of JSON's, but got %v bytes to %v", sizes[helpers.EncodingBinary], sizes[helpers.EncodingJSON])
+	}
+}
+
+func TestGetKeys(t *testing.T) {
+	s, sErr := schema.New(map[string]interface{}{
+		"mmr":    []interface{}{"Uint16", 0.0, 0.0, 0.0, false, false},
+		"banned": []interface{}{"Bool", false},
+		"badges": []interface{}{"Array", []interface{}{"String", "", 0.0, false, false, false}, 0.0, false},
+		"friend": []interface{}{"Object", map[string]interface{}{
+			"level": []interface{}{"Int8", 0.0, 0.0, 0.0, false, false, false},
+		}},
+	}, false)
+	if sErr.ID != 0 {
+		t.Errorf("TestGetKeys error making schema: %v", sErr)
+		return
+	}
+	k, kErr := keystore.New(tableName+"-select", nil, s, 0, false, true, "", "")
+	if kErr.ID != 0 {
+		t.Errorf("TestGetKeys error making Keystore: %v", kErr)
+		return
+	}
+	defer k.Delete()
+	players := map[string][]interface{}{
+		"Amy":  {1700, false, []interface{}{"gold"}, 3},
+		"Bob":  {1400, false, []interface{}{"gold"}, 9},
+		"Cat":  {1900, true, []interface{}{"gold", "silver"}, 1},
+		"Dan":  {1600, false, []interface{}{}, 5},
+		"Eve":  {2100, false, []interface{}{"gold"}, 7},
+		"Finn": {1550, false, []interface{}{"silver", "gold"}, 2},
+	}
+	for key, p := range players {
+		if _, err := k.InsertKey(key, map[string]interface{}{"mmr": p[0], "banned": p[1], "badges": p[2], "friend": map[string]interface{}{"level": p[3]}}); err.ID != 0 {
+			t.Errorf("TestGetKeys insert error: %v", err)
+			return
+		}
+	}
+	where := map[string]interface{}{"mmr.*gt": []interface{}{1500}, "badges.*contains": []interface{}{"gold"}, "banned.*eq": []interface{}{false}}
+
+	// Every match, in order of their keys
+	checkKeys(t, k, where, nil, "", false, 0, 0, "Amy", "Eve", "Finn")
+	// Sorted, and split into pages
+	checkKeys(t, k, where, nil, "mmr", true, 2, 0, "Eve", "Amy")
+	checkKeys(t, k, where, nil, "mmr", true, 2, 1, "Finn")
+	checkKeys(t, k, where, nil, "mmr", true, 2, 2)
+	checkKeys(t, k, nil, nil, "friend.level", false, 3, 0, "Cat", "Finn", "Amy")
+
+	// Items to get
+	res, err := k.GetKeys(where, map[string]interface{}{"mmr": nil}, "mmr", false, 1, 0)
+	if err.ID != 0 || len(res) != 1 || res[0].Key != "Finn" || len(res[0].Items) != 1 || res[0].Items["mmr"] != uint16(1550) {
+		t.Errorf("TestGetKeys expected Finn's mmr, but got: %v %v", res, err)
+	}
+
+	// Where items must check to true or false
+	if _, err := k.GetKeys(map[string]interface{}{"mmr": []interface{}{1500}}, nil, "", false, 0, 0); err.ID != helpers.ErrorInvalidWhereItem {
+		t.Errorf("TestGetKeys expected error %v for a where item without methods, but got: %v", helpers.ErrorInvalidWhereItem, err)
+	}
+	if _, err := k.GetKeys(map[string]interface{}{"mmr.*add": []interface{}{1}}, nil, "", false, 0, 0); err.ID != helpers.ErrorInvalidWhereItem {
+		t.Errorf("TestGetKeys expected error %v for a where item that isn't true or false, but got: %v", helpers.ErrorInvalidWhereItem, err)
+	}
+	if _, err := k.GetKeys(nil, nil, "badges", false, 0, 0); err.ID != helpers.ErrorArrayItemNotSortable {
+		t.Errorf("TestGetKeys expected error %v sorting by an Array, but got: %v", helpers.ErrorArrayItemNotSortable, err)
+	}
+}
+
+func checkKeys(t *testing.T, k *keystore.Keystore, where map[string]interface{}, items map[string]interface{}, sortBy string, desc bool, limit int, page int, keys ...string) {
+	res, err := k.GetKeys(where, items, sortBy, desc, limit, page)
+	if err.ID != 0 {
+		t.Errorf("TestGetKeys error getting page %v by '%v': %v", page, sortBy, err)
+		return
+	}
+	got := make([]string, len(res))
+	for i, r := range res {
+		got[i] = r.Key
+	}
+	if len(got) != len(keys) {
+		t.Errorf("TestGetKeys expected %v on page %v by '%v', but got: %v", keys, page, sortBy, got)
+		return
+	}
+	for i := range keys {
+		if got[i] != keys[i] {
+			t.Errorf("TestGetKeys expected %v on page %v by '%v', but got: %v", keys, page, sortBy, got)
+			return
+		}
+	}
+}
+
+func TestIndexes(t *testing.T) {
+	// Only Bool, number and String items at the top of a schema can be indexed
+	for _, item := range []interface{}{
+		[]interface{}{"Uint16", 0.0, 0.0, 0.0, false, false, "btree"},
+		[]interface{}{"String", "", 0.0, true, false, false, "hash"},
+		[]interface{}{"Object", map[string]interface{}{"level": []interface{}{"Int8", 0.0, 0.0, 0.0, false, false, false, "ordered"}}},
+	} {
+		if _, err := schema.New(map[string]interface{}{"item": item}, false); err.ID != helpers.ErrorSchemaInvalidItemParameters {
+			t.Errorf("TestIndexes expected error %v for %v, but got: %v", helpers.ErrorSchemaInvalidItemParameters, item, err)
+		}
+	}
+	s, sErr := schema.New(map[string]interface{}{
+		"mmr":    []interface{}{"Uint16", 0.0, 0.0, 0.0, false, false, "ordered"},
+		"name":   []interface{}{"String", "", 0.0, false, false, false, "hash"},
+		"banned": []interface{}{"Bool", false, "hash"},
+	}, false)
+	if sErr.ID != 0 {
+		t.Errorf("TestIndexes error making schema: %v", sErr)
+		return
+	}
+	k, kErr := keystore.New(tableName+"-index", nil, s, 0, true, false, "", "")
+	if kErr.ID != 0 {
+		t.Errorf("TestIndexes error making Keystore: %v", kErr)
+		return
+	}
+	defer func() { k.Delete() }()
+	players := map[string][]interface{}{
+		"Amy":  {1700, "amy", false},
+		"Bob":  {1400, "bob", false},
+		"Cat":  {1900, "cat", true},
+		"Dan":  {1600, "dan", false},
+		"Eve":  {2100, "eve", false},
+		"Finn": {1600, "finn", false},
+	}
+	for key, p := range players {
+		if _, err := k.InsertKey(key, map[string]interface{}{"mmr": p[0], "name": p[1], "banned": p[2]}); err.ID != 0 {
+			t.Errorf("TestIndexes insert error: %v", err)
+			return
+		}
+	}
+	checkIndexes := func() {
+		checkKeys(t, k, map[string]interface{}{"mmr.*gte": []interface{}{1600}, "banned.*eq": []interface{}{false}}, nil, "", false, 0, 0, "Amy", "Dan", "Eve", "Finn")
+		checkKeys(t, k, map[string]interface{}{"mmr.*lt": []interface{}{1700}}, nil, "", false, 0, 0, "Bob", "Dan", "Finn")
+		checkKeys(t, k, map[string]interface{}{"name.*eq": []interface{}{"cat"}}, nil, "", false, 0, 0, "Cat")
+		// Sorted by the ordered index, equal values in order of their keys
+		checkKeys(t, k, nil, nil, "mmr", true, 3, 0, "Eve", "Cat", "Amy")
+		checkKeys(t, k, nil, nil, "mmr", true, 3, 1, "Dan", "Finn", "Bob")
+		checkKeys(t, k, map[string]interface{}{"mmr.*gt": []interface{}{1600}}, nil, "mmr", false, 0, 0, "Amy", "Cat", "Eve")
+	}
+	checkIndexes()
+
+	// Updates and deletes move entries in the indexes
+	if err := k.UpdateKey("Bob", map[string]interface{}{"mmr.*add": []interface{}{800}, "name": "bobby"}); err.ID != 0 {
+		t.Errorf("TestIndexes update error: %v", err)
+	}
+	checkKeys(t, k, map[string]interface{}{"mmr.*gt": []interface{}{2000}}, nil, "mmr", false, 0, 0, "Eve", "Bob")
+	checkKeys(t, k, map[string]interface{}{"name.*eq": []interface{}{"bob"}}, nil, "", false, 0, 0)
+	checkKeys(t, k, map[string]interface{}{"name.*eq": []interface{}{"bobby"}}, nil, "", false, 0, 0, "Bob")
+	if err := k.DeleteKey("Bob"); err.ID != 0 {
+		t.Errorf("TestIndexes delete error: %v", err)
+	}
+	checkKeys(t, k, map[string]interface{}{"mmr.*gt": []interface{}{2000}}, nil, "", false, 0, 0, "Eve")
+	if _, err := k.InsertKey("Bob", map[string]interface{}{"mmr": 1400, "name": "bob"}); err.ID != 0 {
+		t.Errorf("TestIndexes insert error: %v", err)
+	}
+
+	// Restore must rebuild the indexes
+	k.Close(true)
+	var err helpers.Error
+	if k, err = keystore.Restore(tableName + "-index"); err.ID != 0 {
+		t.Errorf("TestIndexes restore error: %v", err)
+		return
+	}
+	checkIndexes()
+}
+
+func TestKeyScans(t *testing.T) {
+	s, sErr := schema.New(map[string]interface{}{
+		"mmr": []interface{}{"Uint16", 0.0, 0.0, 0.0, false, false},
+	}, false)
+	if sErr.ID != 0 {
+		t.Errorf("TestKeyScans error making schema: %v", sErr)
+		return
+	}
+	k, kErr := keystore.New(tableName+"-keys", nil, s, 0, true, false, "", "")
+	if kErr.ID != 0 {
+		t.Errorf("TestKeyScans error making Keystore: %v", kErr)
+		return
+	}
+	defer func() { k.Delete() }()
+	for _, key := range []string{"player:124:a", "player:123:c", "player:12", "player:123:a", "team:1", "player:123:b", "player:123"} {
+		if _, err := k.InsertKey(key, nil); err.ID != 0 {
+			t.Errorf("TestKeyScans insert error: %v", err)
+			return
+		}
+	}
+	checkScans := func(test string) {
+		// Prefix scan split into pages with the cursor
+		checkKeyPage(t, test, k, true, "player:123:", "", "", 2, "player:123:c", "player:123:a", "player:123:b")
+		checkKeyPage(t, test, k, true, "player:123:", "", "player:123:c", 2, "", "player:123:c")
+		checkKeyPage(t, test, k, true, "player:123", "", "", 0, "", "player:123", "player:123:a", "player:123:b", "player:123:c")
+		// Range scans
+		checkKeyPage(t, test, k, false, "", "player:123:b", "", 0, "", "player:12", "player:123", "player:123:a")
+		checkKeyPage(t, test, k, false, "player:124", "", "", 0, "", "player:124:a", "team:1")
+		// Iterate stops when fn returns false
+		var keys []string
+		k.Iterate("", "", func(key string) bool {
+			keys = append(keys, key)
+			return len(keys) < 3
+		})
+		if len(keys) != 3 || keys[0] != "player:12" || keys[2] != "player:123:a" {
+			t.Errorf("%v expected the first 3 keys from Iterate, but got: %v", test, keys)
+		}
+	}
+	checkScans("TestKeyScans")
+	if err := k.DeleteKey("player:123:a"); err.ID != 0 {
+		t.Errorf("TestKeyScans delete error: %v", err)
+	}
+	if _, err := k.InsertKey("player:123:a", nil); err.ID != 0 {
+		t.Errorf("TestKeyScans insert error: %v", err)
+	}
+	if _, err := k.ScanKeys("b", "a", "", 0); err.ID != helpers.ErrorQueryInvalidFormat {
+		t.Errorf("TestKeyScans expected error %v for a range that ends before it starts, but got: %v", helpers.ErrorQueryInvalidFormat, err)
+	}
+
+	// Restore must restore the keys in order
+	k.Close(true)
+	var err helpers.Error
+	if k, err = keystore.Restore(tableName + "-keys"); err.ID != 0 {
+		t.Errorf("TestKeyScans restore error: %v", err)
+		return
+	}
+	checkScans("TestKeyScans (restore)")
+
+	// Many keys inserted out of order, over more than one Iterate batch
+	for i := 0; i < 500; i++ {
+		if _, err = k.InsertKey("n:"+fmt.Sprintf("%03d", i*7%500), nil); err.ID != 0 {
+			t.Errorf("TestKeyScans insert error: %v", err)
+			return
+		}
+	}
+	for i := 0; i < 500; i += 3 {
+		if err = k.DeleteKey("n:" + fmt.Sprintf("%03d", i)); err.ID != 0 {
+			t.Errorf("TestKeyScans delete error: %v", err)
+		}
+	}
+	var scanned []string
+	var page keystore.KeyPage
+	for {
+		if page, err = k.PrefixKeys("n:", page.Cursor, 150); err.ID != 0 {
+			t.Errorf("TestKeyScans scan error: %v", err)
+			return
+		}
+		scanned = append(scanned, page.Keys...)
+		if page.Cursor == "" {
+			break
+		}
+	}
+	var want []string
+	for i := 0; i < 500; i++ {
+		if i%3 != 0 {
+			want = append(want, "n:"+fmt.Sprintf("%03d", i))
+		}
+	}
+	if fmt.Sprint(scanned) != fmt.Sprint(want) {
+		t.Errorf("TestKeyScans expected %v keys in order from a paged scan, but got %v: %v", len(want), len(scanned), scanned)
+	}
+}
+
+// Checks a page of keys from a prefix scan of start, or a range scan from start to end
+func checkKeyPage(t *testing.T, test string, k *keystore.Keystore, prefix bool, start string, end string, cursor string, limit int, nextCursor string, keys ...string) {
+	var page keystore.KeyPage
+	var err helpers.Error
+	if prefix {
+		page, err = k.PrefixKeys(start, cursor, limit)
+	} else {
+		page, err = k.ScanKeys(start, end, cursor, limit)
+	}
+	if err.ID != 0 {
+		t.Errorf("%v error scanning from '%v': %v", test, start, err)
+		return
+	}
+	if page.Cursor != nextCursor || len(page.Keys) != len(keys) {
+		t.Errorf("%v expected %v and cursor '%v' from '%v', but got: %v", test, keys, nextCursor, start, page)
+		return
+	}
+	for i := range keys {
+		if page.Keys[i] != keys[i] {
+			t.Errorf("%v expected %v and cursor '%v' from '%v', but got: %v", test, keys, nextCursor, start, page)
+			return
+		}
+	}
+}
+
+func TestExpiration(t *testing.T) {
+	for _, encoding := range []string{helpers.EncodingJSON, helpers.EncodingBinary} {
+		testExpiration(t, encoding)
+	}
+}
+
+func testExpiration(t *testing.T, encoding string) {
+	test := "TestExpiration (" + encoding + ")"
+	s, sErr := schema.New(map[string]interface{}{
+		"token": []interface{}{"String", "", 0.0, false, false, true},
+	}, false)
+	if sErr.ID != 0 {
+		t.Errorf("%v error making schema: %v", test, sErr)
+		return
+	}
+	name := tableName + "-expire-" + encoding
+	k, kErr := keystore.New(name, nil, s, 0, false, false, "", encoding)
+	if kErr.ID != 0 {
+		t.Errorf("%v error making Keystore: %v", test, kErr)
+		return
+	}
+	defer func() { k.Delete() }()
+	if _, err := k.InsertKeyTTL("a", map[string]interface{}{"token": "t1"}, 100*time.Millisecond); err.ID != 0 {
+		t.Errorf("%v insert error: %v", test, err)
+	}
+	if _, err := k.InsertKeyTTL("b", map[string]interface{}{"token": "t2"}, time.Hour); err.ID != 0 {
+		t.Errorf("%v insert error: %v", test, err)
+	}
+	if _, err := k.UpsertKeyTTL("c", map[string]interface{}{"token": "t3"}, 0); err.ID != 0 {
+		t.Errorf("%v upsert error: %v", test, err)
+	}
+	if _, err := k.InsertKeyTTL("d", nil, -time.Second); err.ID != helpers.ErrorQueryInvalidFormat {
+		t.Errorf("%v expected error %v for a negative TTL, but got: %v", test, helpers.ErrorQueryInvalidFormat, err)
+	}
+
+	// Expiry times must be restored
+	k.Close(true)
+	var err helpers.Error
+	if k, err = keystore.Restore(name); err.ID != 0 {
+		t.Errorf("%v restore error: %v", test, err)
+		return
+	}
+	time.Sleep(150 * time.Millisecond)
+	if _, gErr := k.Get("a"); gErr != helpers.ErrorNoEntryFound {
+		t.Errorf("%v expected error %v getting an expired key, but got: %v", test, helpers.ErrorNoEntryFound, gErr)
+	}
+	// Scans hide expired keys before the expirer deletes them
+	if page, _ := k.ScanKeys("", "", "", 0); len(page.Keys) != 2 || page.Keys[0] != "b" || page.Keys[1] != "c" {
+		t.Errorf("%v expected keys [b c] scanning before the expirer runs, but got: %v", test, page.Keys)
+	}
+	if page, _ := k.PrefixKeys("a", "", 0); len(page.Keys) != 0 {
+		t.Errorf("%v expected no keys scanning an expired prefix, but got: %v", test, page.Keys)
+	}
+	if n := k.Size(); n != 2 {
+		t.Errorf("%v expected a size of 2 before the expirer runs, but got: %v", test, n)
+	}
+	if n := k.DeleteExpired(); n != 1 || k.Size() != 2 {
+		t.Errorf("%v expected to delete 1 expired key and keep 2, but deleted %v and kept %v", test, n, k.Size())
+	}
+	// The expired entry's unique value is free again
+	if _, err = k.InsertKey("a2", map[string]interface{}{"token": "t1"}); err.ID != 0 {
+		t.Errorf("%v insert error: %v", test, err)
+	}
+
+	// Refreshing
+	if err = k.RefreshKey("b", 0); err.ID != 0 {
+		t.Errorf("%v refresh error: %v", test, err)
+	}
+	if err = k.RefreshKey("c", time.Millisecond); err.ID != 0 {
+		t.Errorf("%v refresh error: %v", test, err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	// An expired key can be inserted before the expirer deletes it
+	if _, err = k.InsertKey("c", map[string]interface{}{"token": "t3"}); err.ID != 0 {
+		t.Errorf("%v expected to insert an expired key, but got: %v", test, err)
+	}
+	if _, err = k.UpsertKeyTTL("b", map[string]interface{}{"token": "t4"}, time.Millisecond); err.ID != 0 {
+		t.Errorf("%v upsert error: %v", test, err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if n := k.DeleteExpired(); n != 1 {
+		t.Errorf("%v expected to delete 1 expired key, but deleted %v", test, n)
+	}
+	// Refreshes move a key's expiry, so it only expires at the time it was last refreshed to
+	if _, err = k.InsertKeyTTL("e", map[string]interface{}{"token": "t5"}, time.Millisecond); err.ID != 0 {
+		t.Errorf("%v insert error: %v", test, err)
+	}
+	for i := 0; i < 100; i++ {
+		if err = k.RefreshKey("e", time.Hour); err.ID != 0 {
+			t.Errorf("%v refresh error: %v", test, err)
+			break
+		}
+	}
+	time.Sleep(5 * time.Millisecond)
+	if n := k.DeleteExpired(); n != 0 || k.Size() != 3 {
+		t.Errorf("%v expected a refreshed key to be kept, but deleted %v and kept %v", test, n, k.Size())
+	}
+	if err = k.RefreshKey("e", time.Millisecond); err.ID != 0 {
+		t.Errorf("%v refresh error: %v", test, err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if n := k.DeleteExpired(); n != 1 || k.Size() != 2 {
+		t.Errorf("%v expected to delete 1 refreshed key and keep 2, but deleted %v and kept %v", test, n, k.Size())
+	}
+
+	// Deleted lines must stay deleted
+	k.Close(true)
+	if k, err = keystore.Restore(name); err.ID != 0 {
+		t.Errorf("%v restore error: %v", test, err)
+		return
+	}
+	page, _ := k.ScanKeys("", "", "", 0)
+	if len(page.Keys) != 2 || page.Keys[0] != "a2" || page.Keys[1] != "c" {
+		t.Errorf("%v expected keys [a2 c] after restoring, but got: %v", test, page.Keys)
+	}
+	if errs := k.CheckIntegrity(); len(errs) != 0 {
+		t.Errorf("%v expected no integrity errors, but got: %v", test, errs)
+	}
+}
+
+func TestTableFull(t *testing.T) {
+	s, sErr := schema.New(map[string]interface{}{
+		"mmr": []interface{}{"Uint16", 0.0, 0.0, 0.0, false, false},
+	}, false)
+	if sErr.ID != 0 {
+		t.Errorf("TestTableFull error making schema: %v", sErr)
+		return
+	}
+	k, kErr := keystore.New(tableName+"-full", nil, s, 0, false, false, "", "")
+	if kErr.ID != 0 {
+		t.Errorf("TestTableFull error making Keystore: %v", kErr)
+		return
+	}
+	defer k.Delete()
+	if err := k.SetMaxEntries(1); err != 0 {
+		t.Errorf("TestTableFull error setting max entries: %v", err)
+	}
+	if _, err := k.InsertKey("a", nil); err.ID != 0 {
+		t.Errorf("TestTableFull insert error: %v", err)
+	}
+	if _, err := k.InsertKey("b", nil); err.ID != helpers.ErrorTableFull {
+		t.Errorf("TestTableFull expected error %v inserting past max entries, but got: %v", helpers.ErrorTableFull, err)
+	}
+	// The Keystore must still be usable
+	done := make(chan bool)
+	go func() {
+		k.InsertKey("c", nil)
+		_, gErr := k.Get("a")
+		done <- gErr == 0
+	}()
+	select {
+	case ok := <-done:
+		if !ok {
+			t.Errorf("TestTableFull expected to get a key from a full Keystore")
+		}
+	case <-time.After(time.Second):
+		t.Errorf("TestTableFull Keystore is locked after inserting past max entries")
+	}
+}
+
+func TestTransaction(t *testing.T) {
+	ps, sErr := schema.New(map[string]interface{}{
+		"name":  []interface{}{"String", "", 0.0, false, false, true},
+		"coins": []interface{}{"Uint32", 0.0, 0.0, 0.0, false, false, "ordered"},
+	}, false)
+	if sErr.ID != 0 {
+		t.Errorf("TestTransaction error making schema: %v", sErr)
+		return
+	}
+	is, sErr := schema.New(map[string]interface{}{
+		"owner": []interface{}{"String", "", 0.0, false, true, false},
+	}, false)
+	if sErr.ID != 0 {
+		t.Errorf("TestTransaction error making schema: %v", sErr)
+		return
+	}
+	playersName := tableName + "-tx-players"
+	players, kErr := keystore.New(playersName, nil, ps, 0, true, false, "", helpers.EncodingJSON)
+	if kErr.ID != 0 {
+		t.Errorf("TestTransaction error making Keystore: %v", kErr)
+		return
+	}
+	defer func() { players.Delete() }()
+	items, kErr := keystore.New(tableName+"-tx-items", nil, is, 0, false, false, "", helpers.EncodingBinary)
+	if kErr.ID != 0 {
+		t.Errorf("TestTransaction error making Keystore: %v", kErr)
+		return
+	}
+	defer items.Delete()
+	if _, err := players.InsertKey("Maya", map[string]interface{}{"name": "maya", "coins": 100.0}); err.ID != 0 {
+		t.Errorf("TestTransaction insert error: %v", err)
+	}
+	if _, err := players.InsertKey("Bill", map[string]interface{}{"name": "bill", "coins": 20.0}); err.ID != 0 {
+		t.Errorf("TestTransaction insert error: %v", err)
+	}
+	checkCoins := func(when string, maya float64, bill float64) {
+		for key, coins := range map[string]float64{"Maya": maya, "Bill": bill} {
+			obj, err := players.GetKey(key, map[string]interface{}{"coins": nil})
+			if err.ID != 0 {
+				t.Errorf("TestTransaction get error %v: %v", when, err)
+			} else if c, _ := obj["coins"].(uint32); float64(c) != coins {
+				t.Errorf("TestTransaction expected %v to have %v coins %v, but got: %v", key, coins, when, obj["coins"])
+			}
+		}
+	}
+
+	// Transfer between players, and give an item
+	err := keystore.Transaction([]keystore.Operation{
+		{Type: keystore.OperationUpdate, Table: players, Key: "Maya", Obj: map[string]interface{}{"coins.*sub": []interface{}{50.0}}},
+		{Type: keystore.OperationUpdate, Table: players, Key: "Bill", Obj: map[string]interface{}{"coins.*add": []interface{}{50.0}}},
+		{Type: keystore.OperationInsert, Table: items, Key: "sword", Obj: map[string]interface{}{"owner": "Bill"}},
+	})
+	if err.ID != 0 {
+		t.Errorf("TestTransaction transfer error: %v", err)
+	}
+	checkCoins("after a transfer", 50, 70)
+	if items.Size() != 1 {
+		t.Errorf("TestTransaction expected 1 item after a transfer, but got %v", items.Size())
+	}
+	if found, _ := players.GetKeys(map[string]interface{}{"coins.*gte": []interface{}{60.0}}, nil, "", false, 0, 0); len(found) != 1 || found[0].Key != "Bill" {
+		t.Errorf("TestTransaction expected the coins index to find [Bill], but got: %v", found)
+	}
+
+	// Failed transactions apply nothing
+	err = keystore.Transaction([]keystore.Operation{
+		{Type: keystore.OperationUpdate, Table: players, Key: "Maya", Obj: map[string]interface{}{"coins.*sub": []interface{}{10.0}}},
+		{Type: keystore.OperationInsert, Table: players, Key: "Joe", Obj: map[string]interface{}{"name": "bill"}},
+	})
+	if err.ID != helpers.ErrorUniqueValueDuplicate {
+		t.Errorf("TestTransaction expected error %v for a duplicate unique value, but got: %v", helpers.ErrorUniqueValueDuplicate, err)
+	}
+	err = keystore.Transaction([]keystore.Operation{
+		{Type: keystore.OperationUpdate, Table: players, Key: "Bill", Obj: map[string]interface{}{"coins.*sub": []interface{}{10.0}}},
+		{Type: keystore.OperationInsert, Table: items, Key: "shield", Obj: map[string]interface{}{}},
+	})
+	if err.ID == 0 {
+		t.Errorf("TestTransaction expected an error inserting an item without it's required owner")
+	}
+	err = keystore.Transaction([]keystore.Operation{
+		{Type: keystore.OperationDelete, Table: items, Key: "sword"},
+		{Type: keystore.OperationUpdate, Table: players, Key: "Nobody", Obj: map[string]interface{}{"coins": 1.0}},
+	})
+	if err.ID != helpers.ErrorNoEntryFound {
+		t.Errorf("TestTransaction expected error %v updating a missing key, but got: %v", helpers.ErrorNoEntryFound, err)
+	}
+	checkCoins("after failed transactions", 50, 70)
+	if players.Size() != 2 || items.Size() != 1 {
+		t.Errorf("TestTransaction expected 2 players and 1 item after failed transactions, but got %v and %v", players.Size(), items.Size())
+	}
+
+	// Unique values can be swapped
+	err = keystore.Transaction([]keystore.Operation{
+		{Type: keystore.OperationUpdate, Table: players, Key: "Maya", Obj: map[string]interface{}{"name": "bill"}},
+		{Type: keystore.OperationUpdate, Table: players, Key: "Bill", Obj: map[string]interface{}{"name": "maya"}},
+	})
+	if err.ID != 0 {
+		t.Errorf("TestTransaction swap error: %v", err)
+	}
+	if _, err = players.InsertKey("Joe", map[string]interface{}{"name": "maya"}); err.ID != helpers.ErrorUniqueValueDuplicate {
+		t.Errorf("TestTransaction expected error %v for a swapped unique value, but got: %v", helpers.ErrorUniqueValueDuplicate, err)
+	}
+
+	// Delete and insert in the same transaction
+	err = keystore.Transaction([]keystore.Operation{
+		{Type: keystore.OperationDelete, Table: items, Key: "sword"},
+		{Type: keystore.OperationInsert, Table: items, Key: "axe", Obj: map[string]interface{}{"owner": "Maya"}},
+		{Type: keystore.OperationInsert, Table: items, Key: "sword", Obj: map[string]interface{}{"owner": "Maya"}},
+	})
+	if err.ID != 0 {
+		t.Errorf("TestTransaction delete and insert error: %v", err)
+	}
+	if obj, gErr := items.GetKey("sword", nil); gErr.ID != 0 || obj["owner"] != "Maya" || items.Size() != 2 {
+		t.Errorf("TestTransaction expected 2 items with sword owned by Maya, but got %v items and: %v %v", items.Size(), obj, gErr)
+	}
+
+	// A transaction log left by a crash is finished by Restore
+	players.Close(true)
+	line := func(key string, name string, coins float64) []byte {
+		data := make([]interface{}, 2)
+		data[ps["name"].DataIndex()] = name
+		data[ps["coins"].DataIndex()] = coins
+		b, _ := json.Marshal(map[string]interface{}{"K": key, "D": data})
+		return b
+	}
+	logBytes, _ := json.Marshal(map[string]interface{}{"W": []map[string]interface{}{
+		{"T": playersName, "P": 0, "L": 1, "B": line("Maya", "bill", 5)},
+		{"T": playersName, "P": 0, "L": 4, "B": line("Zed", "zed", 1)},
+		{"T": tableName + "-tx-other", "P": 0, "L": 1, "B": []byte{}},
+	}})
+	folder := helpers.DataPath("Keystore-transactions")
+	logFile := filepath.Join(folder, "1-1.gdbtx")
+	os.MkdirAll(folder, os.ModePerm)
+	defer os.Remove(folder)
+	defer os.Remove(logFile)
+	if wErr := ioutil.WriteFile(logFile, logBytes, 0755); wErr != nil {
+		t.Errorf("TestTransaction error writing transaction log: %v", wErr)
+		return
+	}
+	if players, err = keystore.Restore(playersName); err.ID != 0 {
+		t.Errorf("TestTransaction restore error: %v", err)
+		return
+	}
+	checkCoins("after recovering a transaction", 5, 70)
+	if players.Size() != 3 {
+		t.Errorf("TestTransaction expected 3 players after recovering a transaction, but got %v", players.Size())
+	}
+	if errs := players.CheckIntegrity(); len(errs) != 0 {
+		t.Errorf("TestTransaction expected no integrity errors, but got: %v", errs)
+	}
+	// Only the other table's write is kept in the log
+	if b, rErr := ioutil.ReadFile(logFile); rErr != nil || bytes.Contains(b, []byte(playersName)) {
+		t.Errorf("TestTransaction expected the log to only keep the other table's write, but got: %s %v", b, rErr)
+	}
+}
+
+// Storage engine that fails it's next updates
+type failingEngine struct {
+	storage.Engine
+	fails *int32 // updates left to fail
+}
+
+func (e failingEngine) Update(partition uint32, line uint32, jData []byte) int {
+	if atomic.AddInt32(e.fails, -1) >= 0 {
+		return helpers.ErrorFileUpdate
+	}
+	return e.Engine.Update(partition, line, jData)
+}
+
+func TestTransactionFailedWrites(t *testing.T) {
+	var fails int32
+	storage.RegisterEngine("failing", func(folder string) storage.Engine {
+		se, _ := storage.NewEngine(storage.EngineMemory, folder)
+		return failingEngine{Engine: se, fails: &fails}
+	})
+	s, sErr := schema.New(map[string]interface{}{
+		"coins": []interface{}{"Uint32", 0.0, 0.0, 0.0, false, false},
+	}, false)
+	if sErr.ID != 0 {
+		t.Errorf("TestTransactionFailedWrites error making schema: %v", sErr)
+		return
+	}
+	name := tableName + "-tx-fail"
+	k, kErr := keystore.New(name, nil, s, 0, false, false, "failing", helpers.EncodingJSON)
+	if kErr.ID != 0 {
+		t.Errorf("TestTransactionFailedWrites error making Keystore: %v", kErr)
+		return
+	}
+	defer func() {
+		atomic.StoreInt32(&fails, 0)
+		k.Delete()
+	}()
+	if _, err := k.InsertKey("a", map[string]interface{}{"coins": 10.0}); err.ID != 0 {
+		t.Errorf("TestTransactionFailedWrites insert error: %v", err)
+	}
+	ops := []keystore.Operation{
+		{Type: keystore.OperationUpdate, Table: k, Key: "a", Obj: map[string]interface{}{"coins": 20.0}},
+		{Type: keystore.OperationInsert, Table: k, Key: "b", Obj: map[string]interface{}{"coins": 5.0}},
+	}
+	folder := helpers.DataPath("Keystore-transactions")
+	defer os.Remove(folder)
+	logs := func() []string {
+		var found []string
+		files, _ := filepath.Glob(filepath.Join(folder, "*.gdbtx"))
+		for _, file := range files {
+			if b, _ := ioutil.ReadFile(file); bytes.Contains(b, []byte(name)) {
+				found = append(found, file)
+			}
+		}
+		return found
+	}
+	checkCoins := func(when string, coins uint32, size int) {
+		if obj, err := k.GetKey("a", nil); err.ID != 0 || obj["coins"] != coins || k.Size() != size {
+			t.Errorf("TestTransactionFailedWrites expected %v coins and %v entries %v, but got %v entries and: %v %v", coins, size, when, k.Size(), obj, err)
+		}
+	}
+
+	// A write that fails twice is undone, and nothing is applied
+	atomic.StoreInt32(&fails, 2)
+	if err := keystore.Transaction(ops); err.ID != helpers.ErrorFileUpdate {
+		t.Errorf("TestTransactionFailedWrites expected error %v, but got: %v", helpers.ErrorFileUpdate, err)
+	}
+	checkCoins("after an undone transaction", 10, 1)
+	if found := logs(); len(found) != 0 {
+		t.Errorf("TestTransactionFailedWrites expected the log of an undone transaction to be removed, but got: %v", found)
+	}
+	if err := k.UpdateKey("a", map[string]interface{}{"coins": 11.0}); err.ID != 0 {
+		t.Errorf("TestTransactionFailedWrites update error: %v", err)
+	}
+
+	// When the undo fails too, the log is kept for Restore and the Keystore stops taking writes
+	atomic.StoreInt32(&fails, 1000)
+	if err := keystore.Transaction(ops); err.ID != helpers.ErrorTableInconsistent {
+		t.Errorf("TestTransactionFailedWrites expected error %v, but got: %v", helpers.ErrorTableInconsistent, err)
+	}
+	atomic.StoreInt32(&fails, 0)
+	checkCoins("after a failed undo", 11, 1)
+	found := logs()
+	for _, file := range found {
+		defer os.Remove(file)
+	}
+	if len(found) != 1 {
+		t.Errorf("TestTransactionFailedWrites expected the log of a failed undo to be kept, but got: %v", found)
+	}
+	if err := k.UpdateKey("a", map[string]interface{}{"coins": 12.0}); err.ID != helpers.ErrorTableInconsistent {
+		t.Errorf("TestTransactionFailedWrites expected error %v updating, but got: %v", helpers.ErrorTableInconsistent, err)
+	}
+	if _, err := k.InsertKey("c", map[string]interface{}{"coins": 1.0}); err.ID != helpers.ErrorTableInconsistent {
+		t.Errorf("TestTransactionFailedWrites expected error %v inserting, but got: %v", helpers.ErrorTableInconsistent, err)
+	}
+	if err := k.DeleteKey("a"); err.ID != helpers.ErrorTableInconsistent {
+		t.Errorf("TestTransactionFailedWrites expected error %v deleting, but got: %v", helpers.ErrorTableInconsistent, err)
+	}
+	if err := keystore.Transaction(ops); err.ID != helpers.ErrorTableInconsistent {
+		t.Errorf("TestTransactionFailedWrites expected error %v for another transaction, but got: %v", helpers.ErrorTableInconsistent, err)
+	}
+	if err := k.Compact(); err != helpers.ErrorTableInconsistent {
+		t.Errorf("TestTransactionFailedWrites expected error %v compacting, but got: %v", helpers.ErrorTableInconsistent, err)
+	}
+}
+
+func TestDataDirectory(t *testing.T) {
+	dir, dErr := ioutil.TempDir("", "gopherdb-")
+	if dErr != nil {
+		t.Errorf("TestDataDirectory error making folder: %v", dErr)
+		return
+	}
+	defer os.RemoveAll(dir)
+	storage.ShutDown()
+	defer func() {
+		storage.ShutDown()
+		storage.Init("")
+	}()
+	dataDir := filepath.Join(dir, "data")
+	if err := storage.Init(dataDir); err != 0 {
+		t.Errorf("TestDataDirectory error initializing storage: %v", err)
+		return
+	}
+	s, sErr := schema.New(map[string]interface{}{
+		"mmr": []interface{}{"Uint16", 0.0, 0.0, 0.0, false, false},
+	}, false)
+	if sErr.ID != 0 {
+		t.Errorf("TestDataDirectory error making schema: %v", sErr)
+		return
+	}
+	k, kErr := keystore.New(tableName+"-dir", nil, s, 0, true, false, "", "")
+	if kErr.ID != 0 {
+		t.Errorf("TestDataDirectory error making Keystore: %v", kErr)
+		return
+	}
+	if _, err := k.InsertKey("gopher", map[string]interface{}{"mmr": 1500}); err.ID != 0 {
+		t.Errorf("TestDataDirectory insert error: %v", err)
+	}
+	for _, name := range []string{"Keystore-" + tableName + "-dir" + helpers.FileTypeConfig, "Keystore-" + tableName + "-dir/0" + helpers.FileTypeStorage} {
+		if _, err := os.Stat(filepath.Join(dataDir, name)); err != nil {
+			t.Errorf("TestDataDirectory expected '%v' in the data directory, but got: %v", name, err)
+		}
+	}
+	if _, err := os.Stat("Keystore-" + tableName + "-dir" + helpers.FileTypeConfig); !os.IsNotExist(err) {
+		t.Errorf("TestDataDirectory expected no config file in the working directory, but got: %v", err)
+	}
+	if err := k.Delete(); err != 0 {
+		t.Errorf("TestDataDirectory delete error: %v", err)
+	}
+}
+
 // Must be last test!!
 func TestStorageShutdown(t *testing.T) {
 	storage.ShutDown()
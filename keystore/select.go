@@ -0,0 +1,168 @@
+/*
+keystore package Copyright 2020 Dominique Debergue
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at:
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+either express or implied. See the License for the specific
+language governing permissions and limitations under the License.
+*/
+
+package keystore
+
+import (
+	"github.com/hewiefreeman/GopherDB/helpers"
+	"github.com/hewiefreeman/GopherDB/schema"
+	"sort"
+)
+
+// KeyItems is the key and items of an entry from a multi-key get.
+type KeyItems struct {
+	Key   string
+	Items map[string]interface{}
+}
+
+// Example JSON for select query:
+//
+//     ["Select", "tableName", { *where (optional)* }, { *items to get (optional)* }, sortBy /* optional */, desc /* optional */, limit /* optional */, page /* optional */]
+//
+//  Get the names of players with over 1500 mmr that have the "gold" badge, highest mmr first, 10 at a time:
+//     ["Select", "players", {"mmr.*gt": [1500], "badges.*contains": ["gold"]}, {"name": null}, "mmr", true, 10, 0]
+//
+//  Every item of a where query must use methods that check to true or false (*eq, *gt, *lt, *gte, *lte,
+//  *contains, ...). Entries are in order of their keys when there is no sortBy. Where items and sortBy use the
+//  table's indexes when they can - see index.go
+
+// GetKeys gets the items of every entry that matches where, sorted by the sortBy item and split into pages of limit
+// entries. Gets every matching entry when limit is 0.
+func (k *Keystore) GetKeys(where map[string]interface{}, items map[string]interface{}, sortBy string, desc bool, limit int, page int) ([]KeyItems, helpers.Error) {
+	if limit < 0 || page < 0 {
+		return nil, helpers.NewError(helpers.ErrorQueryInvalidFormat, "")
+	}
+
+	// Get the keys of the entries that can match where from the indexes, in order of sortBy when it has an ordered
+	// index
+	var keys []string
+	k.uMux.Lock()
+	candidates, indexed := k.indexedKeys(where)
+	ix := k.indexes[sortBy]
+	sorted := ix != nil && ix.ordered()
+	if sorted {
+		keys = ix.orderedKeys(desc, candidates)
+	}
+	k.uMux.Unlock()
+	if !sorted {
+		if indexed {
+			keys = make([]string, 0, len(candidates))
+			for key := range candidates {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+		} else {
+			// Get every entry, already in order
+			k.eMux.Lock()
+			keys = k.allKeys()
+			k.eMux.Unlock()
+		}
+	}
+
+	// Get the data of the entries that match where - each entry's key is kept after it's data for sorting
+	var rows []interface{}
+	for _, key := range keys {
+		e, err := k.Get(key)
+		if err != 0 {
+			// Deleted since the keys were made
+			continue
+		}
+		data, err := k.entryData(e)
+		if err != 0 {
+			return nil, helpers.NewError(err, key)
+		}
+		match, wErr := k.matchesWhere(data, where)
+		if wErr.ID != 0 {
+			return nil, wErr
+		} else if match {
+			rows = append(rows, append(data[:len(data):len(data)], key))
+			// Rows from an ordered index are already sorted - stop at the end of the page
+			if sorted && limit > 0 && len(rows) == (page+1)*limit {
+				break
+			}
+		}
+	}
+
+	// Sort
+	if sortBy != "" && !sorted {
+		if err := k.schema.SortData(rows, sortBy, !desc); err != 0 {
+			return nil, helpers.NewError(err, sortBy)
+		}
+	}
+
+	// Get the page
+	if limit > 0 {
+		start := page * limit
+		if start >= len(rows) {
+			return []KeyItems{}, helpers.Error{}
+		}
+		end := start + limit
+		if end > len(rows) {
+			end = len(rows)
+		}
+		rows = rows[start:end]
+	}
+	results := make([]KeyItems, len(rows))
+	for i, r := range rows {
+		row := r.([]interface{})
+		// Each entry gets it's own copy of the items to get
+		var get map[string]interface{}
+		if len(items) > 0 {
+			get = make(map[string]interface{}, len(items))
+			for itemName, methodParams := range items {
+				get[itemName] = methodParams
+			}
+		}
+		key := row[len(row)-1].(string)
+		itemsOut, err := k.getItems(row[:len(row)-1], get)
+		if err.ID != 0 {
+			return nil, err
+		}
+		results[i] = KeyItems{Key: key, Items: itemsOut}
+	}
+	return results, helpers.Error{}
+}
+
+// Checks if an entry's data matches every item of a where query
+func (k *Keystore) matchesWhere(data []interface{}, where map[string]interface{}) (bool, helpers.Error) {
+	for itemName, methodParams := range where {
+		siName, itemMethods := schema.GetQueryItemMethods(itemName)
+		si := (k.schema)[siName]
+		if !si.QuickValidate() {
+			return false, helpers.NewError(helpers.ErrorInvalidItem, itemName)
+		} else if len(itemMethods) == 0 {
+			return false, helpers.NewError(helpers.ErrorInvalidWhereItem, itemName)
+		}
+		var i interface{}
+		if si.TypeName() == schema.ItemTypeBool {
+			// Bool items have no get methods - compare with *eq here
+			params, ok := methodParams.([]interface{})
+			if !ok || len(params) != 1 || len(itemMethods) != 1 || itemMethods[0] != schema.MethodEquals {
+				return false, helpers.NewError(helpers.ErrorInvalidWhereItem, itemName)
+			}
+			i = (data[si.DataIndex()] == params[0])
+		} else if err := schema.ItemFilter(methodParams, itemMethods, &i, data[si.DataIndex()], si, nil, k.EncryptCost(), true, false); err != 0 {
+			return false, helpers.NewError(err, itemName)
+		}
+		match, ok := i.(bool)
+		if !ok {
+			return false, helpers.NewError(helpers.ErrorInvalidWhereItem, itemName)
+		} else if !match {
+			return false, helpers.Error{}
+		}
+	}
+	return true, helpers.Error{}
+}
@@ -0,0 +1,162 @@
+/*
+keystore package Copyright 2020 Dominique Debergue
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at:
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+either express or implied. See the License for the specific
+language governing permissions and limitations under the License.
+*/
+
+package keystore
+
+// Skip lists
+//
+// A skipList keeps a set of values in order, with O(log n) inserts, removes and seeks. The keys of a Keystore and
+// the values of it's ordered indexes are kept in skipLists. A skipList isn't concurrently safe on it's own.
+
+const (
+	skipListMaxLevel = 32
+)
+
+type skipNode struct {
+	value interface{}
+	next  []*skipNode
+}
+
+type skipList struct {
+	head    skipNode
+	level   int                        // levels in use
+	length  int                        // values in the list
+	compare func(a, b interface{}) int // -1 when a is before b, 0 when equal, 1 when after
+	seed    uint64                     // random level state
+}
+
+func newSkipList(compare func(a, b interface{}) int) *skipList {
+	return &skipList{
+		head:    skipNode{next: make([]*skipNode, skipListMaxLevel)},
+		level:   1,
+		compare: compare,
+		seed:    0x9E3779B97F4A7C15,
+	}
+}
+
+// Compares strings for skipLists of keys
+func compareStrings(a, b interface{}) int {
+	as, bs := a.(string), b.(string)
+	if as < bs {
+		return -1
+	} else if as > bs {
+		return 1
+	}
+	return 0
+}
+
+// Gets a random level for a new node - each level is a quarter as likely as the one below it
+func (l *skipList) randomLevel() int {
+	// xorshift64
+	l.seed ^= l.seed << 13
+	l.seed ^= l.seed >> 7
+	l.seed ^= l.seed << 17
+	level := 1
+	for r := l.seed; level < skipListMaxLevel && r&3 == 0; r >>= 2 {
+		level++
+	}
+	return level
+}
+
+// Gets the last node before v on every level
+func (l *skipList) findPrev(v interface{}, prev *[skipListMaxLevel]*skipNode) {
+	n := &l.head
+	for i := l.level - 1; i >= 0; i-- {
+		for n.next[i] != nil && l.compare(n.next[i].value, v) < 0 {
+			n = n.next[i]
+		}
+		prev[i] = n
+	}
+}
+
+// Gets the amount of values in the list
+func (l *skipList) size() int {
+	return l.length
+}
+
+// Adds v to the list. Returns false when v is already in the list.
+func (l *skipList) insert(v interface{}) bool {
+	var prev [skipListMaxLevel]*skipNode
+	l.findPrev(v, &prev)
+	if n := prev[0].next[0]; n != nil && l.compare(n.value, v) == 0 {
+		return false
+	}
+	level := l.randomLevel()
+	for ; l.level < level; l.level++ {
+		prev[l.level] = &l.head
+	}
+	n := &skipNode{value: v, next: make([]*skipNode, level)}
+	for i := 0; i < level; i++ {
+		n.next[i] = prev[i].next[i]
+		prev[i].next[i] = n
+	}
+	l.length++
+	return true
+}
+
+// Removes v from the list. Returns false when v isn't in the list.
+func (l *skipList) remove(v interface{}) bool {
+	var prev [skipListMaxLevel]*skipNode
+	l.findPrev(v, &prev)
+	n := prev[0].next[0]
+	if n == nil || l.compare(n.value, v) != 0 {
+		return false
+	}
+	for i := 0; i < len(n.next); i++ {
+		prev[i].next[i] = n.next[i]
+	}
+	for l.level > 1 && l.head.next[l.level-1] == nil {
+		l.level--
+	}
+	l.length--
+	return true
+}
+
+// Gets the first node with a value equal to or after v - nil when there is none
+func (l *skipList) seek(v interface{}) *skipNode {
+	n := &l.head
+	for i := l.level - 1; i >= 0; i-- {
+		for n.next[i] != nil && l.compare(n.next[i].value, v) < 0 {
+			n = n.next[i]
+		}
+	}
+	return n.next[0]
+}
+
+// Gets the first node - nil when the list is empty
+func (l *skipList) first() *skipNode {
+	return l.head.next[0]
+}
+
+// Fills an empty list with values that are already in order, in O(n)
+func (l *skipList) build(values []interface{}) {
+	var tail [skipListMaxLevel]*skipNode
+	for i := range tail {
+		tail[i] = &l.head
+	}
+	for _, v := range values {
+		level := l.randomLevel()
+		if level > l.level {
+			l.level = level
+		}
+		n := &skipNode{value: v, next: make([]*skipNode, level)}
+		for i := 0; i < level; i++ {
+			tail[i].next[i] = n
+			tail[i] = n
+		}
+	}
+	l.length = len(values)
+}
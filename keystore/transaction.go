@@ -0,0 +1,737 @@
+/*
+keystore package Copyright 2020 Dominique Debergue
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at:
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+either express or implied. See the License for the specific
+language governing permissions and limitations under the License.
+*/
+
+package keystore
+
+import (
+	"github.com/hewiefreeman/GopherDB/helpers"
+	"github.com/hewiefreeman/GopherDB/schema"
+	"github.com/hewiefreeman/GopherDB/storage"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Transactions
+//
+// A Transaction applies Inserts, Updates and Deletes to entries of one or more Keystores all-or-nothing. Every
+// entry in the transaction is locked, in order of table and key, while the operations are run on copies of their
+// data. Schema validation, unique value checks and table limits all happen before anything is written.
+//
+// Lines with room for inserted entries are reserved, then the tables are unlocked - the keys of inserted entries are
+// held, and the entries and unique values stay locked. Every line the transaction writes is put in a transaction log
+// in the transactionsFolder - the transaction is committed once the log is on the disk. The lines are then written to
+// the data files, the partitions they're in are flushed to the disk, and the log is removed. A write that fails is
+// tried once more, and if it fails again, the lines are written back to what they were before the transaction, the
+// log is removed, and the transaction returns the error without applying anything to the tables.
+//
+// When a crash leaves a log behind, Restore writes the log's lines for it's table again before reading it's data, so
+// a committed transaction is never half applied. When the lines can't be written back, flushed, or the log can't be
+// removed, the log is kept for Restore in the same way, and the tables stop taking writes and compacting until they're
+// restored, so Restore never replays writes that the tables have moved past.
+
+const (
+	transactionsFolder  = "Keystore-transactions"
+	fileTypeTransaction = ".gdbtx"
+)
+
+// Transaction operation types
+const (
+	OperationInsert = "Insert"
+	OperationUpdate = "Update"
+	OperationDelete = "Delete"
+)
+
+var (
+	txMux   sync.Mutex // transaction log files lock
+	txCount uint64     // transaction logs made - makes their names unique
+)
+
+// Operation is an Insert, Update or Delete in a Transaction.
+type Operation struct {
+	Type  string                 // OperationInsert, OperationUpdate or OperationDelete
+	Table *Keystore              // Keystore of the entry
+	Key   string                 // key of the entry
+	Obj   map[string]interface{} // items to insert or update
+	TTL   time.Duration          // time until an inserted entry expires - 0 never expires
+}
+
+// Example JSON for transaction query:
+//
+//     ["Transaction", [ *Insert, Update and Delete queries* ]]
+//
+//  Move 50 coins from Maya to Bill, and give Bill a sword:
+//     ["Transaction", [["Update", "players", "Maya", {"coins.*sub": [50]}], ["Update", "players", "Bill", {"coins.*add": [50]}], ["Insert", "items", "sword-31", {"owner": "Bill"}]]]
+
+// An entry in a transaction
+type txEntry struct {
+	k       *Keystore
+	key     string
+	e       *keystoreEntry // entry before the transaction - nil when it doesn't exist
+	before  []interface{}  // data before the transaction
+	data    []interface{}  // data after the transaction - nil when it doesn't exist
+	expires int64
+	bytes   []byte // line written for the entry - empty when deleted
+	old     []byte // line before the transaction - empty when the entry didn't exist
+	file    uint32
+	line    uint32
+}
+
+// Unique values removed and added by a transaction, by table
+type txUniqueVals struct {
+	removed map[string]map[interface{}]bool
+	added   map[string]map[interface{}]bool
+}
+
+// Transaction log
+type txLog struct {
+	W []txWrite
+}
+
+// A line written by a transaction
+type txWrite struct {
+	T string // table name
+	P uint32 // partition
+	L uint32 // line
+	B []byte // line bytes - empty for a deleted entry
+}
+
+// Transaction applies ops in order, all-or-nothing. Nothing is applied when an operation fails.
+func Transaction(ops []Operation) helpers.Error {
+	if len(ops) == 0 {
+		return helpers.NewError(helpers.ErrorQueryInvalidFormat, "")
+	}
+
+	// Get the tables and entries in the transaction
+	entries := make(map[*Keystore]map[string]*txEntry)
+	var tables []*Keystore
+	var order []*txEntry
+	for _, op := range ops {
+		if op.Table == nil {
+			return helpers.NewError(helpers.ErrorTableDoesntExist, op.Key)
+		} else if op.Type != OperationInsert && op.Type != OperationUpdate && op.Type != OperationDelete {
+			return helpers.NewError(helpers.ErrorQueryInvalidFormat, op.Type)
+		} else if len(op.Key) == 0 {
+			return helpers.NewError(helpers.ErrorKeyRequired, "")
+		} else if op.TTL < 0 {
+			return helpers.NewError(helpers.ErrorQueryInvalidFormat, op.Key)
+		}
+		if entries[op.Table] == nil {
+			entries[op.Table] = make(map[string]*txEntry)
+			tables = append(tables, op.Table)
+		}
+		if entries[op.Table][op.Key] == nil {
+			te := &txEntry{k: op.Table, key: op.Key}
+			entries[op.Table][op.Key] = te
+			order = append(order, te)
+		}
+	}
+
+	// Lock order - by table name, then key
+	sort.Slice(tables, func(i, j int) bool { return tables[i].name < tables[j].name })
+	sort.Slice(order, func(i, j int) bool {
+		if order[i].k != order[j].k {
+			return order[i].k.name < order[j].k.name
+		}
+		return order[i].key < order[j].key
+	})
+
+	// Expired entries with the same keys are deleted first
+	for _, te := range order {
+		te.k.deleteIfExpired(te.key)
+	}
+
+	// Keep the entries in their lines until the transaction is done
+	for _, k := range tables {
+		k.pMux.RLock()
+		defer k.pMux.RUnlock()
+	}
+
+	// Lock the entries that exist, and get their data
+	now := time.Now().UnixNano()
+	for _, te := range order {
+		k := te.k
+		k.eMux.Lock()
+		e := k.entries[te.key]
+		k.eMux.Unlock()
+		if e == nil {
+			continue
+		}
+		e.mux.Lock()
+		if e.deleted || e.expired(now) {
+			e.mux.Unlock()
+			continue
+		}
+		defer e.mux.Unlock()
+		te.e = e
+		te.expires = e.expires
+		if k.dataOnDrive {
+			var err int
+			if te.before, err = k.dataFromDrive(e.persistFile, e.persistIndex); err != 0 {
+				return helpers.NewError(err, te.key)
+			}
+		} else {
+			te.before = e.data
+		}
+		te.data = append([]interface{}{}, te.before...)
+	}
+
+	// Tables stopped by a transaction before this one locked it's entries can't take writes
+	for _, k := range tables {
+		if err := k.writable(); err != 0 {
+			return helpers.NewError(err, k.name)
+		}
+	}
+
+	// Run the operations on the entries' data
+	for _, op := range ops {
+		if err := entries[op.Table][op.Key].run(op); err.ID != 0 {
+			return err
+		}
+	}
+
+	// Make the lines of the entries
+	for _, te := range order {
+		if te.k.memOnly {
+			continue
+		}
+		if te.e != nil {
+			if err := te.k.makeEntryBytes(te.key, te.before, te.e.expires, &te.old); err != 0 {
+				return helpers.NewError(err, te.key)
+			}
+		}
+		if te.data == nil {
+			continue
+		}
+		if err := te.k.makeEntryBytes(te.key, te.data, te.expires, &te.bytes); err != 0 {
+			return helpers.NewError(err, te.key)
+		}
+	}
+
+	// Lock the tables
+	for _, k := range tables {
+		k.eMux.Lock()
+	}
+	for _, k := range tables {
+		k.uMux.Lock()
+	}
+	unlockTables := func() {
+		for _, k := range tables {
+			k.uMux.Unlock()
+			k.eMux.Unlock()
+		}
+	}
+
+	// Check keys, table limits and unique values
+	uniqueVals, err := checkTransaction(tables, order)
+	if err.ID != 0 {
+		unlockTables()
+		return err
+	}
+
+	// Reserve lines with room for inserted entries, so writing them doesn't move the lines
+	var reserved []*txEntry
+	releaseLines := func() {
+		// Reserved lines stay empty
+		for _, te := range reserved {
+			te.k.compactor.AddDead(te.file, 1)
+		}
+	}
+	for _, te := range order {
+		k := te.k
+		if te.e != nil {
+			te.file, te.line = te.e.persistFile, te.e.persistIndex
+			continue
+		} else if k.memOnly || te.data == nil {
+			continue
+		}
+		line, lErr := storage.ReserveLine(k.engine, k.fileOn, te.bytes)
+		if lErr != 0 {
+			releaseLines()
+			unlockTables()
+			return helpers.NewError(lErr, te.key)
+		}
+		te.file, te.line = k.fileOn, line
+		reserved = append(reserved, te)
+		// Increase fileOn when the index has reached or surpassed partitionMax
+		if line >= k.partitionMax.Load().(uint32) {
+			k.fileOn++
+			writeConfigFile(k.configFile, k.makeDefaultConfig(k.fileOn))
+		}
+	}
+
+	// Hold the keys of inserted entries and unlock eMux, so the writes don't hold up the rest of the tables. The
+	// entries and uMux stay locked until the transaction is applied, like a single key's update.
+	for _, te := range order {
+		if te.e == nil && te.data != nil {
+			te.k.pending[te.key] = true
+		}
+	}
+	for _, k := range tables {
+		k.eMux.Unlock()
+	}
+	abort := func() {
+		releaseLines()
+		for _, k := range tables {
+			k.uMux.Unlock()
+		}
+		for _, k := range tables {
+			k.eMux.Lock()
+		}
+		for _, te := range order {
+			if te.e == nil && te.data != nil {
+				delete(te.k.pending, te.key)
+			}
+		}
+		for _, k := range tables {
+			k.eMux.Unlock()
+		}
+	}
+
+	// Commit
+	var writes []txWrite
+	for _, te := range order {
+		if !te.k.memOnly && (te.e != nil || te.data != nil) {
+			writes = append(writes, txWrite{T: te.k.name, P: te.file, L: te.line, B: te.bytes})
+		}
+	}
+	var logFile string
+	if len(writes) > 0 {
+		var lErr int
+		if logFile, lErr = writeTransactionLog(writes); lErr != 0 {
+			abort()
+			return helpers.NewError(lErr, "")
+		}
+	}
+
+	// Write the lines - when a write fails, the lines are written back and nothing is applied to the tables
+	if wErr := writeTransaction(order, false); wErr.ID != 0 {
+		if uErr := writeTransaction(order, true); uErr.ID != 0 {
+			// Restore finishes the transaction from it's log
+			helpers.LogAndPrint("Failed to undo the writes of a failed transaction - kept transaction log '"+logFile+"' for the next Restore of it's tables", 5)
+			stopWrites(tables)
+			abort()
+			return helpers.NewError(helpers.ErrorTableInconsistent, wErr.From)
+		}
+		if rErr := removeTransactionLog(logFile); rErr != 0 {
+			// Restore would finish the undone transaction from it's log
+			stopWrites(tables)
+			abort()
+			return helpers.NewError(helpers.ErrorTableInconsistent, logFile)
+		}
+		abort()
+		return wErr
+	}
+
+	// Flush the lines, and remove the log. When either fails, the log is kept for the next Restore of the tables.
+	var result helpers.Error
+	if logFile != "" {
+		if sErr := syncTransaction(order); sErr.ID != 0 {
+			helpers.LogAndPrint("Kept transaction log '"+logFile+"' for the next Restore of it's tables", 5)
+			stopWrites(tables)
+			result = sErr
+		} else if rErr := removeTransactionLog(logFile); rErr != 0 {
+			stopWrites(tables)
+			result = helpers.NewError(rErr, logFile)
+		}
+	}
+
+	// Apply to the tables - unique values and indexes first, then the entries
+	for _, k := range tables {
+		for itemName, vals := range uniqueVals[k].removed {
+			for v := range vals {
+				delete(k.uniqueVals[itemName], v)
+			}
+		}
+		for itemName, vals := range uniqueVals[k].added {
+			if k.uniqueVals[itemName] == nil {
+				k.uniqueVals[itemName] = make(map[interface{}]bool)
+			}
+			for v := range vals {
+				k.uniqueVals[itemName][v] = true
+			}
+		}
+	}
+	for _, te := range order {
+		k := te.k
+		if te.e != nil {
+			k.removeFromIndexes(te.key, k.indexValues(te.before))
+		}
+		if te.data != nil {
+			k.addToIndexes(te.key, k.indexValues(te.data))
+		}
+		if te.e != nil && te.data != nil && !k.dataOnDrive {
+			te.e.data = te.data
+		} else if te.e != nil && te.data == nil {
+			te.e.deleted = true
+		}
+	}
+	for _, k := range tables {
+		k.uMux.Unlock()
+	}
+	for _, k := range tables {
+		k.eMux.Lock()
+	}
+	var deleted []*txEntry
+	for _, te := range order {
+		k := te.k
+		switch {
+		case te.e != nil && te.data != nil:
+			// Updated
+			if te.expires != te.e.expires {
+				te.e.expires = te.expires
+				k.scheduleExpiry(te.key, te.e)
+			}
+		case te.e != nil:
+			// Deleted
+			delete(k.entries, te.key)
+			k.removeKey(te.key)
+			k.removeExpiry(te.e)
+			deleted = append(deleted, te)
+		case te.data != nil:
+			// Inserted
+			e := &keystoreEntry{persistFile: te.file, persistIndex: te.line, data: te.data, expires: te.expires}
+			if k.dataOnDrive {
+				e.data = nil
+			}
+			delete(k.pending, te.key)
+			k.entries[te.key] = e
+			k.addKey(te.key)
+			k.scheduleExpiry(te.key, e)
+		}
+	}
+	for _, k := range tables {
+		k.eMux.Unlock()
+	}
+
+	// Count the deleted lines towards compaction
+	for _, te := range deleted {
+		if !te.k.memOnly {
+			te.k.addDeadLine(te.file)
+		}
+	}
+
+	return result
+}
+
+// Stops writes to tables that a transaction left out of line with their data files, until a Restore finishes the
+// transaction from it's log. Must lock the entries of the transaction before-hand, so no write gets past them.
+func stopWrites(tables []*Keystore) {
+	for _, k := range tables {
+		atomic.StoreInt32(&k.inconsistent, 1)
+		k.compactor.Stop()
+	}
+}
+
+// Checks if a Keystore takes writes - a Keystore stops taking writes when a transaction leaves it out of line with
+// it's data files
+func (k *Keystore) writable() int {
+	if atomic.LoadInt32(&k.inconsistent) != 0 {
+		return helpers.ErrorTableInconsistent
+	}
+	return 0
+}
+
+// Writes the lines of a transaction's entries, or their lines from before the transaction when undo is true. A write
+// that fails is tried once more. Stops at the first failed write, unless undo is true.
+func writeTransaction(order []*txEntry, undo bool) helpers.Error {
+	var wErr helpers.Error
+	for _, te := range order {
+		if te.k.memOnly || (te.e == nil && te.data == nil) {
+			continue
+		}
+		b := te.bytes
+		if undo {
+			b = te.old
+		}
+		err := te.k.engine.Update(te.file, te.line, b)
+		if err != 0 {
+			err = te.k.engine.Update(te.file, te.line, b)
+		}
+		if err == 0 {
+			continue
+		}
+		helpers.LogAndPrint("Failed to write line "+strconv.Itoa(int(te.line))+" of partition "+strconv.Itoa(int(te.file))+" of Keystore '"+te.k.name+"' for a transaction, with error code: "+strconv.Itoa(err), 5)
+		if !undo {
+			return helpers.NewError(err, te.key)
+		} else if wErr.ID == 0 {
+			wErr = helpers.NewError(err, te.key)
+		}
+	}
+	return wErr
+}
+
+// Flushes the partitions written by a transaction to the disk, and returns the first error
+func syncTransaction(order []*txEntry) helpers.Error {
+	type partition struct {
+		k    *Keystore
+		file uint32
+	}
+	synced := make(map[partition]bool)
+	for _, te := range order {
+		p := partition{te.k, te.file}
+		if te.k.memOnly || (te.e == nil && te.data == nil) || synced[p] {
+			continue
+		}
+		synced[p] = true
+		if err := storage.SyncPartition(te.k.engine, te.file); err != 0 {
+			helpers.LogAndPrint("Failed to flush partition "+strconv.Itoa(int(te.file))+" of Keystore '"+te.k.name+"' for a transaction, with error code: "+strconv.Itoa(err), 5)
+			return helpers.NewError(err, te.k.name)
+		}
+	}
+	return helpers.Error{}
+}
+
+// Runs an operation on a transaction entry's data
+func (te *txEntry) run(op Operation) helpers.Error {
+	k := te.k
+	switch op.Type {
+	case OperationInsert:
+		if te.data != nil {
+			return helpers.NewError(helpers.ErrorKeyInUse, te.key)
+		} else if strings.ContainsAny(te.key, ".*\t\n\r") {
+			return helpers.NewError(helpers.ErrorInvalidKeyCharacters, te.key)
+		}
+		data := make([]interface{}, len(k.schema), len(k.schema))
+		uniqueVals := make(map[string]interface{})
+		for itemName, schemaItem := range k.schema {
+			err := schema.ItemFilter(op.Obj[itemName], nil, &data[schemaItem.DataIndex()], nil, schemaItem, &uniqueVals, k.EncryptCost(), false, false)
+			if err != 0 {
+				return helpers.NewError(err, itemName)
+			}
+		}
+		te.data = data
+		te.expires = expiresAt(op.TTL)
+
+	case OperationUpdate:
+		if te.data == nil {
+			return helpers.NewError(helpers.ErrorNoEntryFound, te.key)
+		} else if len(op.Obj) == 0 {
+			return helpers.NewError(helpers.ErrorQueryInvalidFormat, te.key)
+		}
+		data := append([]interface{}{}, te.data...)
+		uniqueVals := make(map[string]interface{})
+		for updateName, updateItem := range op.Obj {
+			uName, itemMethods := schema.GetQueryItemMethods(updateName)
+			schemaItem := k.schema[uName]
+			if !schemaItem.QuickValidate() {
+				return helpers.NewError(helpers.ErrorSchemaInvalid, updateName)
+			}
+			itemBefore := data[schemaItem.DataIndex()]
+			err := schema.ItemFilter(updateItem, itemMethods, &data[schemaItem.DataIndex()], itemBefore, schemaItem, &uniqueVals, k.EncryptCost(), false, false)
+			if err != 0 {
+				return helpers.NewError(err, updateName)
+			}
+		}
+		te.data = data
+
+	case OperationDelete:
+		if te.data == nil {
+			return helpers.NewError(helpers.ErrorNoEntryFound, te.key)
+		}
+		te.data = nil
+	}
+	return helpers.Error{}
+}
+
+// Checks that the keys of inserted entries are free, that the tables have room for them, and that the unique values
+// of the entries after the transaction aren't in use. Returns the unique values removed and added by the
+// transaction. Must lock the tables' eMux and uMux before-hand.
+func checkTransaction(tables []*Keystore, order []*txEntry) (map[*Keystore]txUniqueVals, helpers.Error) {
+	uniqueVals := make(map[*Keystore]txUniqueVals, len(tables))
+	added := make(map[*Keystore]int)
+	for _, k := range tables {
+		uniqueVals[k] = txUniqueVals{removed: make(map[string]map[interface{}]bool), added: make(map[string]map[interface{}]bool)}
+	}
+	for _, te := range order {
+		if te.e == nil && te.data != nil {
+			if te.k.entries[te.key] != nil || te.k.pending[te.key] {
+				return nil, helpers.NewError(helpers.ErrorKeyInUse, te.key)
+			}
+			added[te.k]++
+		} else if te.e != nil && te.data == nil {
+			added[te.k]--
+		}
+		if te.e == nil {
+			continue
+		}
+		vals, err := te.k.uniqueValues(te.before)
+		if err != 0 {
+			return nil, helpers.NewError(err, te.key)
+		}
+		for itemName, v := range vals {
+			if uniqueVals[te.k].removed[itemName] == nil {
+				uniqueVals[te.k].removed[itemName] = make(map[interface{}]bool)
+			}
+			uniqueVals[te.k].removed[itemName][v] = true
+		}
+	}
+	for _, k := range tables {
+		maxEntries := k.maxEntries.Load().(uint64)
+		if maxEntries > 0 && added[k] > 0 && len(k.entries)+len(k.pending)+added[k] > int(maxEntries) {
+			return nil, helpers.NewError(helpers.ErrorTableFull, k.name)
+		}
+	}
+	for _, te := range order {
+		if te.data == nil {
+			continue
+		}
+		u := uniqueVals[te.k]
+		vals, err := te.k.uniqueValues(te.data)
+		if err != 0 {
+			return nil, helpers.NewError(err, te.key)
+		}
+		for itemName, v := range vals {
+			if v == nil {
+				continue
+			} else if u.added[itemName][v] || (te.k.uniqueVals[itemName][v] && !u.removed[itemName][v]) {
+				return nil, helpers.NewError(helpers.ErrorUniqueValueDuplicate, itemName)
+			}
+			if u.added[itemName] == nil {
+				u.added[itemName] = make(map[interface{}]bool)
+			}
+			u.added[itemName][v] = true
+		}
+	}
+	return uniqueVals, helpers.Error{}
+}
+
+// Writes a transaction log to the disk, and returns it's file name. The log is written to a temporary file first,
+// so a log is either whole or missing after a crash.
+func writeTransactionLog(writes []txWrite) (string, int) {
+	b, jErr := helpers.Fjson.Marshal(txLog{W: writes})
+	if jErr != nil {
+		return "", helpers.ErrorJsonEncoding
+	}
+	folder := helpers.DataPath(transactionsFolder)
+	if err := os.MkdirAll(folder, os.ModePerm); err != nil {
+		return "", helpers.ErrorCreatingFolder
+	}
+	name := filepath.Join(folder, strconv.FormatInt(time.Now().UnixNano(), 10)+"-"+strconv.FormatUint(atomic.AddUint64(&txCount, 1), 10)+fileTypeTransaction)
+	txMux.Lock()
+	defer txMux.Unlock()
+	if err := writeFileSynced(name, b); err != 0 {
+		return "", err
+	}
+	return name, 0
+}
+
+// Removes a transaction log once it's writes are done or undone. A log that can't be removed would be replayed by
+// the next Restore, so the caller must stop writes to it's tables.
+func removeTransactionLog(name string) int {
+	if name == "" {
+		return 0
+	}
+	txMux.Lock()
+	defer txMux.Unlock()
+	if err := os.Remove(name); err != nil {
+		helpers.LogAndPrint("Failed to remove transaction log '"+name+"': "+err.Error(), 5)
+		return helpers.ErrorFileDelete
+	}
+	return 0
+}
+
+// Writes a file through a temporary file, and flushes it to the disk
+func writeFileSynced(name string, b []byte) int {
+	tmp := name + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		return helpers.ErrorFileOpen
+	}
+	if _, err = f.Write(b); err == nil {
+		err = f.Sync()
+	}
+	f.Close()
+	if err != nil {
+		os.Remove(tmp)
+		return helpers.ErrorFileWrite
+	}
+	if err = os.Rename(tmp, name); err != nil {
+		os.Remove(tmp)
+		return helpers.ErrorFileWrite
+	}
+	// Flush the rename
+	if d, dErr := os.Open(filepath.Dir(name)); dErr == nil {
+		d.Sync()
+		d.Close()
+	}
+	return 0
+}
+
+// Writes the lines of a table left in transaction logs by a crash - called by Restore before it reads the table's
+// data. The table's lines are taken out of each log, and a log is removed once it has no lines left.
+func recoverTransactions(name string, se storage.Engine) int {
+	txMux.Lock()
+	defer txMux.Unlock()
+	files, _ := filepath.Glob(filepath.Join(helpers.DataPath(transactionsFolder), "*"+fileTypeTransaction))
+	for _, file := range files {
+		b, rErr := os.ReadFile(file)
+		if rErr != nil {
+			return helpers.ErrorFileRead
+		}
+		var log txLog
+		if jErr := helpers.Fjson.Unmarshal(b, &log); jErr != nil {
+			return helpers.ErrorJsonDecoding
+		}
+		keep := make([]txWrite, 0, len(log.W))
+		for _, w := range log.W {
+			if w.T != name {
+				keep = append(keep, w)
+				continue
+			}
+			if err := replayTransactionWrite(se, w); err != 0 {
+				return err
+			}
+		}
+		if len(keep) == len(log.W) {
+			continue
+		}
+		helpers.LogAndPrint("Finished the writes of transaction log '"+file+"' for Keystore '"+name+"'", 4)
+		if len(keep) == 0 {
+			if err := os.Remove(file); err != nil {
+				return helpers.ErrorFileDelete
+			}
+			continue
+		}
+		nb, jErr := helpers.Fjson.Marshal(txLog{W: keep})
+		if jErr != nil {
+			return helpers.ErrorJsonEncoding
+		}
+		if err := writeFileSynced(file, nb); err != 0 {
+			return err
+		}
+	}
+	return 0
+}
+
+// Writes a line from a transaction log again. Reserved lines lost in the crash are added back first.
+func replayTransactionWrite(se storage.Engine, w txWrite) int {
+	lines, err := se.Lines(w.P)
+	if err != 0 {
+		return err
+	}
+	for ; lines < int(w.L); lines++ {
+		if _, err = se.Insert(w.P, []byte{}); err != 0 {
+			return err
+		}
+	}
+	return se.Update(w.P, w.L, w.B)
+}
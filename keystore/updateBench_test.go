@@ -0,0 +1,79 @@
+package keystore
+
+import (
+	"github.com/hewiefreeman/GopherDB/keystore"
+	"github.com/hewiefreeman/GopherDB/schema"
+	"github.com/hewiefreeman/GopherDB/storage"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TO TEST:
+// go test updateBench_test.go -run=NONE -bench=.
+//
+// Makes a new Keystore for each partition size, fills one partition, then updates the first and last
+// entry of the partition. The cost of an update should not depend on where the entry is in it's partition.
+//
+// WARNING: These benchmarks write to the disk many times. Please run them on disk storage.
+
+const (
+	benchTableName string = "bench-update"
+)
+
+var (
+	benchPartitionSizes []int  = []int{250, 2500, 10000}
+	benchBio            string = strings.Repeat("gopher ", 30)
+)
+
+func BenchmarkUpdateFirstOfPartition(b *testing.B) {
+	for _, size := range benchPartitionSizes {
+		b.Run("lines-"+strconv.Itoa(size), func(b *testing.B) {
+			benchmarkUpdate(b, size, "0")
+		})
+	}
+}
+
+func BenchmarkUpdateLastOfPartition(b *testing.B) {
+	for _, size := range benchPartitionSizes {
+		b.Run("lines-"+strconv.Itoa(size), func(b *testing.B) {
+			benchmarkUpdate(b, size, strconv.Itoa(size-1))
+		})
+	}
+}
+
+func benchmarkUpdate(b *testing.B, size int, key string) {
+	storage.Init("")
+	defer storage.ShutDown()
+	s, sErr := schema.New(map[string]interface{}{
+		"mmr": []interface{}{"Uint16", 0.0, 0.0, 0.0, false, false},
+		"bio": []interface{}{"String", "", 0.0, false, false, false},
+	}, false)
+	if sErr.ID != 0 {
+		b.Fatalf("Error making schema: %v", sErr)
+	}
+	// Remove a Keystore left behind by a failed benchmark
+	if k, err := keystore.Restore(benchTableName); err.ID == 0 {
+		k.Delete()
+	}
+	k, kErr := keystore.New(benchTableName, nil, s, 0, false, false, "", "")
+	if kErr.ID != 0 {
+		b.Fatalf("Error making Keystore: %v", kErr)
+	}
+	defer k.Delete()
+	if err := k.SetPartitionMax(uint32(size)); err != 0 {
+		b.Fatalf("Error setting partitionMax: %v", err)
+	}
+	for i := 0; i < size; i++ {
+		if _, err := k.InsertKey(strconv.Itoa(i), map[string]interface{}{"mmr": 1000, "bio": benchBio}); err.ID != 0 {
+			b.Fatalf("Insert error (%v): %v", i, err)
+		}
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := k.UpdateKey(key, map[string]interface{}{"mmr": 1000 + (i % 2)}); err.ID != 0 {
+			b.Fatalf("Update error (%v): %v", i, err)
+		}
+	}
+}
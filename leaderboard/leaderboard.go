@@ -1,27 +1,66 @@
+/*
+leaderboard package Copyright 2020 Dominique Debergue
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at:
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+either express or implied. See the License for the specific
+language governing permissions and limitations under the License.
+*/
+
 package leaderboard
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/hewiefreeman/GopherDB/helpers"
+	"github.com/hewiefreeman/GopherDB/storage"
+	"github.com/schollz/progressbar"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 )
 
 // Potential memory leak problem? Reference: https://github.com/golang/go/wiki/SliceTricks#delete-without-preserving-order
 
+// File/folder prefixes
+const (
+	dataFolderPrefix = "Leaderboard-"
+)
+
 var (
 	leaderboardsMux sync.Mutex
 	leaderboards    map[string]*Leaderboard = make(map[string]*Leaderboard)
 )
 
 type Leaderboard struct {
-	maxEntries    uint64
+	// Settings - read only
+	name          string
+	maxEntries    int
 	dupePushAbove bool
 	alwaysReplace bool
+	ascending     bool // lower targets are better
+	timeTieBreak  bool // ties are ordered by push time, overrides dupePushAbove
+	partitionMax  uint32
+	configFile    *os.File
 
-	mux     sync.Mutex
-	least   float64
-	most    float64
-	entries []*LeaderboardEntry
+	mux        sync.Mutex
+	syncPolicy uint8   // locked by mux - storage sync policy of data files
+	fileOn     uint32  // locked by mux
+	pushes     uint64  // locked by mux - number of saved pushes, used to order ties when restoring
+	least      float64 // target of the last entry (the highest when ascending)
+	most       float64 // target of the first entry (the lowest when ascending)
+	entries    []*LeaderboardEntry
 }
 
 // LeaderboardEntry is sorted in Leaderboard entries by the target.
@@ -29,16 +68,127 @@ type LeaderboardEntry struct {
 	name   string
 	target float64
 	extra  map[string]interface{}
+
+	time int64 // push time in unix nanoseconds
+
+	persistFile  uint32
+	persistIndex uint32
+	push         uint64
+}
+
+// Name returns the name of the entry
+func (e LeaderboardEntry) Name() string {
+	return e.name
+}
+
+// Target returns the target the entry is sorted by
+func (e LeaderboardEntry) Target() float64 {
+	return e.target
+}
+
+// Extra returns the extra data pushed with the entry
+func (e LeaderboardEntry) Extra() map[string]interface{} {
+	return e.extra
+}
+
+// Time returns the time the entry was pushed
+func (e LeaderboardEntry) Time() time.Time {
+	return time.Unix(0, e.time)
+}
+
+type leaderboardConfig struct {
+	FormatVersion uint8
+	Name          string
+	FileOn        uint32
+	PartitionMax  uint32
+	MaxEntries    int
+	DupePushAbove bool
+	AlwaysReplace bool
+	Ascending     bool
+	TimeTieBreak  bool
+	SyncPolicy    uint8
+}
+
+// Format of a LeaderboardEntry in storage
+type jsonEntry struct {
+	N string
+	T float64
+	E map[string]interface{}
+	S int64
+	P uint64
 }
 
-// New creates a new leaderboard.
-func New(name string, maxEntries int, dupePushAbove bool, alwaysReplace bool) (*Leaderboard, int) {
+//////////////////////////////////////////////////////////////////////////////////////////////////////
+//   Leaderboard   ///////////////////////////////////////////////////////////////////////////////////
+//////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// New creates a new leaderboard. A maxEntries of 0 or less makes a leaderboard with no entry limit. Higher targets
+// are better unless ascending is true. Entries with the same target are ordered by who reached it first when
+// timeTieBreak is true, otherwise newer pushes are placed above them when dupePushAbove is true.
+func New(name string, maxEntries int, dupePushAbove bool, alwaysReplace bool, ascending bool, timeTieBreak bool) (*Leaderboard, int) {
+	return newLeaderboard(name, nil, leaderboardConfig{
+		FormatVersion: helpers.FormatVersion,
+		Name:          name,
+		PartitionMax:  helpers.DefaultPartitionMax,
+		MaxEntries:    maxEntries,
+		DupePushAbove: dupePushAbove,
+		AlwaysReplace: alwaysReplace,
+		Ascending:     ascending,
+		TimeTieBreak:  timeTieBreak,
+		SyncPolicy:    helpers.DefaultSyncPolicy,
+	})
+}
+
+// Makes a Leaderboard from a config. A new config file and data folder are made when configFile is nil.
+func newLeaderboard(name string, configFile *os.File, conf leaderboardConfig) (*Leaderboard, int) {
+	if len(name) == 0 {
+		return nil, helpers.ErrorTableNameRequired
+	}
 	leaderboardsMux.Lock()
 	if leaderboards[name] != nil {
 		leaderboardsMux.Unlock()
 		return nil, helpers.ErrorLeaderboardExists
 	}
-	lb := &Leaderboard{maxEntries: maxEntries, dupePushAbove: dupePushAbove, alwaysReplace: alwaysReplace, entries: make([]*LeaderboardEntry, 0)}
+	if conf.PartitionMax < helpers.PartitionMin {
+		conf.PartitionMax = helpers.DefaultPartitionMax
+	}
+
+	// Restoring if configFile is not nil
+	if configFile == nil {
+		namePre := helpers.DataPath(dataFolderPrefix + name)
+		var err error
+		// Make leaderboard storage folder
+		if err = storage.MakeDir(namePre); err != nil {
+			leaderboardsMux.Unlock()
+			return nil, helpers.ErrorCreatingFolder
+		}
+		// Create/open config file
+		if configFile, err = os.OpenFile(namePre+helpers.FileTypeConfig, os.O_RDWR|os.O_CREATE, 0755); err != nil {
+			leaderboardsMux.Unlock()
+			return nil, helpers.ErrorFileOpen
+		}
+		// Write config file
+		if wErr := writeConfigFile(configFile, conf); wErr != 0 {
+			leaderboardsMux.Unlock()
+			configFile.Close()
+			return nil, wErr
+		}
+	}
+
+	lb := &Leaderboard{
+		name:          name,
+		maxEntries:    conf.MaxEntries,
+		dupePushAbove: conf.DupePushAbove,
+		alwaysReplace: conf.AlwaysReplace,
+		ascending:     conf.Ascending,
+		timeTieBreak:  conf.TimeTieBreak,
+		partitionMax:  conf.PartitionMax,
+		configFile:    configFile,
+		syncPolicy:    conf.SyncPolicy,
+		fileOn:        conf.FileOn,
+		entries:       make([]*LeaderboardEntry, 0),
+	}
+	storage.SetSyncPolicy(helpers.DataPath(dataFolderPrefix+name), conf.SyncPolicy)
 	leaderboards[name] = lb
 	leaderboardsMux.Unlock()
 
@@ -57,6 +207,69 @@ func Get(name string) (*Leaderboard, int) {
 	return lb, 0
 }
 
+// Close a Leaderboard and save current settings to it's config file if `save` is true
+func (l *Leaderboard) Close(save bool) {
+	if save {
+		l.mux.Lock()
+		conf := l.makeConfig(l.fileOn)
+		l.mux.Unlock()
+		if err := writeConfigFile(l.configFile, conf); err != 0 {
+			helpers.LogAndPrint("Failed to write config file for Leaderboard '"+l.name+"' while closing, with error code: "+strconv.Itoa(err), 5)
+		}
+	}
+	l.configFile.Close()
+
+	leaderboardsMux.Lock()
+	delete(leaderboards, l.name)
+	leaderboardsMux.Unlock()
+}
+
+// Delete a Leaderboard, along with it's config file and data folder.
+func (l *Leaderboard) Delete() int {
+	l.Close(false)
+
+	// Delete data directory
+	if err := storage.DeleteDir(helpers.DataPath(dataFolderPrefix + l.name)); err != nil {
+		helpers.LogAndPrint("Failed delete Leaderboard '"+l.name+"' with error: "+err.Error(), 5)
+		return helpers.ErrorFileDelete
+	}
+
+	// Delete config file
+	if err := os.Remove(helpers.DataPath(dataFolderPrefix+l.name) + helpers.FileTypeConfig); err != nil {
+		helpers.LogAndPrint("Failed delete Leaderboard '"+l.name+"' with error: "+err.Error(), 5)
+		return helpers.ErrorFileDelete
+	}
+
+	return 0
+}
+
+// SyncPolicy returns the storage sync policy for this leaderboard's data files
+func (l *Leaderboard) SyncPolicy() uint8 {
+	l.mux.Lock()
+	policy := l.syncPolicy
+	l.mux.Unlock()
+	return policy
+}
+
+// SetSyncPolicy sets how writes to the leaderboard's data files are flushed to the disk
+func (l *Leaderboard) SetSyncPolicy(policy uint8) int {
+	if policy > helpers.SyncPolicyAlways {
+		policy = helpers.DefaultSyncPolicy
+	}
+	l.mux.Lock()
+	conf := l.makeConfig(l.fileOn)
+	conf.SyncPolicy = policy
+	if err := writeConfigFile(l.configFile, conf); err != 0 {
+		l.mux.Unlock()
+		helpers.LogAndPrint("Failed to set sync policy for Leaderboard '"+l.name+"' with error code: "+strconv.Itoa(err), 4)
+		return err
+	}
+	l.syncPolicy = policy
+	storage.SetSyncPolicy(helpers.DataPath(dataFolderPrefix+l.name), policy)
+	l.mux.Unlock()
+	return 0
+}
+
 // Len returns the length of the leaderboard
 func (l *Leaderboard) Len() int {
 	l.mux.Lock()
@@ -80,22 +293,24 @@ func (l *Leaderboard) GetPage(limit int, page int) []LeaderboardEntry {
 	} else {
 		p = l.entries[page*limit : (page+1)*limit]
 	}
-	l.mux.Unlock()
 	// Convert to non-pointer list
 	cp := make([]LeaderboardEntry, len(p), len(p))
 	for i := 0; i < len(p); i++ {
 		cp[i] = *p[i]
 	}
+	l.mux.Unlock()
 	return cp
 }
 
 // CheckAndPush checks the target against the leaderboard, and pushes worthy entries into it. Returns true if entry was pushed to leaderboard.
 func (l *Leaderboard) CheckAndPush(name string, target float64, extra map[string]interface{}) bool {
+	pushTime := time.Now().UnixNano()
 	l.mux.Lock()
 	// Check if Leaderboard is empty
 	entriesLen := len(l.entries)
 	if entriesLen == 0 {
-		newEntry := LeaderboardEntry{name: name, target: target, extra: extra}
+		newEntry := LeaderboardEntry{name: name, target: target, extra: extra, time: pushTime}
+		l.saveEntry(&newEntry, nil)
 		l.entries = []*LeaderboardEntry{&newEntry}
 		l.least = target
 		l.most = target
@@ -106,11 +321,11 @@ func (l *Leaderboard) CheckAndPush(name string, target float64, extra map[string
 	previousPos := -1
 	var previousTarget float64 = -1
 	newPos := -1
-	if target > l.most {
+	if l.better(target, l.most) {
 		newPos = 0
-	} else if entriesLen < l.maxEntries && target < l.least {
+	} else if !l.full(entriesLen) && l.better(l.least, target) {
 		newPos = entriesLen
-	} else if entriesLen == l.maxEntries && target < l.least {
+	} else if l.full(entriesLen) && l.better(l.least, target) {
 		newPos = -2
 	}
 	for i := 0; i < entriesLen; i++ {
@@ -120,23 +335,23 @@ func (l *Leaderboard) CheckAndPush(name string, target float64, extra map[string
 			previousTarget = currTarget
 		}
 		if newPos == -1 {
-			if target > currTarget {
+			if l.better(target, currTarget) {
 				newPos = i
 			} else if target == currTarget {
-				if l.dupePushAbove {
+				if l.dupePushAbove && !l.timeTieBreak {
 					newPos = i
 				} else {
-					if i == l.maxEntries-1 {
+					if l.full(i + 1) {
 						newPos = -2
 					} else if i == entriesLen-1 {
 						newPos = i + 1
 					} else {
 						for j := i + 1; j < entriesLen; j++ {
-							if target > l.entries[j].target {
+							if l.better(target, l.entries[j].target) {
 								newPos = j
 								break
 							} else if j == entriesLen-1 {
-								if entriesLen < l.maxEntries {
+								if !l.full(entriesLen) {
 									newPos = j + 1
 								} else {
 									newPos = -2
@@ -154,10 +369,11 @@ func (l *Leaderboard) CheckAndPush(name string, target float64, extra map[string
 	var onList bool
 	// Apply any changes
 	if newPos >= 0 {
-		newEntry := LeaderboardEntry{name: name, target: target, extra: extra}
+		newEntry := LeaderboardEntry{name: name, target: target, extra: extra, time: pushTime}
 		if previousPos >= 0 {
 			if previousPos > newPos || (previousPos < newPos && l.alwaysReplace) {
 				// move previousPos to newPos
+				l.saveEntry(&newEntry, l.entries[previousPos])
 				if previousPos < newPos {
 					newPos--
 				}
@@ -166,8 +382,9 @@ func (l *Leaderboard) CheckAndPush(name string, target float64, extra map[string
 				l.least = l.entries[len(l.entries)-1].target
 				l.most = l.entries[0].target
 				onList = true
-			} else if previousPos == newPos && (previousTarget < target || l.alwaysReplace) {
+			} else if previousPos == newPos && (l.better(target, previousTarget) || l.alwaysReplace) {
 				// replace previousPos
+				l.saveEntry(&newEntry, l.entries[previousPos])
 				l.entries[previousPos] = &newEntry
 				l.least = l.entries[len(l.entries)-1].target
 				l.most = l.entries[0].target
@@ -175,9 +392,13 @@ func (l *Leaderboard) CheckAndPush(name string, target float64, extra map[string
 			}
 		} else {
 			// insert to newPos
+			l.saveEntry(&newEntry, nil)
 			l.entries = append(l.entries[:newPos], append([]*LeaderboardEntry{&newEntry}, l.entries[newPos:]...)...)
 			//remove last item if too large
-			if len(l.entries) > l.maxEntries {
+			if l.maxEntries > 0 && len(l.entries) > l.maxEntries {
+				for _, e := range l.entries[l.maxEntries:] {
+					l.deleteEntry(e)
+				}
 				l.entries = l.entries[:l.maxEntries]
 			}
 			l.least = l.entries[len(l.entries)-1].target
@@ -186,7 +407,8 @@ func (l *Leaderboard) CheckAndPush(name string, target float64, extra map[string
 		}
 	} else if previousPos >= 0 && l.alwaysReplace {
 		// move previousPos to end
-		newEntry := LeaderboardEntry{name: name, target: target, extra: extra}
+		newEntry := LeaderboardEntry{name: name, target: target, extra: extra, time: pushTime}
+		l.saveEntry(&newEntry, l.entries[previousPos])
 		l.entries = append(l.entries[:previousPos], l.entries[previousPos+1:]...)
 		l.entries = append(l.entries, &newEntry)
 		l.least = target
@@ -196,6 +418,99 @@ func (l *Leaderboard) CheckAndPush(name string, target float64, extra map[string
 	return onList
 }
 
+// RankOf gets the rank of an entry by name. The top entry has a rank of 1.
+func (l *Leaderboard) RankOf(name string) (int, int) {
+	l.mux.Lock()
+	i := l.indexOf(name)
+	l.mux.Unlock()
+	if i == -1 {
+		return 0, helpers.ErrorNoEntryFound
+	}
+	return i + 1, 0
+}
+
+// Remove removes an entry from the leaderboard by name.
+func (l *Leaderboard) Remove(name string) int {
+	l.mux.Lock()
+	i := l.indexOf(name)
+	if i == -1 {
+		l.mux.Unlock()
+		return helpers.ErrorNoEntryFound
+	}
+	l.deleteEntry(l.entries[i])
+	l.entries = append(l.entries[:i], l.entries[i+1:]...)
+	if len(l.entries) > 0 {
+		l.least = l.entries[len(l.entries)-1].target
+		l.most = l.entries[0].target
+	}
+	l.mux.Unlock()
+	return 0
+}
+
+// GetAround gets the entry with the given name, and up to radius entries above and below it. Also returns the rank of the
+// first entry in the list.
+func (l *Leaderboard) GetAround(name string, radius int) ([]LeaderboardEntry, int, int) {
+	if radius < 0 {
+		radius = 0
+	}
+	l.mux.Lock()
+	i := l.indexOf(name)
+	if i == -1 {
+		l.mux.Unlock()
+		return nil, 0, helpers.ErrorNoEntryFound
+	}
+	start := i - radius
+	if start < 0 {
+		start = 0
+	}
+	end := i + radius + 1
+	if end > len(l.entries) {
+		end = len(l.entries)
+	}
+	// Convert to non-pointer list
+	cp := make([]LeaderboardEntry, end-start, end-start)
+	for j := start; j < end; j++ {
+		cp[j-start] = *l.entries[j]
+	}
+	l.mux.Unlock()
+	return cp, start + 1, 0
+}
+
+// Reset removes all entries from the leaderboard.
+func (l *Leaderboard) Reset() {
+	l.mux.Lock()
+	for _, e := range l.entries {
+		l.deleteEntry(e)
+	}
+	l.entries = make([]*LeaderboardEntry, 0)
+	l.least = 0
+	l.most = 0
+	l.mux.Unlock()
+}
+
+// Gets the index of an entry by name, or -1 if it's not on the leaderboard - must lock l.mux before-hand.
+func (l *Leaderboard) indexOf(name string) int {
+	for i, e := range l.entries {
+		if e.name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Checks if target a is better than target b
+func (l *Leaderboard) better(a float64, b float64) bool {
+	if l.ascending {
+		return a < b
+	}
+	return a > b
+}
+
+// Checks if a leaderboard with the given number of entries is full
+func (l *Leaderboard) full(entriesLen int) bool {
+	return l.maxEntries > 0 && entriesLen >= l.maxEntries
+}
+
 // Print prints the leaderboard to console.
 func (l *Leaderboard) Print() {
 	fmt.Println("=================================================")
@@ -207,3 +522,228 @@ func (l *Leaderboard) Print() {
 	l.mux.Unlock()
 	fmt.Println("=================================================")
 }
+
+//////////////////////////////////////////////////////////////////////////////////////////////////////
+//   Leaderboard Storage   ///////////////////////////////////////////////////////////////////////////
+//////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Saves e to storage in place of the entry it replaces, or on a new line when replaces is nil - must lock l.mux before-hand.
+// The in-memory Leaderboard is kept even when saving fails, so errors are logged instead of returned.
+func (l *Leaderboard) saveEntry(e *LeaderboardEntry, replaces *LeaderboardEntry) {
+	l.pushes++
+	e.push = l.pushes
+	jBytes, jErr := helpers.Fjson.Marshal(jsonEntry{N: e.name, T: e.target, E: e.extra, S: e.time, P: e.push})
+	if jErr != nil {
+		helpers.LogAndPrint("Failed to encode entry '"+e.name+"' for Leaderboard '"+l.name+"'", 4)
+		return
+	}
+	if replaces != nil {
+		e.persistFile = replaces.persistFile
+		e.persistIndex = replaces.persistIndex
+		if err := storage.Update(l.dataFile(e.persistFile), e.persistIndex, jBytes); err != 0 {
+			helpers.LogAndPrint("Failed to save entry '"+e.name+"' for Leaderboard '"+l.name+"' with error code: "+strconv.Itoa(err), 4)
+		}
+		return
+	}
+	lineOn, err := storage.Insert(l.dataFile(l.fileOn), jBytes)
+	if err != 0 {
+		helpers.LogAndPrint("Failed to save entry '"+e.name+"' for Leaderboard '"+l.name+"' with error code: "+strconv.Itoa(err), 4)
+		return
+	}
+	e.persistFile = l.fileOn
+	e.persistIndex = lineOn
+	// Increase fileOn when the index has reached or surpassed partitionMax
+	if lineOn >= l.partitionMax {
+		l.fileOn++
+		writeConfigFile(l.configFile, l.makeConfig(l.fileOn))
+	}
+}
+
+// Removes e from storage - must lock l.mux before-hand.
+func (l *Leaderboard) deleteEntry(e *LeaderboardEntry) {
+	if e.persistIndex == 0 {
+		// Never saved
+		return
+	}
+	if err := storage.Update(l.dataFile(e.persistFile), e.persistIndex, []byte{}); err != 0 {
+		helpers.LogAndPrint("Failed to delete entry '"+e.name+"' for Leaderboard '"+l.name+"' with error code: "+strconv.Itoa(err), 4)
+	}
+}
+
+// Gets the storage file path for a file number
+func (l *Leaderboard) dataFile(fileNum uint32) string {
+	return helpers.DataPath(dataFolderPrefix+l.name) + "/" + strconv.Itoa(int(fileNum)) + helpers.FileTypeStorage
+}
+
+// Makes the leaderboard's config - must lock l.mux before-hand.
+func (l *Leaderboard) makeConfig(fileOn uint32) leaderboardConfig {
+	return leaderboardConfig{
+		FormatVersion: helpers.FormatVersion,
+		Name:          l.name,
+		FileOn:        fileOn,
+		PartitionMax:  l.partitionMax,
+		MaxEntries:    l.maxEntries,
+		DupePushAbove: l.dupePushAbove,
+		AlwaysReplace: l.alwaysReplace,
+		Ascending:     l.ascending,
+		TimeTieBreak:  l.timeTieBreak,
+		SyncPolicy:    l.syncPolicy,
+	}
+}
+
+// Writes c to f and truncates file
+func writeConfigFile(f *os.File, c leaderboardConfig) int {
+	jBytes, jErr := helpers.Fjson.MarshalIndent(c, "", "   ")
+	if jErr != nil {
+		return helpers.ErrorJsonEncoding
+	}
+
+	// Write to config file
+	if _, wErr := f.WriteAt(jBytes, 0); wErr != nil {
+		return helpers.ErrorFileUpdate
+	}
+	f.Truncate(int64(len(jBytes)))
+	return 0
+}
+
+//////////////////////////////////////////////////////////////////////////////////////////////////////
+//   Leaderboard Restoring   /////////////////////////////////////////////////////////////////////////
+//////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Restore restores a Leaderboard by name; requires a valid config file and data folder.
+func Restore(name string) (*Leaderboard, helpers.Error) {
+	fmt.Printf("Restoring Leaderboard '%v'...\n", name)
+	namePre := helpers.DataPath(dataFolderPrefix + name)
+	// Open the File
+	f, err := os.OpenFile(namePre+helpers.FileTypeConfig, os.O_RDWR, 0755)
+	if err != nil {
+		return nil, helpers.NewError(helpers.ErrorFileOpen, "Config file missing for Leaderboard '"+name+"'")
+	}
+	// Get file stats
+	fs, fsErr := f.Stat()
+	if fsErr != nil {
+		f.Close()
+		return nil, helpers.NewError(helpers.ErrorFileOpen, "Error reading config file for Leaderboard '"+name+"'")
+	}
+	// Get file bytes
+	bytes := make([]byte, fs.Size())
+	_, rErr := f.ReadAt(bytes, 0)
+	if rErr != nil && rErr != io.EOF {
+		f.Close()
+		return nil, helpers.NewError(helpers.ErrorFileRead, "Config data is corrupt for Leaderboard '"+name+"'")
+	}
+	// Make confStruct from json bytes
+	confStruct := leaderboardConfig{SyncPolicy: helpers.DefaultSyncPolicy}
+	if mErr := json.Unmarshal(bytes, &confStruct); mErr != nil {
+		f.Close()
+		return nil, helpers.NewError(helpers.ErrorJsonDecoding,
+			"Config contains JSON syntax errors for Leaderboard '"+name+"': "+mErr.Error())
+	}
+	if confStruct.FormatVersion > helpers.FormatVersion {
+		f.Close()
+		return nil, helpers.NewError(helpers.ErrorFormatVersion, "Leaderboard '"+name+"' was made by a newer version (format version "+strconv.Itoa(int(confStruct.FormatVersion))+")")
+	}
+	// Make Leaderboard
+	l, lErr := newLeaderboard(name, f, confStruct)
+	if lErr != 0 {
+		f.Close()
+		return nil, helpers.NewError(lErr, name)
+	}
+	l.mux.Lock()
+	// Open data folder
+	df, err := os.Open(namePre)
+	if err != nil {
+		l.mux.Unlock()
+		l.Close(false)
+		return nil, helpers.NewError(helpers.ErrorFileOpen, "Missing data folder for Leaderboard '"+name+"'")
+	}
+	// Get file names
+	files, err := df.Readdir(-1)
+	df.Close()
+	if err != nil {
+		l.mux.Unlock()
+		l.Close(false)
+		return nil, helpers.NewError(helpers.ErrorFileRead, "Error reading files in data folder for Leaderboard '"+name+"'")
+	}
+	fmt.Printf("Loading Leaderboard data for '%v'...\n", name)
+	// Make progress bar
+	pBar := progressbar.New(len(files))
+	// Go through files & restore entries
+	for _, fileStats := range files {
+		// Get file number
+		fileNameSplit := strings.Split(fileStats.Name(), ".")
+		fileNum, fnErr := strconv.Atoi(fileNameSplit[0])
+		if fnErr != nil || len(fileNameSplit) < 2 || "."+fileNameSplit[1] != helpers.FileTypeStorage {
+			// Not a valid storage file
+			pBar.Add(1)
+			continue
+		}
+		var of *storage.OpenFile
+		var err int
+		if of, err = storage.GetOpenFile(namePre + "/" + fileStats.Name()); err != 0 {
+			helpers.LogAndPrint("Error: Leaderboard '"+name+"':: Could not read data file '"+namePre+"/"+fileStats.Name()+"'!\n", 4)
+			pBar.Add(1)
+			continue
+		}
+		for i := 0; i < of.Lines(); i++ {
+			// Get line bytes
+			var lb []byte
+			if lb, err = of.Read(uint32(i + 1)); err != 0 {
+				helpers.LogAndPrint("Error: Leaderboard '"+name+"':: Could not read line "+strconv.Itoa(i+1)+" of '"+fileStats.Name()+"'!\n", 4)
+				continue
+			} else if len(lb) == 0 {
+				// Removed entry
+				continue
+			}
+			var jEntry jsonEntry
+			if mErr := json.Unmarshal(lb, &jEntry); mErr != nil || jEntry.N == "" {
+				helpers.LogAndPrint("Error: Leaderboard '"+name+"':: Incorrect JSON format on line "+strconv.Itoa(i+1)+" of '"+fileStats.Name()+"'!\n", 4)
+				continue
+			}
+			l.entries = append(l.entries, &LeaderboardEntry{
+				name:         jEntry.N,
+				target:       jEntry.T,
+				extra:        jEntry.E,
+				time:         jEntry.S,
+				persistFile:  uint32(fileNum),
+				persistIndex: uint32(i + 1),
+				push:         jEntry.P,
+			})
+			if jEntry.P > l.pushes {
+				l.pushes = jEntry.P
+			}
+		}
+		pBar.Add(1)
+	}
+	l.sortEntries()
+	l.mux.Unlock()
+	fmt.Printf("Successfully restored Leaderboard '%v'!\n", name)
+	return l, helpers.Error{}
+}
+
+// Sorts restored entries by target. Ties are ordered the same way CheckAndPush would have pushed them: by push time
+// when timeTieBreak is true, newest first when dupePushAbove is true, and oldest first otherwise - must lock l.mux before-hand.
+func (l *Leaderboard) sortEntries() {
+	sort.Slice(l.entries, func(i, j int) bool {
+		if l.entries[i].target != l.entries[j].target {
+			return l.better(l.entries[i].target, l.entries[j].target)
+		}
+		if l.timeTieBreak && l.entries[i].time != l.entries[j].time {
+			return l.entries[i].time < l.entries[j].time
+		} else if l.dupePushAbove && !l.timeTieBreak {
+			return l.entries[i].push > l.entries[j].push
+		}
+		return l.entries[i].push < l.entries[j].push
+	})
+	// Remove entries that no longer fit
+	if l.maxEntries > 0 && len(l.entries) > l.maxEntries {
+		for _, e := range l.entries[l.maxEntries:] {
+			l.deleteEntry(e)
+		}
+		l.entries = l.entries[:l.maxEntries]
+	}
+	if len(l.entries) > 0 {
+		l.least = l.entries[len(l.entries)-1].target
+		l.most = l.entries[0].target
+	}
+}
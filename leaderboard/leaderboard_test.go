@@ -0,0 +1,353 @@
+package leaderboard
+
+import (
+	"encoding/json"
+	"github.com/hewiefreeman/GopherDB/helpers"
+	"github.com/hewiefreeman/GopherDB/leaderboard"
+	"github.com/hewiefreeman/GopherDB/storage"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const (
+	// Test settings
+	leaderboardName string = "test"
+)
+
+var (
+	// Test variables
+	setupComplete bool
+	lb            *leaderboard.Leaderboard
+)
+
+// TO TEST:
+// go test -v leaderboard_test.go
+//
+// Use -v to display fmt output
+
+func TestNew(t *testing.T) {
+	storage.Init("")
+	// Remove a Leaderboard left behind by a failed test
+	if l, err := leaderboard.Restore(leaderboardName); err.ID == 0 {
+		l.Delete()
+	}
+	var err int
+	if lb, err = leaderboard.New(leaderboardName, 3, true, false, false, false); err != 0 {
+		t.Errorf("Error making Leaderboard: %v", err)
+		return
+	}
+	if _, err = leaderboard.New(leaderboardName, 3, true, false, false, false); err != helpers.ErrorLeaderboardExists {
+		t.Errorf("TestNew expected error %v, but got: %v", helpers.ErrorLeaderboardExists, err)
+	}
+	setupComplete = true
+}
+
+func TestCheckAndPush(t *testing.T) {
+	if !setupComplete {
+		t.Skip()
+	}
+	lb.CheckAndPush("a", 10, nil)
+	lb.CheckAndPush("b", 30, map[string]interface{}{"level": "castle"})
+	lb.CheckAndPush("c", 20, nil)
+	lb.CheckAndPush("d", 20, nil)
+	if lb.CheckAndPush("e", 5, nil) {
+		t.Errorf("TestCheckAndPush expected 'e' not to be pushed to a full Leaderboard")
+	}
+	checkPage(t, "TestCheckAndPush", "b", "d", "c")
+	if e := lb.GetPage(1, 0); len(e) != 1 || e[0].Target() != 30 || e[0].Extra()["level"] != "castle" {
+		t.Errorf("TestCheckAndPush got unexpected top entry: %v", e)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	if !setupComplete {
+		t.Skip()
+	}
+	lb.Close(true)
+	if _, err := leaderboard.Get(leaderboardName); err != helpers.ErrorLeaderboardDoesntExist {
+		t.Errorf("TestRestore expected error %v after closing, but got: %v", helpers.ErrorLeaderboardDoesntExist, err)
+	}
+	var err helpers.Error
+	if lb, err = leaderboard.Restore(leaderboardName); err.ID != 0 {
+		t.Errorf("TestRestore error: %v", err)
+		setupComplete = false
+		return
+	}
+	// "a" was pushed off the Leaderboard by "d", and "d" must stay above "c" with dupePushAbove
+	checkPage(t, "TestRestore", "b", "d", "c")
+	// Settings must be restored
+	if lb.CheckAndPush("e", 5, nil) {
+		t.Errorf("TestRestore expected 'e' not to be pushed to a full Leaderboard")
+	}
+	lb.CheckAndPush("f", 25, nil)
+	checkPage(t, "TestRestore (after push)", "b", "f", "d")
+	// Restore again to check pushes made after restoring
+	lb.Close(true)
+	if lb, err = leaderboard.Restore(leaderboardName); err.ID != 0 {
+		t.Errorf("TestRestore error: %v", err)
+		setupComplete = false
+		return
+	}
+	checkPage(t, "TestRestore (second restore)", "b", "f", "d")
+}
+
+func TestRankOf(t *testing.T) {
+	if !setupComplete {
+		t.Skip()
+	}
+	if rank, err := lb.RankOf("f"); err != 0 || rank != 2 {
+		t.Errorf("TestRankOf expected rank 2 for 'f', but got: %v %v", rank, err)
+	}
+	if _, err := lb.RankOf("a"); err != helpers.ErrorNoEntryFound {
+		t.Errorf("TestRankOf expected error %v, but got: %v", helpers.ErrorNoEntryFound, err)
+	}
+}
+
+func TestGetAround(t *testing.T) {
+	if !setupComplete {
+		t.Skip()
+	}
+	entries, rank, err := lb.GetAround("b", 1)
+	if err != 0 || rank != 1 || len(entries) != 2 || entries[0].Name() != "b" || entries[1].Name() != "f" {
+		t.Errorf("TestGetAround got unexpected entries around 'b': %v %v %v", entries, rank, err)
+	}
+	entries, rank, err = lb.GetAround("d", 1)
+	if err != 0 || rank != 2 || len(entries) != 2 || entries[0].Name() != "f" || entries[1].Name() != "d" {
+		t.Errorf("TestGetAround got unexpected entries around 'd': %v %v %v", entries, rank, err)
+	}
+	if _, _, err = lb.GetAround("a", 1); err != helpers.ErrorNoEntryFound {
+		t.Errorf("TestGetAround expected error %v, but got: %v", helpers.ErrorNoEntryFound, err)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	if !setupComplete {
+		t.Skip()
+	}
+	if err := lb.Remove("f"); err != 0 {
+		t.Errorf("TestRemove error: %v", err)
+		return
+	}
+	if err := lb.Remove("f"); err != helpers.ErrorNoEntryFound {
+		t.Errorf("TestRemove expected error %v, but got: %v", helpers.ErrorNoEntryFound, err)
+	}
+	checkPage(t, "TestRemove", "b", "d")
+	// There is room for entries below "d" again
+	if !lb.CheckAndPush("e", 5, nil) {
+		t.Errorf("TestRemove expected 'e' to be pushed")
+	}
+	// Removed entries must stay removed after restoring
+	lb.Close(true)
+	var err helpers.Error
+	if lb, err = leaderboard.Restore(leaderboardName); err.ID != 0 {
+		t.Errorf("TestRemove error: %v", err)
+		setupComplete = false
+		return
+	}
+	checkPage(t, "TestRemove (restore)", "b", "d", "e")
+}
+
+func TestReset(t *testing.T) {
+	if !setupComplete {
+		t.Skip()
+	}
+	lb.Reset()
+	checkPage(t, "TestReset")
+	lb.CheckAndPush("g", 1, nil)
+	lb.Close(true)
+	var err helpers.Error
+	if lb, err = leaderboard.Restore(leaderboardName); err.ID != 0 {
+		t.Errorf("TestReset error: %v", err)
+		setupComplete = false
+		return
+	}
+	checkPage(t, "TestReset (restore)", "g")
+}
+
+func TestNoEntryLimit(t *testing.T) {
+	if !setupComplete {
+		t.Skip()
+	}
+	l, err := leaderboard.New(leaderboardName+"-unlimited", 0, false, false, false, false)
+	if err != 0 {
+		t.Errorf("TestNoEntryLimit error: %v", err)
+		return
+	}
+	defer l.Delete()
+	for i := 0; i < 100; i++ {
+		if !l.CheckAndPush(strconv.Itoa(i), float64(i%10), nil) {
+			t.Errorf("TestNoEntryLimit expected entry %v to be pushed", i)
+			return
+		}
+	}
+	if l.Len() != 100 {
+		t.Errorf("TestNoEntryLimit expected 100 entries, but got: %v", l.Len())
+	}
+	// Without dupePushAbove, ties keep the order they were pushed in
+	if page := l.GetPage(2, 0); len(page) != 2 || page[0].Name() != "9" || page[1].Name() != "19" {
+		t.Errorf("TestNoEntryLimit got unexpected first page: %v", page)
+	}
+}
+
+func TestAscending(t *testing.T) {
+	if !setupComplete {
+		t.Skip()
+	}
+	l, err := leaderboard.New(leaderboardName+"-ascending", 3, false, false, true, false)
+	if err != 0 {
+		t.Errorf("TestAscending error: %v", err)
+		return
+	}
+	l.CheckAndPush("a", 65.5, nil)
+	l.CheckAndPush("b", 61.2, nil)
+	l.CheckAndPush("c", 70, nil)
+	if l.CheckAndPush("d", 80, nil) {
+		t.Errorf("TestAscending expected 'd' not to be pushed to a full Leaderboard")
+	}
+	// A better (lower) target for "c" moves it to the top, and a worse one is ignored
+	l.CheckAndPush("c", 60, nil)
+	if l.CheckAndPush("b", 62, nil) {
+		t.Errorf("TestAscending expected a higher target for 'b' not to be pushed")
+	}
+	checkNames(t, "TestAscending", l, "c", "b", "a")
+	// Ascending order must be restored
+	l.Close(true)
+	var rErr helpers.Error
+	if l, rErr = leaderboard.Restore(leaderboardName + "-ascending"); rErr.ID != 0 {
+		t.Errorf("TestAscending error: %v", rErr)
+		return
+	}
+	defer l.Delete()
+	checkNames(t, "TestAscending (restore)", l, "c", "b", "a")
+	if !l.CheckAndPush("d", 50, nil) {
+		t.Errorf("TestAscending expected 'd' to be pushed after restoring")
+	}
+	checkNames(t, "TestAscending (after push)", l, "d", "c", "b")
+}
+
+func TestTimeTieBreak(t *testing.T) {
+	if !setupComplete {
+		t.Skip()
+	}
+	// dupePushAbove is overridden by timeTieBreak
+	l, err := leaderboard.New(leaderboardName+"-time", 3, true, false, false, true)
+	if err != 0 {
+		t.Errorf("TestTimeTieBreak error: %v", err)
+		return
+	}
+	before := time.Now()
+	l.CheckAndPush("a", 10, nil)
+	l.CheckAndPush("b", 20, nil)
+	l.CheckAndPush("c", 20, nil)
+	l.CheckAndPush("d", 20, nil)
+	// "a" reached 20 last, so there is no room for it
+	if l.CheckAndPush("a", 20, nil) {
+		t.Errorf("TestTimeTieBreak expected 'a' not to be pushed below the ties")
+	}
+	checkNames(t, "TestTimeTieBreak", l, "b", "c", "d")
+	page := l.GetPage(3, 0)
+	if page[0].Time().Before(before) || page[1].Time().Before(page[0].Time()) {
+		t.Errorf("TestTimeTieBreak got unexpected push times: %v %v", page[0].Time(), page[1].Time())
+	}
+	// Tie order and push times must be restored
+	l.Close(true)
+	var rErr helpers.Error
+	if l, rErr = leaderboard.Restore(leaderboardName + "-time"); rErr.ID != 0 {
+		t.Errorf("TestTimeTieBreak error: %v", rErr)
+		return
+	}
+	defer l.Delete()
+	checkNames(t, "TestTimeTieBreak (restore)", l, "b", "c", "d")
+	if restored := l.GetPage(1, 0); !restored[0].Time().Equal(page[0].Time()) {
+		t.Errorf("TestTimeTieBreak expected push time %v to be restored, but got: %v", page[0].Time(), restored[0].Time())
+	}
+}
+
+func TestFormatVersion(t *testing.T) {
+	if !setupComplete {
+		t.Skip()
+	}
+	name := leaderboardName + "-format"
+	l, err := leaderboard.New(name, 3, false, false, false, false)
+	if err != 0 {
+		t.Errorf("TestFormatVersion error: %v", err)
+		return
+	}
+	l.CheckAndPush("a", 10, nil)
+	l.Close(true)
+	// A config from a newer version must not load
+	setConfigFormatVersion(t, name, 99)
+	if _, rErr := leaderboard.Restore(name); rErr.ID != helpers.ErrorFormatVersion {
+		t.Errorf("TestFormatVersion expected error %v, but got: %v", helpers.ErrorFormatVersion, rErr)
+	}
+	// A config without a FormatVersion is from version 1, and must still load
+	setConfigFormatVersion(t, name, -1)
+	var rErr helpers.Error
+	if l, rErr = leaderboard.Restore(name); rErr.ID != 0 {
+		t.Errorf("TestFormatVersion error restoring a version 1 config: %v", rErr)
+		return
+	}
+	defer l.Delete()
+	checkNames(t, "TestFormatVersion", l, "a")
+}
+
+func TestDelete(t *testing.T) {
+	if !setupComplete {
+		t.Skip()
+	}
+	if err := lb.Delete(); err != 0 {
+		t.Errorf("TestDelete error: %v", err)
+	}
+	if _, err := leaderboard.Restore(leaderboardName); err.ID != helpers.ErrorFileOpen {
+		t.Errorf("TestDelete expected error %v when restoring, but got: %v", helpers.ErrorFileOpen, err)
+	}
+	storage.ShutDown()
+}
+
+// Checks the names of the entries on the test Leaderboard's first page
+func checkPage(t *testing.T, test string, names ...string) {
+	checkNames(t, test, lb, names...)
+}
+
+// Checks the names of the entries on a Leaderboard's first page
+func checkNames(t *testing.T, test string, lb *leaderboard.Leaderboard, names ...string) {
+	page := lb.GetPage(10, 0)
+	if len(page) != len(names) {
+		t.Errorf("%v expected %v entries, but got: %v", test, len(names), len(page))
+		lb.Print()
+		return
+	}
+	for i := range names {
+		if page[i].Name() != names[i] {
+			t.Errorf("%v expected %v at rank %v, but got: %v", test, names[i], i+1, page[i].Name())
+			lb.Print()
+			return
+		}
+	}
+}
+
+// Sets the FormatVersion in a closed Leaderboard's config file, or removes it when version is negative
+func setConfigFormatVersion(t *testing.T, name string, version int) {
+	file := "Leaderboard-" + name + helpers.FileTypeConfig
+	b, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("Error reading config file: %v", err)
+	}
+	conf := make(map[string]interface{})
+	if err = json.Unmarshal(b, &conf); err != nil {
+		t.Fatalf("Error decoding config file: %v", err)
+	}
+	if version < 0 {
+		delete(conf, "FormatVersion")
+	} else {
+		conf["FormatVersion"] = version
+	}
+	if b, err = json.Marshal(conf); err != nil {
+		t.Fatalf("Error encoding config file: %v", err)
+	}
+	if err = os.WriteFile(file, b, 0755); err != nil {
+		t.Fatalf("Error writing config file: %v", err)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"github.com/hewiefreeman/GopherDB/helpers"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// Maximum rate limit buckets kept before idle buckets are cleared
+	maxLimitBuckets int = 10000
+)
+
+var (
+	limitsMux    sync.Mutex
+	queryLimiter *rateLimiter // Query rate limiter by credential name or client address - nil for no limit
+	authLimiter  *rateLimiter // bcrypt heavy query rate limiter by client address - nil for no limit
+
+	maxConnections  int32 // Maximum concurrent connections - 0 for no limit
+	openConnections int32 // Number of connections currently being served
+)
+
+// limitsConfig is the structure of the "limits" object in the db.conf file. A value of 0 turns the limit off.
+type limitsConfig struct {
+	MaxConnections   int32   // Maximum concurrent connections
+	QueriesPerSecond float64 // Maximum queries per second for each credential, or client address for master connections
+	AuthPerMinute    float64 // Maximum bcrypt heavy queries (AuthTable queries with a password, authentication) per minute for each client address
+}
+
+// rateLimiter is a token bucket rate limiter for any number of keys.
+type rateLimiter struct {
+	mux     sync.Mutex
+	rate    float64 // Tokens refilled per second
+	burst   float64 // Maximum tokens in a bucket
+	buckets map[string]*limitBucket
+}
+
+type limitBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Applies the settings of a limitsConfig
+func applyLimits(lc limitsConfig) {
+	limitsMux.Lock()
+	queryLimiter = newRateLimiter(lc.QueriesPerSecond, lc.QueriesPerSecond)
+	authLimiter = newRateLimiter(lc.AuthPerMinute/60, lc.AuthPerMinute)
+	limitsMux.Unlock()
+	if lc.MaxConnections < 0 {
+		lc.MaxConnections = 0
+	}
+	atomic.StoreInt32(&maxConnections, lc.MaxConnections)
+}
+
+func newRateLimiter(rate float64, burst float64) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{rate: rate, burst: burst, buckets: make(map[string]*limitBucket)}
+}
+
+// allow takes a token from key's bucket. Returns false if the bucket is empty.
+func (l *rateLimiter) allow(key string) bool {
+	if l == nil {
+		return true
+	}
+	now := time.Now()
+	l.mux.Lock()
+	b := l.buckets[key]
+	if b == nil {
+		if len(l.buckets) >= maxLimitBuckets {
+			l.clearIdle(now)
+		}
+		b = &limitBucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	} else {
+		// Refill tokens since last use
+		b.tokens += now.Sub(b.last).Seconds() * l.rate
+		if b.tokens > l.burst {
+			b.tokens = l.burst
+		}
+		b.last = now
+	}
+	if b.tokens < 1 {
+		l.mux.Unlock()
+		return false
+	}
+	b.tokens--
+	l.mux.Unlock()
+	return true
+}
+
+// Deletes buckets that would be full by now - must lock l.mux before-hand.
+func (l *rateLimiter) clearIdle(now time.Time) {
+	for key, b := range l.buckets {
+		if b.tokens+(now.Sub(b.last).Seconds()*l.rate) >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// allowQuery checks the query rate limit for a connection.
+func allowQuery(conn *connection) bool {
+	limitsMux.Lock()
+	l := queryLimiter
+	limitsMux.Unlock()
+	if conn.name != "" {
+		return l.allow(conn.name)
+	}
+	return l.allow(conn.client)
+}
+
+// allowAuth checks the bcrypt heavy query rate limit for a client address.
+func allowAuth(client string) bool {
+	limitsMux.Lock()
+	l := authLimiter
+	limitsMux.Unlock()
+	return l.allow(client)
+}
+
+// openConnection reserves one of the server's connections. closeConnection must be called when the connection closes.
+func openConnection() int {
+	max := atomic.LoadInt32(&maxConnections)
+	if n := atomic.AddInt32(&openConnections, 1); max > 0 && n > max {
+		atomic.AddInt32(&openConnections, -1)
+		return helpers.ErrorTooManyConnections
+	}
+	return 0
+}
+
+// closeConnection frees a connection reserved by openConnection.
+func closeConnection() {
+	atomic.AddInt32(&openConnections, -1)
+}
+
+// Gets the client address (without port) of a remote network address
+func clientAddress(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return host
+}
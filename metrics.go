@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"github.com/hewiefreeman/GopherDB/storage"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+const (
+	metricsPath string = "/metrics" // Prometheus metrics path on the HTTP server
+)
+
+// metricsHandler responds with the storage engine's metrics in the Prometheus text format. Only served when
+// "metrics" is on in the config file. Requires the master password or a credential with the Admin privilege.
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "", http.StatusMethodNotAllowed)
+		return
+	}
+	name, pass, _ := r.BasicAuth()
+	conn, aErr := authenticate(clientAddress(r.RemoteAddr), name, pass)
+	if aErr != 0 {
+		w.Header().Set("WWW-Authenticate", "Basic")
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	} else if !conn.master && (conn.privs == nil || !conn.privs.Admin) {
+		http.Error(w, "", http.StatusForbidden)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write(prometheusMetrics(storage.Stats()))
+}
+
+// prometheusMetrics formats storage Metrics in the Prometheus text format.
+func prometheusMetrics(s storage.Metrics) []byte {
+	var b bytes.Buffer
+	writeMetric(&b, "gopherdb_storage_open_files", "gauge", "Data files that are open.", uint64(s.OpenFiles))
+	writeMetric(&b, "gopherdb_storage_open_file_hits_total", "counter", "Data file opens that found the file already open.", s.OpenFileHits)
+	writeMetric(&b, "gopherdb_storage_open_file_misses_total", "counter", "Data file opens that had to open the file.", s.OpenFileMisses)
+	writeMetric(&b, "gopherdb_storage_evictions_total", "counter", "Open data files closed to make room for another.", s.Evictions)
+	writeMetric(&b, "gopherdb_storage_read_bytes_total", "counter", "Bytes of lines read from data files.", s.BytesRead)
+	writeMetric(&b, "gopherdb_storage_written_bytes_total", "counter", "Bytes written to data files by inserts and updates.", s.BytesWritten)
+
+	// Latency histograms, sorted by operation
+	const histogram = "gopherdb_storage_operation_duration_seconds"
+	b.WriteString("# HELP " + histogram + " Time taken by storage operations.\n# TYPE " + histogram + " histogram\n")
+	ops := make([]string, 0, len(s.Latency))
+	for op := range s.Latency {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+	for _, op := range ops {
+		l := s.Latency[op]
+		for _, bucket := range l.Buckets {
+			b.WriteString(histogram + "_bucket{operation=\"" + op + "\",le=\"" + strconv.FormatFloat(bucket.Max.Seconds(), 'g', -1, 64) + "\"} " + strconv.FormatUint(bucket.Count, 10) + "\n")
+		}
+		b.WriteString(histogram + "_bucket{operation=\"" + op + "\",le=\"+Inf\"} " + strconv.FormatUint(l.Count, 10) + "\n")
+		b.WriteString(histogram + "_sum{operation=\"" + op + "\"} " + strconv.FormatFloat(l.Total.Seconds(), 'g', -1, 64) + "\n")
+		b.WriteString(histogram + "_count{operation=\"" + op + "\"} " + strconv.FormatUint(l.Count, 10) + "\n")
+	}
+	return b.Bytes()
+}
+
+// Writes a single value metric with it's HELP and TYPE lines
+func writeMetric(b *bytes.Buffer, name string, mType string, help string, v uint64) {
+	b.WriteString("# HELP " + name + " " + help + "\n# TYPE " + name + " " + mType + "\n" + name + " " + strconv.FormatUint(v, 10) + "\n")
+}
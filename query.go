@@ -0,0 +1,600 @@
+package main
+
+import (
+	"github.com/hewiefreeman/GopherDB/authtable"
+	"github.com/hewiefreeman/GopherDB/helpers"
+	"github.com/hewiefreeman/GopherDB/keystore"
+	"github.com/hewiefreeman/GopherDB/schema"
+	"time"
+)
+
+// Query types
+const (
+	queryTypeGet            = "Get"
+	queryTypeSelect         = "Select"
+	queryTypeScan           = "Scan"
+	queryTypeRange          = "Range"
+	queryTypeInsert         = "Insert"
+	queryTypeUpdate         = "Update"
+	queryTypeUpsert         = "Upsert"
+	queryTypeDelete         = "Delete"
+	queryTypeRefresh        = "Refresh"
+	queryTypeTransaction    = "Transaction"
+	queryTypeChangePassword = "ChangePassword"
+	queryTypeResetPassword  = "ResetPassword"
+	queryTypeNewTable       = "NewTable"
+	queryTypeDeleteTable    = "DeleteTable"
+
+	// Admin queries
+	queryTypeSetEncryptionCost      = "SetEncryptionCost"
+	queryTypeSetMaxEntries          = "SetMaxEntries"
+	queryTypeSetPartitionMax        = "SetPartitionMax"
+	queryTypeSetSyncPolicy          = "SetSyncPolicy"
+	queryTypeSetCompactRatio        = "SetCompactRatio"
+	queryTypeCompact                = "Compact"
+	queryTypeCheckIntegrity         = "CheckIntegrity"
+	queryTypeSetMinPasswordLength   = "SetMinPasswordLength"
+	queryTypeSetPasswordResetLength = "SetPasswordResetLength"
+	queryTypeSetAltLoginItem        = "SetAltLoginItem"
+	queryTypeSetEmailItem           = "SetEmailItem"
+)
+
+// Table types
+const (
+	tableTypeKeystore  = "Keystore"
+	tableTypeAuthTable = "AuthTable"
+)
+
+// queryResponse is the JSON object sent back to a client for every query.
+type queryResponse struct {
+	R interface{}   // Query result
+	E helpers.Error // Error ID and From message - ID is 0 when the query succeeded
+}
+
+// Example JSON for Keystore queries:
+//
+//     ["Get", "tableName", "key", { *items to get (optional)* }]
+//     ["Insert", "tableName", "key", { *items that match schema* }, ttl /* optional */]
+//     ["Update", "tableName", "key", { *items to update* }]
+//     ["Upsert", "tableName", "key", { *items that match schema* }, ttl /* optional */]
+//     ["Delete", "tableName", "key"]
+//     ["Refresh", "tableName", "key", ttl]
+//     ["Select", "tableName", { *where (optional)* }, { *items to get (optional)* }, sortBy /* optional */, desc /* optional */, limit /* optional */, page /* optional */]
+//     ["Scan", "tableName", "prefix*", cursor /* optional */, limit /* optional */]
+//     ["Range", "tableName", start, end, cursor /* optional */, limit /* optional */]
+//     ["Transaction", [ *Insert, Update and Delete queries* ]]
+//
+// Example JSON for AuthTable queries:
+//
+//     ["Get", "tableName", "userName", "password", { *items to get (optional)* }]
+//     ["Insert", "tableName", "userName", "password", { *items that match schema* }]
+//     ["Update", "tableName", "userName", "password", { *items to update* }]
+//     ["Delete", "tableName", "userName", "password"]
+//     ["ChangePassword", "tableName", "userName", "password", "newPassword"]
+//     ["ResetPassword", "tableName", "userName"]
+//
+// Example JSON for table queries:
+//
+//     ["NewTable", "tableName", "Keystore" /* or "AuthTable" */, { *schema* }, dataOnDrive /* optional */, memOnly /* optional */, engine /* optional */, encoding /* optional */]
+//     ["DeleteTable", "tableName"]
+//
+// Example JSON for admin queries:
+//
+//     ["SetEncryptionCost", "tableName", 10]
+//     ["SetMaxEntries", "tableName", 50000]
+//     ["SetPartitionMax", "tableName", 500]
+//     ["SetSyncPolicy", "tableName", 2]           // 0 = never, 1 = interval, 2 = always
+//     ["SetCompactRatio", "tableName", 0.5]       // 0 = no automatic compaction
+//     ["Compact", "tableName"]
+//     ["SetMinPasswordLength", "tableName", 8]    // AuthTable only
+//     ["SetPasswordResetLength", "tableName", 16] // AuthTable only
+//     ["SetAltLoginItem", "tableName", "email"]   // AuthTable only
+//     ["SetEmailItem", "tableName", "email"]      // AuthTable only
+//
+
+// runQuery checks the format of a query and the connection's privileges, then sends the query to the table it targets.
+func runQuery(conn *connection, query []interface{}) (interface{}, helpers.Error) {
+	if len(query) < 2 {
+		return nil, helpers.NewError(helpers.ErrorQueryInvalidFormat, "")
+	}
+	qType, ok := query[0].(string)
+	if !ok {
+		return nil, helpers.NewError(helpers.ErrorQueryInvalidFormat, "")
+	} else if qType == queryTypeTransaction {
+		return nil, runTransaction(conn, query[1])
+	}
+	tableName, ok := query[1].(string)
+	if !ok || len(tableName) == 0 {
+		return nil, helpers.NewError(helpers.ErrorTableNameRequired, "")
+	}
+	// Check privileges and rate limit
+	if !conn.allowed(qType, tableName) {
+		return nil, helpers.NewError(helpers.ErrorNoPrivileges, qType)
+	} else if !allowQuery(conn) {
+		return nil, helpers.NewError(helpers.ErrorRateLimited, "")
+	}
+	// Admin queries
+	if qType == queryTypeCheckIntegrity {
+		return checkTableIntegrity(tableName)
+	} else if isAdminQuery(qType) {
+		return nil, runAdminQuery(tableName, qType, query[2:])
+	}
+	// Table queries
+	switch qType {
+	case queryTypeNewTable:
+		return nil, newTable(tableName, query[2:])
+	case queryTypeDeleteTable:
+		return nil, deleteTable(tableName)
+	case queryTypeSelect:
+		return selectKeys(tableName, query[2:])
+	case queryTypeScan:
+		return scanKeys(tableName, query[2:])
+	case queryTypeRange:
+		return rangeKeys(tableName, query[2:])
+	}
+	if len(query) < 3 {
+		return nil, helpers.NewError(helpers.ErrorQueryInvalidFormat, tableName)
+	}
+	// Find the table
+	if ks := keystore.Get(tableName); ks != nil {
+		return runKeystoreQuery(ks, qType, query[2:])
+	} else if at := authtable.Get(tableName); at != nil {
+		// Every AuthTable query runs bcrypt at least once
+		if !allowAuth(conn.client) {
+			return nil, helpers.NewError(helpers.ErrorRateLimited, tableName)
+		}
+		return runAuthTableQuery(at, qType, query[2:])
+	}
+	return nil, helpers.NewError(helpers.ErrorTableDoesntExist, tableName)
+}
+
+func runKeystoreQuery(ks *keystore.Keystore, qType string, params []interface{}) (interface{}, helpers.Error) {
+	key, ok := params[0].(string)
+	if !ok {
+		return nil, helpers.NewError(helpers.ErrorKeyRequired, "")
+	}
+	if qType == queryTypeRefresh {
+		ttl, ok := queryTTL(params, 1)
+		if !ok || len(params) < 2 {
+			return nil, helpers.NewError(helpers.ErrorQueryInvalidFormat, key)
+		}
+		return nil, ks.RefreshKey(key, ttl)
+	}
+	obj, ok := queryObject(params, 1)
+	if !ok {
+		return nil, helpers.NewError(helpers.ErrorQueryInvalidFormat, key)
+	}
+	switch qType {
+	case queryTypeGet:
+		return ks.GetKey(key, obj)
+
+	case queryTypeInsert:
+		ttl, ok := queryTTL(params, 2)
+		if !ok {
+			return nil, helpers.NewError(helpers.ErrorQueryInvalidFormat, key)
+		}
+		_, err := ks.InsertKeyTTL(key, obj, ttl)
+		return nil, err
+
+	case queryTypeUpdate:
+		return nil, ks.UpdateKey(key, obj)
+
+	case queryTypeUpsert:
+		ttl, ok := queryTTL(params, 2)
+		if !ok {
+			return nil, helpers.NewError(helpers.ErrorQueryInvalidFormat, key)
+		}
+		_, err := ks.UpsertKeyTTL(key, obj, ttl)
+		return nil, err
+
+	case queryTypeDelete:
+		return nil, ks.DeleteKey(key)
+	}
+	return nil, helpers.NewError(helpers.ErrorQueryInvalidFormat, qType)
+}
+
+// runTransaction checks the format and privileges of every query in a transaction, then runs them all-or-nothing.
+// Transactions can only hold Insert, Update and Delete queries on Keystores.
+func runTransaction(conn *connection, param interface{}) helpers.Error {
+	queries, ok := param.([]interface{})
+	if !ok || len(queries) == 0 {
+		return helpers.NewError(helpers.ErrorQueryInvalidFormat, queryTypeTransaction)
+	}
+	ops := make([]keystore.Operation, len(queries))
+	for i, q := range queries {
+		query, ok := q.([]interface{})
+		if !ok || len(query) < 3 {
+			return helpers.NewError(helpers.ErrorQueryInvalidFormat, queryTypeTransaction)
+		}
+		qType, ok := query[0].(string)
+		if !ok || (qType != queryTypeInsert && qType != queryTypeUpdate && qType != queryTypeDelete) {
+			return helpers.NewError(helpers.ErrorQueryInvalidFormat, queryTypeTransaction)
+		}
+		tableName, ok := query[1].(string)
+		if !ok || len(tableName) == 0 {
+			return helpers.NewError(helpers.ErrorTableNameRequired, "")
+		} else if !conn.allowed(qType, tableName) {
+			return helpers.NewError(helpers.ErrorNoPrivileges, qType)
+		}
+		ks := keystore.Get(tableName)
+		if ks == nil {
+			return helpers.NewError(helpers.ErrorTableDoesntExist, tableName)
+		}
+		key, ok := query[2].(string)
+		if !ok {
+			return helpers.NewError(helpers.ErrorKeyRequired, "")
+		}
+		ops[i] = keystore.Operation{Type: qType, Table: ks, Key: key}
+		if qType == queryTypeDelete {
+			continue
+		}
+		if ops[i].Obj, ok = queryObject(query[2:], 1); !ok {
+			return helpers.NewError(helpers.ErrorQueryInvalidFormat, key)
+		}
+		if qType == queryTypeInsert {
+			if ops[i].TTL, ok = queryTTL(query[2:], 2); !ok {
+				return helpers.NewError(helpers.ErrorQueryInvalidFormat, key)
+			}
+		}
+	}
+	if !allowQuery(conn) {
+		return helpers.NewError(helpers.ErrorRateLimited, "")
+	}
+	return keystore.Transaction(ops)
+}
+
+func runAuthTableQuery(at *authtable.AuthTable, qType string, params []interface{}) (interface{}, helpers.Error) {
+	userName, ok := params[0].(string)
+	if !ok {
+		return nil, helpers.NewError(helpers.ErrorNameRequired, "")
+	}
+	// ResetPassword is the only query that doesn't require a password
+	if qType == queryTypeResetPassword {
+		return nil, at.ResetUserPassword(userName)
+	}
+	if len(params) < 2 {
+		return nil, helpers.NewError(helpers.ErrorQueryInvalidFormat, userName)
+	}
+	password, ok := params[1].(string)
+	if !ok {
+		return nil, helpers.NewError(helpers.ErrorPasswordLength, userName)
+	}
+	if qType == queryTypeChangePassword {
+		if len(params) < 3 {
+			return nil, helpers.NewError(helpers.ErrorQueryInvalidFormat, userName)
+		}
+		newPassword, ok := params[2].(string)
+		if !ok {
+			return nil, helpers.NewError(helpers.ErrorPasswordLength, userName)
+		}
+		return nil, at.ChangeUserPassword(userName, password, newPassword)
+	}
+	obj, ok := queryObject(params, 2)
+	if !ok {
+		return nil, helpers.NewError(helpers.ErrorQueryInvalidFormat, userName)
+	}
+	switch qType {
+	case queryTypeGet:
+		return at.GetUser(userName, password, obj)
+
+	case queryTypeInsert:
+		_, err := at.NewUser(userName, password, obj)
+		return nil, err
+
+	case queryTypeUpdate:
+		return nil, at.UpdateUser(userName, password, obj)
+
+	case queryTypeDelete:
+		return nil, at.DeleteUser(userName, password)
+	}
+	return nil, helpers.NewError(helpers.ErrorQueryInvalidFormat, qType)
+}
+
+// selectKeys runs a multi-key get query on a Keystore.
+func selectKeys(tableName string, params []interface{}) (interface{}, helpers.Error) {
+	ks := keystore.Get(tableName)
+	if ks == nil {
+		return nil, helpers.NewError(helpers.ErrorTableDoesntExist, tableName)
+	}
+	where, ok := queryObject(params, 0)
+	if !ok {
+		return nil, helpers.NewError(helpers.ErrorQueryInvalidFormat, tableName)
+	}
+	items, ok := queryObject(params, 1)
+	if !ok {
+		return nil, helpers.NewError(helpers.ErrorQueryInvalidFormat, tableName)
+	}
+	// Optional sorting and paging
+	var sortBy string
+	var desc bool
+	var limit, page float64
+	if len(params) > 2 {
+		if sortBy, ok = params[2].(string); !ok {
+			return nil, helpers.NewError(helpers.ErrorQueryInvalidFormat, tableName)
+		}
+	}
+	if len(params) > 3 {
+		if desc, ok = params[3].(bool); !ok {
+			return nil, helpers.NewError(helpers.ErrorQueryInvalidFormat, tableName)
+		}
+	}
+	if len(params) > 4 {
+		if limit, ok = params[4].(float64); !ok {
+			return nil, helpers.NewError(helpers.ErrorQueryInvalidFormat, tableName)
+		}
+	}
+	if len(params) > 5 {
+		if page, ok = params[5].(float64); !ok {
+			return nil, helpers.NewError(helpers.ErrorQueryInvalidFormat, tableName)
+		}
+	}
+	return ks.GetKeys(where, items, sortBy, desc, int(limit), int(page))
+}
+
+// scanKeys runs a prefix key scan on a Keystore. The prefix must end with "*", which keys can't contain.
+func scanKeys(tableName string, params []interface{}) (interface{}, helpers.Error) {
+	ks := keystore.Get(tableName)
+	if ks == nil {
+		return nil, helpers.NewError(helpers.ErrorTableDoesntExist, tableName)
+	}
+	if len(params) < 1 {
+		return nil, helpers.NewError(helpers.ErrorQueryInvalidFormat, tableName)
+	}
+	prefix, ok := params[0].(string)
+	if !ok || len(prefix) == 0 || prefix[len(prefix)-1] != '*' {
+		return nil, helpers.NewError(helpers.ErrorQueryInvalidFormat, tableName)
+	}
+	cursor, limit, ok := keyPaging(params, 1)
+	if !ok {
+		return nil, helpers.NewError(helpers.ErrorQueryInvalidFormat, tableName)
+	}
+	return ks.PrefixKeys(prefix[:len(prefix)-1], cursor, limit)
+}
+
+// rangeKeys runs a lexicographic range key scan on a Keystore.
+func rangeKeys(tableName string, params []interface{}) (interface{}, helpers.Error) {
+	ks := keystore.Get(tableName)
+	if ks == nil {
+		return nil, helpers.NewError(helpers.ErrorTableDoesntExist, tableName)
+	}
+	if len(params) < 2 {
+		return nil, helpers.NewError(helpers.ErrorQueryInvalidFormat, tableName)
+	}
+	start, ok := params[0].(string)
+	if !ok {
+		return nil, helpers.NewError(helpers.ErrorQueryInvalidFormat, tableName)
+	}
+	end, ok := params[1].(string)
+	if !ok {
+		return nil, helpers.NewError(helpers.ErrorQueryInvalidFormat, tableName)
+	}
+	cursor, limit, ok := keyPaging(params, 2)
+	if !ok {
+		return nil, helpers.NewError(helpers.ErrorQueryInvalidFormat, tableName)
+	}
+	return ks.ScanKeys(start, end, cursor, limit)
+}
+
+// keyPaging gets the optional cursor and limit of a key scan query, starting at index i of it's parameters.
+func keyPaging(params []interface{}, i int) (string, int, bool) {
+	var cursor string
+	var limit float64
+	var ok bool
+	if len(params) > i {
+		if cursor, ok = params[i].(string); !ok {
+			return "", 0, false
+		}
+	}
+	if len(params) > i+1 {
+		if limit, ok = params[i+1].(float64); !ok {
+			return "", 0, false
+		}
+	}
+	return cursor, int(limit), true
+}
+
+// newTable creates a new table and adds it to the server's config file.
+func newTable(name string, params []interface{}) helpers.Error {
+	if len(params) < 2 {
+		return helpers.NewError(helpers.ErrorQueryInvalidFormat, name)
+	}
+	tableType, ok := params[0].(string)
+	if !ok {
+		return helpers.NewError(helpers.ErrorQueryInvalidFormat, name)
+	}
+	// Optional settings
+	var dataOnDrive, memOnly bool
+	var engine, encoding string
+	if len(params) > 2 {
+		if dataOnDrive, ok = params[2].(bool); !ok {
+			return helpers.NewError(helpers.ErrorQueryInvalidFormat, name)
+		}
+	}
+	if len(params) > 3 {
+		if memOnly, ok = params[3].(bool); !ok {
+			return helpers.NewError(helpers.ErrorQueryInvalidFormat, name)
+		}
+	}
+	if len(params) > 4 {
+		if engine, ok = params[4].(string); !ok {
+			return helpers.NewError(helpers.ErrorQueryInvalidFormat, name)
+		}
+	}
+	if len(params) > 5 {
+		if encoding, ok = params[5].(string); !ok {
+			return helpers.NewError(helpers.ErrorQueryInvalidFormat, name)
+		}
+	}
+	// Table names are unique across all table types
+	if keystore.Get(name) != nil || authtable.Get(name) != nil {
+		return helpers.NewError(helpers.ErrorTableExists, name)
+	}
+	s, sErr := schema.New(params[1], false)
+	if sErr.ID != 0 {
+		return sErr
+	}
+	switch tableType {
+	case tableTypeKeystore:
+		if _, err := keystore.New(name, nil, s, 0, dataOnDrive, memOnly, engine, encoding); err.ID != 0 {
+			return err
+		}
+	case tableTypeAuthTable:
+		if _, err := authtable.New(name, nil, s, 0, dataOnDrive, memOnly, engine, encoding); err.ID != 0 {
+			return err
+		}
+	default:
+		return helpers.NewError(helpers.ErrorQueryInvalidFormat, tableType)
+	}
+	if err := configAddTable(tableType, name); err != 0 {
+		return helpers.NewError(err, configFile)
+	}
+	return helpers.Error{}
+}
+
+// deleteTable deletes a table from memory and disk, and removes it from the server's config file.
+func deleteTable(name string) helpers.Error {
+	var tableType string
+	if ks := keystore.Get(name); ks != nil {
+		if err := ks.Delete(); err != 0 {
+			return helpers.NewError(err, name)
+		}
+		tableType = tableTypeKeystore
+	} else if at := authtable.Get(name); at != nil {
+		if err := at.Delete(); err.ID != 0 {
+			return err
+		}
+		tableType = tableTypeAuthTable
+	} else {
+		return helpers.NewError(helpers.ErrorTableDoesntExist, name)
+	}
+	if err := configRemoveTable(tableType, name); err != 0 {
+		return helpers.NewError(err, configFile)
+	}
+	return helpers.Error{}
+}
+
+// runAdminQuery changes a setting on a table, or compacts it's data files.
+func runAdminQuery(tableName string, qType string, params []interface{}) helpers.Error {
+	if qType == queryTypeCompact {
+		return compactTable(tableName)
+	} else if len(params) == 0 {
+		return helpers.NewError(helpers.ErrorQueryInvalidFormat, tableName)
+	}
+	var err int
+	if ks := keystore.Get(tableName); ks != nil {
+		err = setKeystoreSetting(ks, qType, params[0])
+	} else if at := authtable.Get(tableName); at != nil {
+		err = setAuthTableSetting(at, qType, params[0])
+	} else {
+		err = helpers.ErrorTableDoesntExist
+	}
+	if err != 0 {
+		return helpers.NewError(err, tableName)
+	}
+	return helpers.Error{}
+}
+
+// compactTable removes deleted entries from a table's data files.
+func compactTable(tableName string) helpers.Error {
+	var err int
+	if ks := keystore.Get(tableName); ks != nil {
+		err = ks.Compact()
+	} else if at := authtable.Get(tableName); at != nil {
+		err = at.Compact()
+	} else {
+		err = helpers.ErrorTableDoesntExist
+	}
+	if err != 0 {
+		return helpers.NewError(err, tableName)
+	}
+	return helpers.Error{}
+}
+
+// checkTableIntegrity reads a table's data files, and sends back the errors of the lines that are corrupt.
+func checkTableIntegrity(tableName string) (interface{}, helpers.Error) {
+	if ks := keystore.Get(tableName); ks != nil {
+		return ks.CheckIntegrity(), helpers.Error{}
+	} else if at := authtable.Get(tableName); at != nil {
+		return at.CheckIntegrity(), helpers.Error{}
+	}
+	return nil, helpers.NewError(helpers.ErrorTableDoesntExist, tableName)
+}
+
+func setKeystoreSetting(ks *keystore.Keystore, qType string, param interface{}) int {
+	num, ok := param.(float64)
+	if !ok || num < 0 {
+		return helpers.ErrorQueryInvalidFormat
+	}
+	switch qType {
+	case queryTypeSetEncryptionCost:
+		return ks.SetEncryptionCost(int(num))
+	case queryTypeSetMaxEntries:
+		return ks.SetMaxEntries(uint64(num))
+	case queryTypeSetPartitionMax:
+		return ks.SetPartitionMax(uint32(num))
+	case queryTypeSetSyncPolicy:
+		return ks.SetSyncPolicy(uint8(num))
+	case queryTypeSetCompactRatio:
+		return ks.SetCompactRatio(num)
+	}
+	return helpers.ErrorQueryInvalidFormat
+}
+
+func setAuthTableSetting(at *authtable.AuthTable, qType string, param interface{}) int {
+	// Item settings
+	switch qType {
+	case queryTypeSetAltLoginItem, queryTypeSetEmailItem:
+		item, ok := param.(string)
+		if !ok {
+			return helpers.ErrorQueryInvalidFormat
+		}
+		if qType == queryTypeSetAltLoginItem {
+			return at.SetAltLoginItem(item)
+		}
+		return at.SetEmailItem(item)
+	}
+	// Number settings
+	num, ok := param.(float64)
+	if !ok || num < 0 {
+		return helpers.ErrorQueryInvalidFormat
+	}
+	switch qType {
+	case queryTypeSetEncryptionCost:
+		return at.SetEncryptionCost(int(num))
+	case queryTypeSetMaxEntries:
+		return at.SetMaxEntries(uint64(num))
+	case queryTypeSetPartitionMax:
+		return at.SetPartitionMax(uint32(num))
+	case queryTypeSetSyncPolicy:
+		return at.SetSyncPolicy(uint8(num))
+	case queryTypeSetCompactRatio:
+		return at.SetCompactRatio(num)
+	case queryTypeSetMinPasswordLength:
+		return at.SetMinPasswordLength(uint8(num))
+	case queryTypeSetPasswordResetLength:
+		return at.SetPasswordResetLength(uint8(num))
+	}
+	return helpers.ErrorQueryInvalidFormat
+}
+
+// queryObject gets the optional item object at index i of a query's parameters. Returns false if the
+// parameter exists, but is not an object.
+func queryObject(params []interface{}, i int) (map[string]interface{}, bool) {
+	if len(params) <= i || params[i] == nil {
+		return nil, true
+	}
+	obj, ok := params[i].(map[string]interface{})
+	return obj, ok
+}
+
+// queryTTL gets an optional TTL in seconds from index i of a query's parameters. A missing TTL is 0.
+func queryTTL(params []interface{}, i int) (time.Duration, bool) {
+	if len(params) <= i {
+		return 0, true
+	}
+	seconds, ok := params[i].(float64)
+	if !ok || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds * float64(time.Second)), true
+}
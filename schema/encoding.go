@@ -0,0 +1,322 @@
+/*
+schema package Copyright 2020 Dominique Debergue
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at:
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+either express or implied. See the License for the specific
+language governing permissions and limitations under the License.
+*/
+
+package schema
+
+import (
+	"encoding/binary"
+	"github.com/hewiefreeman/GopherDB/helpers"
+	"math"
+	"time"
+)
+
+// Binary records
+//
+// A table can store it's entries as binary records instead of JSON. A record has no names or type markers - it's
+// read with the Schema that wrote it, so every value comes back as the type the table keeps in memory:
+//
+//     Bool:             1 byte
+//     Int8 - Int64:     1, 2, 4 or 8 bytes, big-endian (Uint8 - Uint64 the same)
+//     Float32, Float64: 4 or 8 bytes of IEEE 754 bits, big-endian
+//     String:           uvarint length, then the string's bytes
+//     Array:            uvarint number of items, then the items
+//     Map:              uvarint number of items, then a String key and the item for each
+//     Object:           the items of it's schema, in data index order
+//     Time:             uvarint length, then the time.Time binary format (keeps nanoseconds and the zone offset)
+//
+// An entry's data is the items of the table's Schema, in data index order.
+
+// AppendBinary appends an entry's data to b as a binary record.
+func (s Schema) AppendBinary(b []byte, data []interface{}) ([]byte, int) {
+	items := s.itemsByIndex()
+	if items == nil || len(data) != len(items) {
+		return nil, helpers.ErrorBinaryEncoding
+	}
+	var err int
+	for i := range items {
+		if b, err = items[i].appendBinary(b, data[i]); err != 0 {
+			return nil, err
+		}
+	}
+	return b, 0
+}
+
+// ReadBinary reads an entry's data from the start of a binary record, and returns the rest of the record.
+func (s Schema) ReadBinary(b []byte) ([]interface{}, []byte, int) {
+	items := s.itemsByIndex()
+	if items == nil {
+		return nil, nil, helpers.ErrorBinaryDecoding
+	}
+	data := make([]interface{}, len(items))
+	var err int
+	for i := range items {
+		if data[i], b, err = items[i].readBinary(b); err != 0 {
+			return nil, nil, err
+		}
+	}
+	return data, b, 0
+}
+
+// AppendBinaryString appends a String to b in the binary record format.
+func AppendBinaryString(b []byte, str string) []byte {
+	b = appendUvarint(b, uint64(len(str)))
+	return append(b, str...)
+}
+
+// ReadBinaryString reads a String from the start of b, and returns the rest of b.
+func ReadBinaryString(b []byte) (string, []byte, int) {
+	l, b, err := readUvarint(b)
+	if err != 0 || uint64(len(b)) < l {
+		return "", nil, helpers.ErrorBinaryDecoding
+	}
+	return string(b[:l]), b[l:], 0
+}
+
+// Gets the SchemaItems in data index order, or nil if the indexes have gaps
+func (s Schema) itemsByIndex() []SchemaItem {
+	items := make([]SchemaItem, len(s))
+	for _, si := range s {
+		if int(si.dataIndex) >= len(items) || items[si.dataIndex].name != "" {
+			return nil
+		}
+		items[si.dataIndex] = si
+	}
+	return items
+}
+
+func (si SchemaItem) appendBinary(b []byte, i interface{}) ([]byte, int) {
+	switch si.typeName {
+	case ItemTypeBool:
+		v, ok := i.(bool)
+		if !ok {
+			return nil, helpers.ErrorBinaryEncoding
+		} else if v {
+			return append(b, 1), 0
+		}
+		return append(b, 0), 0
+
+	case ItemTypeString:
+		v, ok := i.(string)
+		if !ok {
+			return nil, helpers.ErrorBinaryEncoding
+		}
+		return AppendBinaryString(b, v), 0
+
+	case ItemTypeArray:
+		v, ok := i.([]interface{})
+		if !ok {
+			return nil, helpers.ErrorBinaryEncoding
+		}
+		it := si.iType.(ArrayItem)
+		b = appendUvarint(b, uint64(len(v)))
+		var err int
+		for _, item := range v {
+			if b, err = it.dataType.appendBinary(b, item); err != 0 {
+				return nil, err
+			}
+		}
+		return b, 0
+
+	case ItemTypeMap:
+		v, ok := i.(map[string]interface{})
+		if !ok {
+			return nil, helpers.ErrorBinaryEncoding
+		}
+		it := si.iType.(MapItem)
+		b = appendUvarint(b, uint64(len(v)))
+		var err int
+		for key, item := range v {
+			b = AppendBinaryString(b, key)
+			if b, err = it.dataType.appendBinary(b, item); err != 0 {
+				return nil, err
+			}
+		}
+		return b, 0
+
+	case ItemTypeObject:
+		v, ok := i.([]interface{})
+		if !ok {
+			return nil, helpers.ErrorBinaryEncoding
+		}
+		return si.iType.(ObjectItem).schema.AppendBinary(b, v)
+
+	case ItemTypeTime:
+		t, ok := makeTime(i, &si)
+		if !ok {
+			return nil, helpers.ErrorBinaryEncoding
+		}
+		tb, tErr := t.MarshalBinary()
+		if tErr != nil {
+			return nil, helpers.ErrorBinaryEncoding
+		}
+		b = appendUvarint(b, uint64(len(tb)))
+		return append(b, tb...), 0
+	}
+
+	// Numeric types
+	v, ok := makeTypeLiteral(i, &si)
+	if !ok {
+		return nil, helpers.ErrorBinaryEncoding
+	}
+	switch n := v.(type) {
+	case int8:
+		return append(b, byte(n)), 0
+	case int16:
+		return appendUint(b, uint64(uint16(n)), 2), 0
+	case int32:
+		return appendUint(b, uint64(uint32(n)), 4), 0
+	case int64:
+		return appendUint(b, uint64(n), 8), 0
+	case uint8:
+		return append(b, n), 0
+	case uint16:
+		return appendUint(b, uint64(n), 2), 0
+	case uint32:
+		return appendUint(b, uint64(n), 4), 0
+	case uint64:
+		return appendUint(b, n, 8), 0
+	case float32:
+		return appendUint(b, uint64(math.Float32bits(n)), 4), 0
+	case float64:
+		return appendUint(b, math.Float64bits(n), 8), 0
+	}
+	return nil, helpers.ErrorBinaryEncoding
+}
+
+func (si SchemaItem) readBinary(b []byte) (interface{}, []byte, int) {
+	switch si.typeName {
+	case ItemTypeBool:
+		if len(b) < 1 || b[0] > 1 {
+			return nil, nil, helpers.ErrorBinaryDecoding
+		}
+		return b[0] == 1, b[1:], 0
+
+	case ItemTypeString:
+		return ReadBinaryString(b)
+
+	case ItemTypeArray:
+		l, b, err := readUvarint(b)
+		if err != 0 || uint64(len(b)) < l {
+			// Every item is at least a byte
+			return nil, nil, helpers.ErrorBinaryDecoding
+		}
+		it := si.iType.(ArrayItem)
+		v := make([]interface{}, l)
+		for i := range v {
+			if v[i], b, err = it.dataType.readBinary(b); err != 0 {
+				return nil, nil, err
+			}
+		}
+		return v, b, 0
+
+	case ItemTypeMap:
+		l, b, err := readUvarint(b)
+		if err != 0 || uint64(len(b)) < l {
+			return nil, nil, helpers.ErrorBinaryDecoding
+		}
+		it := si.iType.(MapItem)
+		v := make(map[string]interface{}, l)
+		var key string
+		for i := uint64(0); i < l; i++ {
+			if key, b, err = ReadBinaryString(b); err != 0 {
+				return nil, nil, err
+			}
+			if v[key], b, err = it.dataType.readBinary(b); err != 0 {
+				return nil, nil, err
+			}
+		}
+		return v, b, 0
+
+	case ItemTypeObject:
+		return si.iType.(ObjectItem).schema.ReadBinary(b)
+
+	case ItemTypeTime:
+		l, b, err := readUvarint(b)
+		if err != 0 || uint64(len(b)) < l {
+			return nil, nil, helpers.ErrorBinaryDecoding
+		}
+		var t time.Time
+		if tErr := t.UnmarshalBinary(b[:l]); tErr != nil {
+			return nil, nil, helpers.ErrorBinaryDecoding
+		}
+		return t, b[l:], 0
+	}
+
+	// Numeric types
+	var size int
+	switch si.typeName {
+	case ItemTypeInt8, ItemTypeUint8:
+		size = 1
+	case ItemTypeInt16, ItemTypeUint16:
+		size = 2
+	case ItemTypeInt32, ItemTypeUint32, ItemTypeFloat32:
+		size = 4
+	case ItemTypeInt64, ItemTypeUint64, ItemTypeFloat64:
+		size = 8
+	default:
+		return nil, nil, helpers.ErrorBinaryDecoding
+	}
+	if len(b) < size {
+		return nil, nil, helpers.ErrorBinaryDecoding
+	}
+	var n uint64
+	for _, c := range b[:size] {
+		n = n<<8 | uint64(c)
+	}
+	b = b[size:]
+	switch si.typeName {
+	case ItemTypeInt8:
+		return int8(n), b, 0
+	case ItemTypeInt16:
+		return int16(n), b, 0
+	case ItemTypeInt32:
+		return int32(n), b, 0
+	case ItemTypeInt64:
+		return int64(n), b, 0
+	case ItemTypeUint8:
+		return uint8(n), b, 0
+	case ItemTypeUint16:
+		return uint16(n), b, 0
+	case ItemTypeUint32:
+		return uint32(n), b, 0
+	case ItemTypeFloat32:
+		return math.Float32frombits(uint32(n)), b, 0
+	case ItemTypeFloat64:
+		return math.Float64frombits(n), b, 0
+	}
+	return n, b, 0
+}
+
+// Appends the last size bytes of n to b, big-endian
+func appendUint(b []byte, n uint64, size int) []byte {
+	for i := size - 1; i >= 0; i-- {
+		b = append(b, byte(n>>(uint(i)*8)))
+	}
+	return b
+}
+
+func appendUvarint(b []byte, n uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], n)]...)
+}
+
+func readUvarint(b []byte) (uint64, []byte, int) {
+	n, l := binary.Uvarint(b)
+	if l <= 0 {
+		return 0, nil, helpers.ErrorBinaryDecoding
+	}
+	return n, b[l:], 0
+}
@@ -0,0 +1,132 @@
+/*
+schema package Copyright 2020 Dominique Debergue
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at:
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+either express or implied. See the License for the specific
+language governing permissions and limitations under the License.
+*/
+
+package schema
+
+// Indexes
+//
+// Bool, number and String items at the top of a table's schema can be indexed by adding the index type after
+// the rest of their parameters:
+//
+//		"email": ["String", "", 0, false, true, true, "hash"]      // finds entries by equal values
+//		"level": ["Uint8", 0, 0, 0, false, false, "ordered"]      // also finds entries by ranges of values, in order
+//
+// Items inside of Arrays, Maps and Objects, and encrypted Strings, can't be indexed.
+
+// Index types
+const (
+	IndexHash    = "hash"
+	IndexOrdered = "ordered"
+)
+
+// Index gets the index type of the SchemaItem - empty when the SchemaItem isn't indexed.
+func (si SchemaItem) Index() string {
+	return si.index
+}
+
+// IndexValue converts a value of the SchemaItem, from an entry's data or a query, to the value it's indexed by.
+// Numbers are converted the same way as the number methods convert them, so an index finds the same entries as the
+// *eq, *gt, *gte, *lt and *lte methods.
+func (si SchemaItem) IndexValue(v interface{}) (interface{}, bool) {
+	switch si.typeName {
+	case ItemTypeBool:
+		b, ok := v.(bool)
+		return b, ok
+	case ItemTypeInt8, ItemTypeInt16, ItemTypeInt32, ItemTypeInt64:
+		return makeInt64(v)
+	case ItemTypeUint8, ItemTypeUint16, ItemTypeUint32, ItemTypeUint64:
+		return makeUint64(v)
+	case ItemTypeFloat32, ItemTypeFloat64:
+		return makeFloat64(v)
+	case ItemTypeString:
+		s, ok := v.(string)
+		return s, ok
+	}
+	return nil, false
+}
+
+// CompareIndexValues compares two values made by the IndexValue of the same SchemaItem. Returns -1 when a is less
+// than b, 0 when they are equal, and 1 when a is greater than b.
+func CompareIndexValues(a interface{}, b interface{}) int {
+	var less, greater bool
+	switch av := a.(type) {
+	case int64:
+		bv, _ := b.(int64)
+		less, greater = av < bv, av > bv
+	case uint64:
+		bv, _ := b.(uint64)
+		less, greater = av < bv, av > bv
+	case float64:
+		bv, _ := b.(float64)
+		less, greater = av < bv, av > bv
+	case string:
+		bv, _ := b.(string)
+		less, greater = av < bv, av > bv
+	case bool:
+		bv, _ := b.(bool)
+		less, greater = !av && bv, av && !bv
+	}
+	if less {
+		return -1
+	} else if greater {
+		return 1
+	}
+	return 0
+}
+
+// Gets the optional index type after the parameters of an indexable item type. Returns the parameters without the
+// index type, or false when the index type is invalid.
+func indexParam(t string, params []interface{}) ([]interface{}, string, bool) {
+	var n int
+	switch t {
+	case ItemTypeBool:
+		n = 2
+	case ItemTypeUint8, ItemTypeUint16, ItemTypeUint32, ItemTypeUint64, ItemTypeString:
+		n = 6
+	case ItemTypeInt8, ItemTypeInt16, ItemTypeInt32, ItemTypeInt64, ItemTypeFloat32, ItemTypeFloat64:
+		n = 7
+	default:
+		return params, "", true
+	}
+	if len(params) != n+1 {
+		return params, "", true
+	}
+	index, ok := params[n].(string)
+	if !ok || (index != IndexHash && index != IndexOrdered) {
+		return nil, "", false
+	}
+	return params[:n], index, true
+}
+
+// Checks if a SchemaItem, or any item inside of it, is indexed
+func hasIndex(si SchemaItem) bool {
+	if si.index != "" {
+		return true
+	}
+	switch si.typeName {
+	case ItemTypeArray:
+		return hasIndex(si.iType.(ArrayItem).dataType)
+	case ItemTypeMap:
+		return hasIndex(si.iType.(MapItem).dataType)
+	case ItemTypeObject:
+		for _, inner := range si.iType.(ObjectItem).schema {
+			if hasIndex(inner) {
+				return true
+			}
+		}
+	}
+	return false
+}
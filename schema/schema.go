@@ -33,6 +33,7 @@ type SchemaItem struct {
 	typeName  string
 	iType     interface{}
 	rawParams []interface{}
+	index     string // index type - empty when the item isn't indexed
 }
 
 // SchemaConfigItem structures data for saving Schemas/Objects to disk in a config file.
@@ -96,6 +97,9 @@ type SchemaConfigItem struct {
 //			> format: the format of time/date the database will accept as input (eg: "Unix", "RFC3339", "Stamp" - see constants in types.go)
 //			> required: when true, the value must be specified when inserting (does not check on updates)
 //
+//	Any Bool, number or String item at the top of a schema can end with an index type ("hash" or "ordered") to
+//	index the item - see index.go
+//
 //	Example JSON for a new schema:
 //
 //		{
@@ -199,11 +203,13 @@ func makeSchemaItem(name string, params []interface{}, restore bool) (SchemaItem
 
 	// Get data type
 	if t, ok := params[0].(string); ok {
-		if !checkTypeFormat(t)(params[1:]) {
+		// Get the optional index type after the type's parameters
+		typeParams, index, iOk := indexParam(t, params)
+		if !iOk || !checkTypeFormat(t)(typeParams[1:]) {
 			return SchemaItem{}, helpers.NewError(helpers.ErrorSchemaInvalidItemParameters, name)
 		}
 		// Execute create for the type
-		si := SchemaItem{name: name, typeName: t, rawParams: params}
+		si := SchemaItem{name: name, typeName: t, rawParams: params, index: index}
 		switch t {
 		case ItemTypeBool:
 			si.iType = BoolItem{defaultValue: params[1].(bool)}
@@ -252,6 +258,10 @@ func makeSchemaItem(name string, params []interface{}, restore bool) (SchemaItem
 
 		case ItemTypeString:
 			si.iType = StringItem{defaultValue: params[1].(string), maxChars: uint32(params[2].(float64)), encrypted: params[3].(bool), required: params[4].(bool), unique: params[5].(bool)}
+			// Encrypted Strings can't be found by value
+			if index != "" && params[3].(bool) {
+				return SchemaItem{}, helpers.NewError(helpers.ErrorSchemaInvalidItemParameters, name)
+			}
 			return si, helpers.Error{}
 
 		case ItemTypeArray:
@@ -260,6 +270,10 @@ func makeSchemaItem(name string, params []interface{}, restore bool) (SchemaItem
 				return SchemaItem{}, iErr
 			}
 			si.iType = ArrayItem{dataType: schemaItem, maxItems: uint32(params[2].(float64))}
+			// Only items at the top of a schema can be indexed
+			if hasIndex(schemaItem) {
+				return SchemaItem{}, helpers.NewError(helpers.ErrorSchemaInvalidItemParameters, name)
+			}
 			return si, helpers.Error{}
 
 		case ItemTypeMap:
@@ -268,6 +282,9 @@ func makeSchemaItem(name string, params []interface{}, restore bool) (SchemaItem
 				return SchemaItem{}, iErr
 			}
 			si.iType = MapItem{dataType: schemaItem, maxItems: uint32(params[2].(float64))}
+			if hasIndex(schemaItem) {
+				return SchemaItem{}, helpers.NewError(helpers.ErrorSchemaInvalidItemParameters, name)
+			}
 			return si, helpers.Error{}
 
 		case ItemTypeObject:
@@ -278,6 +295,9 @@ func makeSchemaItem(name string, params []interface{}, restore bool) (SchemaItem
 				return SchemaItem{}, schemaErr
 			}
 			si.iType = ObjectItem{schema: schema}
+			if hasIndex(si) {
+				return SchemaItem{}, helpers.NewError(helpers.ErrorSchemaInvalidItemParameters, name)
+			}
 			return si, helpers.Error{}
 
 		case ItemTypeTime:
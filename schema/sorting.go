@@ -45,6 +45,17 @@ func sort(filter *Filter, ary []interface{}, by interface{}, asc bool) int {
 	return 0
 }
 
+// SortData sorts the data of entries made with the Schema by one of it's items, like *sortAsc and *sortDesc sort an
+// Array of Objects. by is the item's name, and selects items inside of Objects with dots (eg: "friend.level"). The
+// data of an entry can have more values after the Schema's items, which are moved along with it.
+func (s Schema) SortData(data []interface{}, by string, asc bool) int {
+	if by == "" {
+		return helpers.ErrorArrayItemNotSortable
+	}
+	itemType := SchemaItem{typeName: ItemTypeObject, iType: ObjectItem{schema: s}}
+	return sortArrayByObjectItem(data, &itemType, strings.Split(by, "."), asc)
+}
+
 // Sort Int type Arrays
 func sortArrayInt(ary []interface{}, asc bool) {
 	// Convert int type to int64
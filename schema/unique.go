@@ -27,6 +27,28 @@ func GetUniqueItems(schema Schema, destination *[]string, outerItems string) {
 	}
 }
 
+// GetUniqueValues gets the values of all unique table items from an entry's data, and puts them in destination by
+// item name. data must hold the types the table keeps in memory, like data read from a binary record.
+func GetUniqueValues(schema Schema, data []interface{}, destination *map[string]interface{}, outerItems string) {
+	for itemName, schemaItem := range schema {
+		name := itemName
+		if outerItems != "" {
+			name = outerItems + "." + itemName
+		}
+		if int(schemaItem.dataIndex) >= len(data) {
+			continue
+		}
+		if schemaItem.typeName == ItemTypeObject {
+			// Top-level Objects can hold items unique to the table
+			if obj, ok := data[schemaItem.dataIndex].([]interface{}); ok {
+				GetUniqueValues(schemaItem.iType.(ObjectItem).schema, obj, destination, name)
+			}
+		} else if schemaItem.Unique() {
+			(*destination)[name] = data[schemaItem.dataIndex]
+		}
+	}
+}
+
 /////////////////////////////////////////////////////////////////////////////////////////////////////////////////
 //   Query checks   /////////////////////////////////////////////////////////////////////////////////////////////
 /////////////////////////////////////////////////////////////////////////////////////////////////////////////////
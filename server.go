@@ -6,12 +6,6 @@ package main
 //////////////////         - Setting server name/address, subject & body message for password reset emails
 //////////////////         - Send emails for password resets
 //////////////////
-//////////////////     - Database server
-//////////////////         - Connection authentication
-//////////////////         - Connection privillages
-//////////////////
-//////////////////     - Rate & connection limiting
-//////////////////
 //////////////////     - Clustering
 //////////////////         - Connect to cluster nodes & agree upon master node
 //////////////////         - Master assigns nodes key numbers and creates a keyspace unless valid ones have been created already
@@ -23,8 +17,9 @@ package main
 
 import (
 	"github.com/hewiefreeman/GopherDB/helpers"
+	"github.com/hewiefreeman/GopherDB/storage"
 	"fmt"
-	//"html"
+	"io"
 	"net/http"
 	"sync"
 )
@@ -46,7 +41,14 @@ var (
 const (
 	configFile string = "db.conf"
 
-	defaultConfigFile string = "{\"masterPass\":\"\",\"dbs\":[],\"replica\":false,\"readOnly\":false,\"replicas\":[],\"routers\":[],\"AuthTables\":[],\"Leaderboards\":[]}"
+	serverAddress string = "localhost:8082"
+	maxQuerySize  int64  = 1 << 20 // Maximum bytes in a query body
+
+	// Logger settings
+	logPriority int = 3
+	logFileSize int = 1000
+
+	defaultConfigFile string = "{\"masterPass\":\"\",\"replica\":false,\"readOnly\":false,\"dataDirectory\":\"\",\"metrics\":false,\"replicas\":[],\"routers\":[],\"Keystores\":[],\"AuthTables\":[],\"Leaderboards\":[],\"credentials\":[],\"limits\":{\"MaxConnections\":0,\"QueriesPerSecond\":0,\"AuthPerMinute\":0}}"
 )
 
 // Database statuses
@@ -59,14 +61,92 @@ const (
 )
 
 func main() {
-	// initialize and start database server
-	/*http.HandleFunc("/", queryHandler)
+	// Load config file
+	if err := loadConfig(); err != 0 {
+		fmt.Println("Failed to load config file '"+configFile+"' with error code:", err)
+		return
+	}
+
+	// Initialize storage engine & logger in the data directory
+	if err := storage.Init(conf.DataDirectory); err != 0 {
+		fmt.Println("Failed to make data directory '"+conf.DataDirectory+"' with error code:", err)
+		return
+	}
+	if err := helpers.InitLogger(logPriority, logFileSize); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	// Restore tables
+	restoreTables()
+	statusMux.Lock()
+	dbStatus = statusHealthy
+	statusMux.Unlock()
+
+	// Initialize and start database server
+	http.HandleFunc("/", queryHandler)
+	http.HandleFunc(streamPath, streamHandler)
+	if conf.Metrics {
+		http.HandleFunc(metricsPath, metricsHandler)
+	}
+	go func() {
+		if err := listenStreams(streamAddress); err != nil {
+			helpers.LogAndPrint("TCP stream listener stopped with error: "+err.Error(), 5)
+		}
+	}()
 	fmt.Println("starting server...")
-	if err := http.ListenAndServe("localhost:8082", nil); err != nil {
+	if err := http.ListenAndServe(serverAddress, nil); err != nil {
 		fmt.Println(err)
-	}*/
+	}
+	storage.ShutDown()
 }
 
+// queryHandler runs a JSON query from the body of a POST request, and responds with a JSON encoded queryResponse.
 func queryHandler(w http.ResponseWriter, r *http.Request) {
-	//
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "", http.StatusMethodNotAllowed)
+		return
+	}
+	var res queryResponse
+	// Connection limit
+	if cErr := openConnection(); cErr != 0 {
+		res.E = helpers.NewError(cErr, "")
+		writeResponse(w, res)
+		return
+	}
+	defer closeConnection()
+	// Authenticate with HTTP Basic Auth - an empty user name authenticates with the master password
+	name, pass, _ := r.BasicAuth()
+	conn, aErr := authenticate(clientAddress(r.RemoteAddr), name, pass)
+	if aErr != 0 {
+		res.E = helpers.NewError(aErr, name)
+		writeResponse(w, res)
+		return
+	}
+	// Read query
+	body, rErr := io.ReadAll(http.MaxBytesReader(w, r.Body, maxQuerySize))
+	if rErr != nil {
+		res.E = helpers.NewError(helpers.ErrorQueryInvalidFormat, "")
+		writeResponse(w, res)
+		return
+	}
+	var query []interface{}
+	if err := helpers.Fjson.Unmarshal(body, &query); err != nil {
+		res.E = helpers.NewError(helpers.ErrorJsonDecoding, "")
+		writeResponse(w, res)
+		return
+	}
+	res.R, res.E = runQuery(conn, query)
+	writeResponse(w, res)
+}
+
+// writeResponse sends a JSON encoded queryResponse to the client.
+func writeResponse(w http.ResponseWriter, res queryResponse) {
+	jBytes, jErr := helpers.Fjson.Marshal(res)
+	if jErr != nil {
+		jBytes, _ = helpers.Fjson.Marshal(queryResponse{E: helpers.NewError(helpers.ErrorJsonEncoding, "")})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(jBytes)
 }
@@ -0,0 +1,153 @@
+/*
+storage package Copyright 2020 Dominique Debergue
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at:
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+either express or implied. See the License for the specific
+language governing permissions and limitations under the License.
+*/
+
+package storage
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"github.com/hewiefreeman/GopherDB/helpers"
+	"hash/crc32"
+)
+
+// Line checksums
+//
+// Every line written to a data file starts with a checksum of it's data, so a line that was changed on the disk
+// is caught when it's read:
+//
+//     #[crc32c of data: 8 hex characters][data][padding]
+//
+// Data with a new line in it, or that ends with a space, can't be stored as-is. It's stored with it's new lines,
+// escapeIndicators and ending space escaped instead, and starts with an escapedIndicator:
+//
+//     %[crc32c of data: 8 hex characters][escaped data][padding]
+//
+// New lines are stored as "\n", escapeIndicators as "\\", and a space at the end as "\s", so binary records only
+// grow by a few bytes. Lines written in base64 start with an encodedIndicator, and are still read:
+//
+//     $[crc32c of data: 8 hex characters][base64 of data][padding]
+//
+// Lines written before checksums were added have no checksumIndicator, and are read without a check. Empty
+// (deleted) lines have no checksum.
+
+const (
+	checksumIndicator byte = byte(35)
+	encodedIndicator  byte = byte(36)
+	escapedIndicator  byte = byte(37)
+	escapeIndicator   byte = byte(92)
+	checksumSize      int  = 9 // checksumIndicator + 8 hex characters
+)
+
+var (
+	castagnoliTable *crc32.Table = crc32.MakeTable(crc32.Castagnoli)
+)
+
+// Makes the record for a line: jData with it's checksum in front of it
+func makeRecord(jData []byte) []byte {
+	if len(jData) == 0 {
+		return jData
+	}
+	escape := bytes.IndexByte(jData, newLineIndicator) != -1 || jData[len(jData)-1] == paddingIndicator
+	rec := make([]byte, checksumSize, checksumSize+len(jData))
+	rec[0] = checksumIndicator
+	if escape {
+		rec[0] = escapedIndicator
+	}
+	var sum [4]byte
+	c := crc32.Checksum(jData, castagnoliTable)
+	sum[0], sum[1], sum[2], sum[3] = byte(c>>24), byte(c>>16), byte(c>>8), byte(c)
+	hex.Encode(rec[1:], sum[:])
+	if escape {
+		return appendEscaped(rec, jData)
+	}
+	return append(rec, jData...)
+}
+
+// Appends data with it's new lines, escapeIndicators and ending space escaped
+func appendEscaped(rec []byte, jData []byte) []byte {
+	last := len(jData) - 1
+	for i, b := range jData {
+		switch {
+		case b == newLineIndicator:
+			rec = append(rec, escapeIndicator, 'n')
+		case b == escapeIndicator:
+			rec = append(rec, escapeIndicator, escapeIndicator)
+		case b == paddingIndicator && i == last:
+			rec = append(rec, escapeIndicator, 's')
+		default:
+			rec = append(rec, b)
+		}
+	}
+	return rec
+}
+
+// Gets the data back from escaped data
+func unescape(eData []byte) ([]byte, bool) {
+	jData := make([]byte, 0, len(eData))
+	for i := 0; i < len(eData); i++ {
+		if eData[i] != escapeIndicator {
+			jData = append(jData, eData[i])
+			continue
+		} else if i++; i == len(eData) {
+			return nil, false
+		}
+		switch eData[i] {
+		case 'n':
+			jData = append(jData, newLineIndicator)
+		case escapeIndicator:
+			jData = append(jData, escapeIndicator)
+		case 's':
+			jData = append(jData, paddingIndicator)
+		default:
+			return nil, false
+		}
+	}
+	return jData, true
+}
+
+// Gets the data from a line's slot, and checks it against it's checksum
+func readRecord(slot []byte) ([]byte, int) {
+	rec := bytes.TrimRight(slot, string(paddingIndicator))
+	if len(rec) == 0 || (rec[0] != checksumIndicator && rec[0] != encodedIndicator && rec[0] != escapedIndicator) {
+		return rec, 0
+	}
+	if len(rec) < checksumSize {
+		return nil, helpers.ErrorChecksumMismatch
+	}
+	var sum [4]byte
+	if _, err := hex.Decode(sum[:], rec[1:checksumSize]); err != nil {
+		return nil, helpers.ErrorChecksumMismatch
+	}
+	jData := rec[checksumSize:]
+	switch rec[0] {
+	case encodedIndicator:
+		var err error
+		if jData, err = base64.RawStdEncoding.DecodeString(string(jData)); err != nil {
+			return nil, helpers.ErrorChecksumMismatch
+		}
+	case escapedIndicator:
+		var ok bool
+		if jData, ok = unescape(jData); !ok {
+			return nil, helpers.ErrorChecksumMismatch
+		}
+	}
+	c := crc32.Checksum(jData, castagnoliTable)
+	if sum != [4]byte{byte(c >> 24), byte(c >> 16), byte(c >> 8), byte(c)} {
+		return nil, helpers.ErrorChecksumMismatch
+	}
+	return jData, 0
+}
@@ -0,0 +1,134 @@
+/*
+storage package Copyright 2020 Dominique Debergue
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at:
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+either express or implied. See the License for the specific
+language governing permissions and limitations under the License.
+*/
+
+package storage
+
+import (
+	"github.com/hewiefreeman/GopherDB/helpers"
+	"strconv"
+	"sync"
+)
+
+// Compaction
+//
+// Deleting an entry leaves an empty line in it's partition. A table's Compactor counts the empty lines of each
+// partition, and once the ratio of empty lines in a partition reaches the table's compact ratio, the partition is
+// compacted in the background: it's rewritten without the empty lines, and the table points the entries that were
+// in it to their new lines. Compact() does the same for every partition.
+
+// Compactor counts the deleted lines in the partitions of a table's Engine, and compacts the partitions.
+type Compactor struct {
+	engine Engine
+	name   string                                          // table name for logs, like "Keystore 'users'"
+	lock   *sync.RWMutex                                   // the table's line lock - write locked while compacting
+	move   func(partition uint32, moved map[uint32]uint32) // points the table's entries to their new lines
+
+	mux     sync.Mutex
+	dead    map[uint32]uint32 // deleted lines by partition
+	stopped bool              // set by Stop
+}
+
+// NewCompactor makes a Compactor for a table's Engine. lock must be read locked by the table while it uses the
+// line numbers of it's entries. move is called with lock write locked after a partition is compacted, with the new
+// line of every line that moved.
+func NewCompactor(se Engine, name string, lock *sync.RWMutex, move func(partition uint32, moved map[uint32]uint32)) *Compactor {
+	return &Compactor{
+		engine: se,
+		name:   name,
+		lock:   lock,
+		move:   move,
+		dead:   make(map[uint32]uint32),
+	}
+}
+
+// AddDead counts n deleted lines in a partition without compacting it - used while restoring a table, and for
+// lines that were left empty.
+func (c *Compactor) AddDead(partition uint32, n uint32) {
+	c.mux.Lock()
+	c.dead[partition] += n
+	c.mux.Unlock()
+}
+
+// AddDeadLine counts a deleted line in a partition, and compacts the partition in the background when it's ratio of
+// deleted lines reaches ratio. A ratio of 0 never compacts.
+func (c *Compactor) AddDeadLine(partition uint32, ratio float64) {
+	c.mux.Lock()
+	c.dead[partition]++
+	dead := c.dead[partition]
+	stopped := c.stopped
+	c.mux.Unlock()
+	if ratio == 0 || stopped {
+		return
+	}
+	lines, err := c.engine.Lines(partition)
+	if err != 0 || float64(dead) < ratio*float64(lines) {
+		return
+	}
+	go func() {
+		// Skipped if another compaction got to the partition first
+		if err := c.CompactPartition(partition, 1); err != 0 {
+			helpers.LogAndPrint("Failed to compact data file "+strconv.Itoa(int(partition))+" of "+c.name+" with error code: "+strconv.Itoa(err), 4)
+		}
+	}()
+}
+
+// Stop keeps the Compactor from compacting, so every line keeps it's line number - used when something that points
+// to the table's lines by number, like a transaction log, has to be resolved by a Restore first.
+func (c *Compactor) Stop() {
+	c.mux.Lock()
+	c.stopped = true
+	c.mux.Unlock()
+}
+
+// Compact rewrites every partition without it's deleted lines.
+func (c *Compactor) Compact() int {
+	partitions, err := c.engine.Partitions()
+	if err != 0 {
+		return err
+	}
+	for _, partition := range partitions {
+		if err := c.CompactPartition(partition, 0); err != 0 {
+			return err
+		}
+	}
+	return 0
+}
+
+// CompactPartition rewrites a partition without it's deleted lines, and moves the table's entries in it to their
+// new lines. Does nothing when the partition has less than minDead deleted lines, and fails once the Compactor is
+// stopped.
+func (c *Compactor) CompactPartition(partition uint32, minDead uint32) int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.mux.Lock()
+	dead := c.dead[partition]
+	stopped := c.stopped
+	c.mux.Unlock()
+	if stopped {
+		return helpers.ErrorTableInconsistent
+	} else if dead < minDead {
+		return 0
+	}
+	moved, err := c.engine.Compact(partition)
+	if err != 0 {
+		return err
+	}
+	c.move(partition, moved)
+	c.mux.Lock()
+	delete(c.dead, partition)
+	c.mux.Unlock()
+	return 0
+}
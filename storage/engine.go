@@ -0,0 +1,121 @@
+/*
+storage package Copyright 2020 Dominique Debergue
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at:
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+either express or implied. See the License for the specific
+language governing permissions and limitations under the License.
+*/
+
+package storage
+
+import (
+	"github.com/hewiefreeman/GopherDB/helpers"
+	"sync"
+)
+
+// Storage engines
+//
+// An Engine stores the data lines of a table's partitions. Tables address their data by partition and line
+// number only, and leave it up to the Engine where and how the lines are kept:
+//
+//     "file"   - a data file for each partition in the table's folder (the default)
+//     "memory" - lines are only kept in memory, and are gone when the server stops. Made for tests.
+//
+// More engines can be added with RegisterEngine.
+
+// Engine names
+const (
+	EngineFile   = "file"
+	EngineMemory = "memory"
+)
+
+// Engine stores the data lines of a table's partitions. Lines start at 1, and an empty line is a deleted one.
+type Engine interface {
+	// Name gets the name the Engine was opened with
+	Name() string
+	// Create makes the Engine's storage for a new table
+	Create() int
+	// Insert appends a line to a partition, and returns it's line number
+	Insert(partition uint32, jData []byte) (uint32, int)
+	// Read gets a line from a partition
+	Read(partition uint32, line uint32) ([]byte, int)
+	// Update replaces a line in a partition
+	Update(partition uint32, line uint32, jData []byte) int
+	// Lines gets the number of lines in a partition
+	Lines(partition uint32) (int, int)
+	// Partitions gets the partitions that have been written to, in order
+	Partitions() ([]uint32, int)
+	// Compact removes a partition's empty lines, and returns the new line number of every line kept by it's old one
+	Compact(partition uint32) (map[uint32]uint32, int)
+	// SetSyncPolicy sets when writes are flushed to the disk
+	SetSyncPolicy(policy uint8)
+	// Delete removes all of the Engine's data
+	Delete() int
+}
+
+// LineReserver is an Engine that can append an empty line with room for data that's written to it later, so the
+// write doesn't have to move the line. Engines that aren't a LineReserver append an empty line instead.
+type LineReserver interface {
+	// Reserve appends an empty line to a partition with room for jData, and returns it's line number
+	Reserve(partition uint32, jData []byte) (uint32, int)
+}
+
+// PartitionSyncer is an Engine that can flush the writes to a single partition to the disk.
+type PartitionSyncer interface {
+	// Sync flushes a partition's writes to the disk
+	Sync(partition uint32) int
+}
+
+var (
+	enginesMux sync.Mutex
+	engines    map[string]func(folder string) Engine = map[string]func(folder string) Engine{
+		EngineFile:   newFileEngine,
+		EngineMemory: newMemoryEngine,
+	}
+)
+
+// RegisterEngine adds an Engine that tables can be made with by name. open gets the Engine for a table's folder.
+func RegisterEngine(name string, open func(folder string) Engine) {
+	enginesMux.Lock()
+	engines[name] = open
+	enginesMux.Unlock()
+}
+
+// NewEngine gets the Engine with the given name for a table's folder. An empty name gets the file Engine.
+func NewEngine(name string, folder string) (Engine, int) {
+	if name == "" {
+		name = EngineFile
+	}
+	enginesMux.Lock()
+	open := engines[name]
+	enginesMux.Unlock()
+	if open == nil {
+		return nil, helpers.ErrorInvalidEngine
+	}
+	return open(folder), 0
+}
+
+// ReserveLine appends an empty line to a partition of an Engine with room for jData, and returns it's line number.
+func ReserveLine(se Engine, partition uint32, jData []byte) (uint32, int) {
+	if r, ok := se.(LineReserver); ok {
+		return r.Reserve(partition, jData)
+	}
+	return se.Insert(partition, []byte{})
+}
+
+// SyncPartition flushes the writes to a partition of an Engine to the disk. Does nothing for Engines that aren't a
+// PartitionSyncer.
+func SyncPartition(se Engine, partition uint32) int {
+	if s, ok := se.(PartitionSyncer); ok {
+		return s.Sync(partition)
+	}
+	return 0
+}
@@ -0,0 +1,120 @@
+/*
+storage package Copyright 2020 Dominique Debergue
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at:
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+either express or implied. See the License for the specific
+language governing permissions and limitations under the License.
+*/
+
+package storage
+
+import (
+	"github.com/hewiefreeman/GopherDB/helpers"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// fileEngine stores each partition of a table in a data file named by it's partition number, in the table's folder.
+type fileEngine struct {
+	folder string
+}
+
+func newFileEngine(folder string) Engine {
+	return &fileEngine{folder: filepath.Clean(folder)}
+}
+
+// Gets the name of a partition's data file
+func (e *fileEngine) file(partition uint32) string {
+	return e.folder + "/" + strconv.Itoa(int(partition)) + helpers.FileTypeStorage
+}
+
+func (e *fileEngine) Name() string {
+	return EngineFile
+}
+
+func (e *fileEngine) Create() int {
+	if err := MakeDir(e.folder); err != nil {
+		return helpers.ErrorCreatingFolder
+	}
+	return 0
+}
+
+func (e *fileEngine) Insert(partition uint32, jData []byte) (uint32, int) {
+	return Insert(e.file(partition), jData)
+}
+
+func (e *fileEngine) Reserve(partition uint32, jData []byte) (uint32, int) {
+	return Reserve(e.file(partition), jData)
+}
+
+func (e *fileEngine) Sync(partition uint32) int {
+	return SyncFile(e.file(partition))
+}
+
+func (e *fileEngine) Read(partition uint32, line uint32) ([]byte, int) {
+	return Read(e.file(partition), line)
+}
+
+func (e *fileEngine) Update(partition uint32, line uint32, jData []byte) int {
+	return Update(e.file(partition), line, jData)
+}
+
+func (e *fileEngine) Lines(partition uint32) (int, int) {
+	f, err := GetOpenFile(e.file(partition))
+	if err != 0 {
+		return 0, err
+	}
+	return f.Lines(), 0
+}
+
+func (e *fileEngine) Partitions() ([]uint32, int) {
+	df, err := os.Open(e.folder)
+	if err != nil {
+		return nil, helpers.ErrorFileOpen
+	}
+	names, err := df.Readdirnames(-1)
+	df.Close()
+	if err != nil {
+		return nil, helpers.ErrorFileRead
+	}
+	partitions := make([]uint32, 0, len(names))
+	for _, name := range names {
+		if !strings.HasSuffix(name, helpers.FileTypeStorage) {
+			continue
+		}
+		p, pErr := strconv.ParseUint(strings.TrimSuffix(name, helpers.FileTypeStorage), 10, 32)
+		if pErr != nil {
+			// Not a data file
+			continue
+		}
+		partitions = append(partitions, uint32(p))
+	}
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+	return partitions, 0
+}
+
+func (e *fileEngine) Compact(partition uint32) (map[uint32]uint32, int) {
+	return Compact(e.file(partition))
+}
+
+func (e *fileEngine) SetSyncPolicy(policy uint8) {
+	SetSyncPolicy(e.folder, policy)
+}
+
+func (e *fileEngine) Delete() int {
+	if err := DeleteDir(e.folder); err != nil {
+		return helpers.ErrorFileDelete
+	}
+	return 0
+}
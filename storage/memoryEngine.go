@@ -0,0 +1,115 @@
+/*
+storage package Copyright 2020 Dominique Debergue
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at:
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+either express or implied. See the License for the specific
+language governing permissions and limitations under the License.
+*/
+
+package storage
+
+import (
+	"github.com/hewiefreeman/GopherDB/helpers"
+	"sort"
+	"sync"
+)
+
+// memoryEngine keeps the lines of a table's partitions in memory only. Nothing is written to the disk.
+type memoryEngine struct {
+	mux        sync.Mutex
+	partitions map[uint32][][]byte
+}
+
+func newMemoryEngine(folder string) Engine {
+	return &memoryEngine{partitions: make(map[uint32][][]byte)}
+}
+
+func (e *memoryEngine) Name() string {
+	return EngineMemory
+}
+
+func (e *memoryEngine) Create() int {
+	return 0
+}
+
+func (e *memoryEngine) Insert(partition uint32, jData []byte) (uint32, int) {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	lines := e.partitions[partition]
+	if int64(len(lines)) >= maxLines {
+		return 0, helpers.ErrorFileAppend
+	}
+	e.partitions[partition] = append(lines, append([]byte{}, jData...))
+	return uint32(len(lines) + 1), 0
+}
+
+func (e *memoryEngine) Read(partition uint32, line uint32) ([]byte, int) {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	lines := e.partitions[partition]
+	if line == 0 || int64(line) > int64(len(lines)) {
+		return nil, helpers.ErrorInternalFormatting
+	}
+	return append([]byte{}, lines[line-1]...), 0
+}
+
+func (e *memoryEngine) Update(partition uint32, line uint32, jData []byte) int {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	lines := e.partitions[partition]
+	if line == 0 || int64(line) > int64(len(lines)) {
+		return helpers.ErrorInternalFormatting
+	}
+	lines[line-1] = append([]byte{}, jData...)
+	return 0
+}
+
+func (e *memoryEngine) Lines(partition uint32) (int, int) {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	return len(e.partitions[partition]), 0
+}
+
+func (e *memoryEngine) Partitions() ([]uint32, int) {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	partitions := make([]uint32, 0, len(e.partitions))
+	for p := range e.partitions {
+		partitions = append(partitions, p)
+	}
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+	return partitions, 0
+}
+
+func (e *memoryEngine) Compact(partition uint32) (map[uint32]uint32, int) {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	moved := make(map[uint32]uint32)
+	var lines [][]byte
+	for i, line := range e.partitions[partition] {
+		if len(line) == 0 {
+			continue
+		}
+		lines = append(lines, line)
+		moved[uint32(i+1)] = uint32(len(lines))
+	}
+	e.partitions[partition] = lines
+	return moved, 0
+}
+
+func (e *memoryEngine) SetSyncPolicy(policy uint8) {}
+
+func (e *memoryEngine) Delete() int {
+	e.mux.Lock()
+	e.partitions = make(map[uint32][][]byte)
+	e.mux.Unlock()
+	return 0
+}
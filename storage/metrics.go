@@ -0,0 +1,137 @@
+/*
+storage package Copyright 2020 Dominique Debergue
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at:
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+either express or implied. See the License for the specific
+language governing permissions and limitations under the License.
+*/
+
+package storage
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Metrics
+//
+// The storage engine counts how often GetOpenFile finds a file already open (a hit) or has to open it (a miss), how
+// many OpenFiles are closed to make room for another, and the bytes read from and written to data files. Read,
+// Insert, Update and Compact also record how long they take in a latency histogram. Stats() gets a snapshot of
+// all of them.
+
+// Storage operations with a latency histogram
+const (
+	OperationRead    = "read"
+	OperationInsert  = "insert"
+	OperationUpdate  = "update"
+	OperationCompact = "compact"
+)
+
+var (
+	// Counters
+	openFileHits   uint64
+	openFileMisses uint64
+	evictions      uint64
+	bytesRead      uint64
+	bytesWritten   uint64
+
+	// Latency histograms
+	readLatency    latencyHistogram
+	insertLatency  latencyHistogram
+	updateLatency  latencyHistogram
+	compactLatency latencyHistogram
+
+	// Upper bounds of the latency histogram buckets
+	latencyBuckets [14]time.Duration = [14]time.Duration{
+		50 * time.Microsecond, 100 * time.Microsecond, 250 * time.Microsecond, 500 * time.Microsecond,
+		time.Millisecond, 2500 * time.Microsecond, 5 * time.Millisecond, 10 * time.Millisecond,
+		25 * time.Millisecond, 50 * time.Millisecond, 100 * time.Millisecond, 250 * time.Millisecond,
+		500 * time.Millisecond, time.Second,
+	}
+)
+
+// Metrics is a snapshot of the storage engine's metrics.
+type Metrics struct {
+	OpenFiles      int                     // OpenFiles that are open now
+	OpenFileHits   uint64                  // GetOpenFile calls that found the file open
+	OpenFileMisses uint64                  // GetOpenFile calls that had to open the file
+	Evictions      uint64                  // least accessed OpenFiles closed to make room for another
+	BytesRead      uint64                  // bytes of lines read from data files
+	BytesWritten   uint64                  // bytes written to data files by Insert and Update
+	Latency        map[string]LatencyStats // latency histograms by operation
+}
+
+// LatencyStats is the latency histogram of a storage operation.
+type LatencyStats struct {
+	Count   uint64          // number of operations
+	Total   time.Duration   // time taken by all of the operations
+	Buckets []LatencyBucket // cumulative buckets, from the lowest upper bound
+}
+
+// LatencyBucket is the number of operations that took at most Max. Operations over the largest Max are only in
+// the LatencyStats' Count.
+type LatencyBucket struct {
+	Max   time.Duration
+	Count uint64
+}
+
+type latencyHistogram struct {
+	count   uint64
+	total   uint64 // nanoseconds
+	buckets [len(latencyBuckets)]uint64
+}
+
+// Stats gets a snapshot of the storage engine's metrics.
+func Stats() Metrics {
+	return Metrics{
+		OpenFiles:      GetNumOpenFiles(),
+		OpenFileHits:   atomic.LoadUint64(&openFileHits),
+		OpenFileMisses: atomic.LoadUint64(&openFileMisses),
+		Evictions:      atomic.LoadUint64(&evictions),
+		BytesRead:      atomic.LoadUint64(&bytesRead),
+		BytesWritten:   atomic.LoadUint64(&bytesWritten),
+		Latency: map[string]LatencyStats{
+			OperationRead:    readLatency.stats(),
+			OperationInsert:  insertLatency.stats(),
+			OperationUpdate:  updateLatency.stats(),
+			OperationCompact: compactLatency.stats(),
+		},
+	}
+}
+
+// Records the time since start in the histogram. Made to be deferred at the start of an operation.
+func (h *latencyHistogram) observe(start time.Time) {
+	d := time.Since(start)
+	atomic.AddUint64(&h.count, 1)
+	atomic.AddUint64(&h.total, uint64(d))
+	for i, max := range latencyBuckets {
+		if d <= max {
+			atomic.AddUint64(&h.buckets[i], 1)
+			return
+		}
+	}
+}
+
+// Gets a snapshot of the histogram with cumulative buckets
+func (h *latencyHistogram) stats() LatencyStats {
+	s := LatencyStats{
+		Count:   atomic.LoadUint64(&h.count),
+		Total:   time.Duration(atomic.LoadUint64(&h.total)),
+		Buckets: make([]LatencyBucket, len(latencyBuckets)),
+	}
+	var c uint64
+	for i, max := range latencyBuckets {
+		c += atomic.LoadUint64(&h.buckets[i])
+		s.Buckets[i] = LatencyBucket{Max: max, Count: c}
+	}
+	return s
+}
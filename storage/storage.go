@@ -19,9 +19,12 @@ package storage
 
 import (
 	"github.com/hewiefreeman/GopherDB/helpers"
+	"bytes"
 	"encoding/json"
 	"io"
+	"math"
 	"os"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -31,7 +34,11 @@ import (
 const (
 	newLineIndicator     byte   = byte(10)
 	openBracketIndicator byte   = byte(91)
+	paddingIndicator     byte   = byte(32)
 	uint32Max            uint32 = 2147483647
+	maxLines             int64  = math.MaxUint32
+	slotPadding          int    = 4 // lines get 1/slotPadding of their length as room to grow
+	pageSize             int64  = 4096
 
 	defaultFileOpenTime  time.Duration = 20 * time.Second
 	defaultMaxOpenFiles  uint16        = 25
@@ -56,26 +63,40 @@ type OpenFile struct {
 	name        string
 	mux         sync.Mutex
 	file        *os.File
-	bytes       []byte
-	lineByteOn  []int64
+	log         *os.File // write-ahead log
+	dirty       bool     // has writes that haven't been flushed to the disk
+	size        int64   // size of the file on the disk
+	lineByteOn  []int64 // start of every line
+	lineByteEnd []int64 // new line indicator at the end of every line
 	indexStart  int64
 	accessed    uint32
 	expireTimer *time.Timer
 	cancelChan  chan bool
 }
 
-// Init initializes the storage package. Must be called before using.
-func Init() {
+// Init initializes the storage package with the root folder that tables and logs are kept in. An empty dataDir is
+// the working directory. Must be called before using.
+func Init(dataDir string) int {
 	openFilesMux.Lock()
 	if inited {
 		openFilesMux.Unlock()
-		return
+		return 0
+	}
+	if err := helpers.SetDataDirectory(dataDir); err != 0 {
+		openFilesMux.Unlock()
+		return err
 	}
 	fileOpenTime.Store(defaultFileOpenTime)
 	maxOpenFiles.Store(defaultMaxOpenFiles)
+	if syncInterval.Load() == nil {
+		syncInterval.Store(defaultSyncInterval)
+	}
 	openFiles = make(map[string]*OpenFile)
+	syncStop = make(chan bool)
+	go syncTimer(syncStop)
 	inited = true
 	openFilesMux.Unlock()
+	return 0
 }
 
 // ShutDown closes all OpenFiles and shuts the storage engine down.
@@ -90,13 +111,11 @@ func ShutDown() {
 		f.mux.Lock()
 		f.cancelChan <- true
 		close(f.cancelChan)
-		f.file.Truncate(int64(len(f.bytes)))
-		f.bytes = nil
-		f.lineByteOn = nil
-		f.file.Close()
+		f.close()
 		f.mux.Unlock()
 		delete(openFiles, fName)
 	}
+	close(syncStop)
 	inited = false
 	openFilesMux.Unlock()
 }
@@ -118,12 +137,10 @@ func newOpenFile(file string) (*OpenFile, int) {
 		laf.mux.Lock()
 		laf.cancelChan <- true
 		close(laf.cancelChan)
-		laf.file.Truncate(int64(len(laf.bytes)))
-		laf.bytes = nil
-		laf.lineByteOn = nil
-		laf.file.Close()
+		laf.close()
 		laf.mux.Unlock()
 		delete(openFiles, laf.name)
+		atomic.AddUint64(&evictions, 1)
 	}
 	// Open the File
 	var f *os.File
@@ -131,44 +148,54 @@ func newOpenFile(file string) (*OpenFile, int) {
 	if f, err = os.OpenFile(file, os.O_RDWR|os.O_CREATE, 0755); err != nil {
 		return nil, helpers.ErrorFileOpen
 	}
+	// Open the write-ahead log and finish any write left in it
+	log, lErr := openLog(file, f)
+	if lErr != 0 {
+		f.Close()
+		return nil, lErr
+	}
 	// Get file stats
 	var fs os.FileInfo
 	if fs, err = f.Stat(); err != nil {
+		f.Close()
+		log.Close()
 		return nil, helpers.ErrorFileOpen
 	}
 	// Make new OpenFile object
-	newOF := OpenFile{file: f, name: file, accessed: 1}
-	// Get file bytes
-	newOF.bytes = make([]byte, fs.Size())
-	_, rErr := f.ReadAt(newOF.bytes, 0)
-	if rErr != nil && rErr != io.EOF {
-		return nil, helpers.ErrorFileRead
-	}
+	newOF := OpenFile{file: f, log: log, name: file, accessed: 1, size: fs.Size()}
 	// Get indexing
-	if (len(newOF.bytes) == 0) {
+	if newOF.size == 0 {
 		// New file, create indexing layer
-		newOF.bytes = append(newOF.bytes, defaultIndexingBytes...)
+		newOF.size = int64(len(defaultIndexingBytes))
 		newOF.indexStart = 0
 		newOF.lineByteOn = []int64{}
-		if _, wErr := f.WriteAt(newOF.bytes, int64(0)); wErr != nil {
+		newOF.lineByteEnd = []int64{}
+		if _, wErr := f.WriteAt(defaultIndexingBytes, int64(0)); wErr != nil {
+			f.Close()
+			log.Close()
 			return nil, helpers.ErrorFileOpen
 		}
 	} else {
 		// Get indexing bytes
 		var iPosBytes []byte
-		for i := len(newOF.bytes) - 1; i >= 0; i-- {
-			if newOF.bytes[i] == openBracketIndicator {
-				iPosBytes = newOF.bytes[i:]
-				newOF.indexStart = int64(i)
-				break
-			} else if i == 0 {
-				return nil, helpers.ErrorJsonIndexingFormat
-			}
+		var iErr int
+		if newOF.indexStart, iPosBytes, iErr = readIndexing(f, newOF.size); iErr != 0 {
+			f.Close()
+			log.Close()
+			return nil, iErr
 		}
 		// Get indexing list
 		if err := json.Unmarshal(iPosBytes, &newOF.lineByteOn); err != nil {
+			f.Close()
+			log.Close()
 			return nil, helpers.ErrorJsonIndexingFormat
 		}
+		// Find the end of every line
+		if newOF.lineByteEnd, iErr = readLineEnds(f, newOF.indexStart, newOF.lineByteOn); iErr != 0 {
+			f.Close()
+			log.Close()
+			return nil, iErr
+		}
 	}
 	ofp := &newOF
 	// Start close timer
@@ -179,6 +206,75 @@ func newOpenFile(file string) (*OpenFile, int) {
 	return ofp, 0
 }
 
+// Reads a file backwards from the end one page at a time, until the start of the indexing is found. Reports back
+// the start of the indexing and the indexing bytes.
+func readIndexing(f *os.File, size int64) (int64, []byte, int) {
+	var tail []byte
+	for end := size; end > 0; {
+		start := end - pageSize
+		if start < 0 {
+			start = 0
+		}
+		page := make([]byte, end-start, size-start)
+		if _, err := f.ReadAt(page, start); err != nil && err != io.EOF {
+			return 0, nil, helpers.ErrorFileRead
+		}
+		tail = append(page, tail...)
+		if i := bytes.LastIndexByte(page, openBracketIndicator); i >= 0 {
+			return start + int64(i), tail[i:], 0
+		}
+		end = start
+	}
+	return 0, nil, helpers.ErrorJsonIndexingFormat
+}
+
+// Reads the data lines of a file one page at a time, and finds the new line indicator that ends every line.
+func readLineEnds(f *os.File, indexStart int64, lineByteOn []int64) ([]int64, int) {
+	var newLines []int64
+	page := make([]byte, pageSize)
+	for off := int64(0); off < indexStart; off += pageSize {
+		p := page
+		if indexStart-off < pageSize {
+			p = page[:indexStart-off]
+		}
+		if _, err := f.ReadAt(p, off); err != nil && err != io.EOF {
+			return nil, helpers.ErrorFileRead
+		}
+		for i := bytes.IndexByte(p, newLineIndicator); i >= 0; {
+			newLines = append(newLines, off+int64(i))
+			next := bytes.IndexByte(p[i+1:], newLineIndicator)
+			if next < 0 {
+				break
+			}
+			i += next + 1
+		}
+	}
+	// Lines that were moved are out of order, so find the first new line indicator after each line's start
+	lineByteEnd := make([]int64, len(lineByteOn))
+	for i, start := range lineByteOn {
+		j := sort.Search(len(newLines), func(j int) bool { return newLines[j] >= start })
+		if start < 0 || j == len(newLines) {
+			return nil, helpers.ErrorJsonIndexingFormat
+		}
+		lineByteEnd[i] = newLines[j]
+	}
+	return lineByteEnd, 0
+}
+
+// Closes the OpenFile's data file and removes it's write-ahead log - must lock f.mux before-hand.
+func (f *OpenFile) close() {
+	f.file.Truncate(f.size)
+	if f.dirty {
+		f.file.Sync()
+		f.dirty = false
+	}
+	f.lineByteOn = nil
+	f.lineByteEnd = nil
+	f.file.Close()
+	f.log.Close()
+	os.Remove(logFileName(f.name))
+}
+
 // GetOpenFile
 func GetOpenFile(file string) (*OpenFile, int) {
 	var f *OpenFile
@@ -189,6 +285,7 @@ func GetOpenFile(file string) (*OpenFile, int) {
 	}
 	f = openFiles[file]
 	if f == nil {
+		atomic.AddUint64(&openFileMisses, 1)
 		var fileErr int
 		if f, fileErr = newOpenFile(file); fileErr != 0 {
 			openFilesMux.Unlock()
@@ -196,6 +293,7 @@ func GetOpenFile(file string) (*OpenFile, int) {
 		}
 		openFiles[file] = f
 	} else {
+		atomic.AddUint64(&openFileHits, 1)
 		// If the closeTimer cannot be reset, the timer has already expired,
 		// but has not been removed. Make a new Timer and replace the closeTimer's
 		// Timer with the new Timer so that it cancels the fileCloseTimer() action.
@@ -230,10 +328,7 @@ func fileCloseTimer(f *OpenFile) {
 		openFilesMux.Unlock()
 		f.mux.Lock()
 		close(f.cancelChan)
-		f.file.Truncate(int64(len(f.bytes)))
-		f.bytes = nil
-		f.lineByteOn = nil
-		f.file.Close()
+		f.close()
 		f.mux.Unlock()
 	case <- f.cancelChan:
 		// Timer cancelled
@@ -252,7 +347,8 @@ func DeleteDir(dir string) error {
 }
 
 // Read opens a file by name, then returns the data from said line.
-func Read(file string, line uint16) ([]byte, int) {
+func Read(file string, line uint32) ([]byte, int) {
+	defer readLatency.observe(time.Now())
 	f, fErr := GetOpenFile(file)
 	if fErr != 0 {
 		return nil, fErr
@@ -261,65 +357,67 @@ func Read(file string, line uint16) ([]byte, int) {
 }
 
 // Read returns the data in an OpenFile from said line.
-func (f *OpenFile) Read(line uint16) ([]byte, int) {
+func (f *OpenFile) Read(line uint32) ([]byte, int) {
 	f.mux.Lock()
-	// Get the start and end index of line
-	bStart := f.lineByteOn[line-1]
-	var bEnd int64
-	if len(f.lineByteOn) == int(line) {
-		// Last item in file
-		bEnd = f.indexStart - 1
-	} else {
-		// Not last item...
-		bEnd = f.lineByteOn[line] - 1
+	b, err := f.readLine(line)
+	f.mux.Unlock()
+	return b, err
+}
+
+// Reads a line's data from the disk, and checks it's checksum - must lock f.mux before-hand.
+func (f *OpenFile) readLine(line uint32) ([]byte, int) {
+	bStart, bEnd, err := f.lineSlot(line)
+	if err != 0 {
+		return nil, err
 	}
-	if bEnd < bStart {
-		return nil, helpers.ErrorInternalFormatting
+	b := make([]byte, bEnd-bStart)
+	if _, rErr := f.file.ReadAt(b, bStart); rErr != nil && rErr != io.EOF {
+		return nil, helpers.ErrorFileRead
 	}
-	bytes := f.bytes[bStart:bEnd]
-	f.mux.Unlock()
-	return bytes, 0
+	atomic.AddUint64(&bytesRead, uint64(len(b)))
+	return readRecord(b)
 }
 
 // Update updates JSON encoded []byte line at given index of given file
-func Update(file string, line uint16, jData []byte) int {
+func Update(file string, line uint32, jData []byte) int {
+	defer updateLatency.observe(time.Now())
 	f, fErr := GetOpenFile(file)
 	if fErr != 0 {
 		return fErr
 	}
 
 	f.mux.Lock()
-
-	// Get the start and end index of line
-	iStart := f.lineByteOn[line-1]
-	var iEnd int64
-	if len(f.lineByteOn) == int(line) {
-		// Last item in file
-		iEnd = f.indexStart - 1
-	} else {
-		// Not last item...
-		iEnd = f.lineByteOn[line] - 1
-	}
-	// Calculate byte difference for subsequent lines
-	iDif := int64(len(jData)) - (iEnd - iStart)
-	for i := int(line); i < len(f.lineByteOn); i++ {
-		f.lineByteOn[i] += iDif
+	iStart, iEnd, err := f.lineSlot(line)
+	if err != 0 {
+		f.mux.Unlock()
+		return err
 	}
-	indexStart := f.indexStart
-	f.indexStart += iDif
-	// Make indexing data
-	lineByteOnData, err := helpers.Fjson.Marshal(f.lineByteOn)
-	if err != nil {
-		return helpers.ErrorInternalFormatting
+
+	if rec := makeRecord(jData); int64(len(rec)) <= iEnd-iStart {
+		// Fits in the line's slot - overwrite the slot and pad the rest of it
+		slot := make([]byte, iEnd-iStart)
+		copy(slot, rec)
+		for i := len(rec); i < len(slot); i++ {
+			slot[i] = paddingIndicator
+		}
+		if !f.write(slot, iStart, f.size) {
+			f.mux.Unlock()
+			return helpers.ErrorFileUpdate
+		}
+		atomic.AddUint64(&bytesWritten, uint64(len(slot)))
+		f.mux.Unlock()
+		return 0
 	}
-	// Make & push data
-	rHalf := append(jData, f.bytes[iEnd:indexStart]...)
-	rHalf = append(rHalf, lineByteOnData...)
-	f.bytes = append(f.bytes[:iStart], rHalf...)
 
-	if _, wErr := f.file.WriteAt(rHalf, int64(iStart)); wErr != nil {
+	// Outgrew the slot - move the line to the end of the data lines. The old slot is left as dead space.
+	if err = f.appendLine(line, jData); err != 0 {
+		f.lineByteOn[line-1] = iStart
+		f.lineByteEnd[line-1] = iEnd
 		f.mux.Unlock()
-		return helpers.ErrorFileUpdate
+		if err == helpers.ErrorFileAppend {
+			return helpers.ErrorFileUpdate
+		}
+		return err
 	}
 	f.mux.Unlock()
 	return 0
@@ -327,33 +425,141 @@ func Update(file string, line uint16, jData []byte) int {
 
 // Insert appends a JSON encoded []byte at the end of given JSON file and reports back the
 // line number that was written to
-func Insert(file string, jData []byte) (uint16, int) {
+func Insert(file string, jData []byte) (uint32, int) {
+	return insertRecord(file, makeRecord(jData))
+}
+
+// Reserve appends an empty line at the end of given JSON file, with a slot that jData fits in without moving the
+// line, and reports back the line number. The slot is only padding, so the line reads as empty until it's updated.
+func Reserve(file string, jData []byte) (uint32, int) {
+	return insertRecord(file, bytes.Repeat([]byte{paddingIndicator}, len(makeRecord(jData))))
+}
+
+// Appends a record in a new line at the end of given JSON file, and reports back the line number
+func insertRecord(file string, rec []byte) (uint32, int) {
+	defer insertLatency.observe(time.Now())
 	f, fErr := GetOpenFile(file)
 	if fErr != 0 {
 		return 0, fErr
 	}
 	f.mux.Lock()
+	if int64(len(f.lineByteOn)) >= maxLines {
+		f.mux.Unlock()
+		return 0, helpers.ErrorFileAppend
+	}
 	// Insert and get lineOn
-	lineOn := uint16(len(f.lineByteOn) + 1)
+	lineOn := uint32(len(f.lineByteOn) + 1)
 	f.lineByteOn = append(f.lineByteOn, f.indexStart)
+	f.lineByteEnd = append(f.lineByteEnd, f.indexStart)
+	if err := f.appendRecord(lineOn, rec); err != 0 {
+		f.lineByteOn = f.lineByteOn[:len(f.lineByteOn)-1]
+		f.lineByteEnd = f.lineByteEnd[:len(f.lineByteEnd)-1]
+		f.mux.Unlock()
+		return 0, err
+	}
+	f.mux.Unlock()
+	return lineOn, 0
+}
+
+// Gets the start and end index of a line's slot. The slot holds the line's data followed by it's padding, and
+// ends before the line's new line indicator - must lock f.mux before-hand.
+func (f *OpenFile) lineSlot(line uint32) (int64, int64, int) {
+	if line == 0 || int64(line) > int64(len(f.lineByteOn)) {
+		return 0, 0, helpers.ErrorInternalFormatting
+	}
+	start, end := f.lineByteOn[line-1], f.lineByteEnd[line-1]
+	if start < 0 || end < start || end >= f.indexStart {
+		return 0, 0, helpers.ErrorInternalFormatting
+	}
+	return start, end, 0
+}
+
+// Moves a line to a new padded slot for jData at the start of the indexing, then writes the indexing after
+// it - must lock f.mux before-hand.
+func (f *OpenFile) appendLine(line uint32, jData []byte) int {
+	return f.appendRecord(line, makeRecord(jData))
+}
+
+// Moves a line to a new padded slot for a record at the start of the indexing, then writes the indexing after
+// it - must lock f.mux before-hand.
+func (f *OpenFile) appendRecord(line uint32, jRec []byte) int {
+	iStart := f.indexStart
+	f.lineByteOn[line-1] = iStart
 	// Make indexing data
 	lineByteOnData, err := helpers.Fjson.Marshal(f.lineByteOn)
 	if err != nil {
-		return 0, helpers.ErrorInternalFormatting
+		return helpers.ErrorInternalFormatting
 	}
-	// Append a new line to jData and get new indexStart
-	jData = append(jData, newLineIndicator)
-	iStart := f.indexStart
-	f.indexStart += int64(len(jData))
-	// Append lineByteOnData and write jData to disk
-	jData = append(jData, lineByteOnData...)
-	if _, wErr := f.file.WriteAt(jData, iStart); wErr != nil {
+	// Make the slot and indexing
+	rec := appendSlot(make([]byte, 0, len(jRec)+(len(jRec)/slotPadding)+1+len(lineByteOnData)), jRec)
+	slotEnd := len(rec)
+	rec = append(rec, lineByteOnData...)
+	// Write the slot and indexing to disk
+	if !f.write(rec, iStart, iStart+int64(len(rec))) {
+		return helpers.ErrorFileAppend
+	}
+	atomic.AddUint64(&bytesWritten, uint64(len(rec)))
+	f.lineByteEnd[line-1] = iStart + int64(slotEnd) - 1
+	f.indexStart = iStart + int64(slotEnd)
+	f.size = iStart + int64(len(rec))
+	return 0
+}
+
+// Appends a padded slot for a record to b, and ends it with a new line
+func appendSlot(b []byte, rec []byte) []byte {
+	b = append(b, rec...)
+	for i := 0; i < len(rec)/slotPadding; i++ {
+		b = append(b, paddingIndicator)
+	}
+	return append(b, newLineIndicator)
+}
+
+// Compact opens a file by name, then rewrites it without it's empty lines and the dead space left by lines that
+// were moved. Reports back the new line number of every line that was kept, by it's old line number.
+func Compact(file string) (map[uint32]uint32, int) {
+	defer compactLatency.observe(time.Now())
+	f, fErr := GetOpenFile(file)
+	if fErr != 0 {
+		return nil, fErr
+	}
+	f.mux.Lock()
+	moved := make(map[uint32]uint32)
+	lineByteOn := make([]int64, 0, len(f.lineByteOn))
+	lineByteEnd := make([]int64, 0, len(f.lineByteOn))
+	var b []byte
+	for i := range f.lineByteOn {
+		line, err := f.readLine(uint32(i + 1))
+		if err != 0 {
+			f.mux.Unlock()
+			return nil, err
+		} else if len(line) == 0 {
+			// Deleted line
+			continue
+		}
+		lineByteOn = append(lineByteOn, int64(len(b)))
+		b = appendSlot(b, makeRecord(line))
+		lineByteEnd = append(lineByteEnd, int64(len(b)-1))
+		moved[uint32(i+1)] = uint32(len(lineByteOn))
+	}
+	// Make indexing data
+	indexStart := int64(len(b))
+	lineByteOnData, err := helpers.Fjson.Marshal(lineByteOn)
+	if err != nil {
 		f.mux.Unlock()
-		return 0, helpers.ErrorFileAppend
+		return nil, helpers.ErrorInternalFormatting
 	}
-	f.bytes = append(f.bytes[:iStart], jData...)
+	b = append(b, lineByteOnData...)
+	// Replace the whole file
+	if !f.write(b, 0, int64(len(b))) {
+		f.mux.Unlock()
+		return nil, helpers.ErrorFileWrite
+	}
+	f.lineByteOn = lineByteOn
+	f.lineByteEnd = lineByteEnd
+	f.indexStart = indexStart
+	f.size = int64(len(b))
 	f.mux.Unlock()
-	return lineOn, 0
+	return moved, 0
 }
 
 // SetFileOpenTime preference allows you to keep OpenFiles open for a given duration.
@@ -1,14 +1,23 @@
 package storage
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"github.com/hewiefreeman/GopherDB/helpers"
 	"github.com/hewiefreeman/GopherDB/storage"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"strconv"
 	"testing"
 	"fmt"
 )
 
 func TestStorageInsert(t *testing.T) {
-	storage.Init()
-	var b uint16
+	storage.Init("")
+	var b uint32
 	var err int
 	if b, err = storage.Insert("0.gdbs", []byte("123geegee")); err != 0 {
 		t.Errorf("Error inserting to file: %v", err)
@@ -23,4 +32,285 @@ func TestStorageGet(t *testing.T) {
 		t.Errorf("Error reading file: %v", err)
 	}
 	fmt.Println(string(b))
-}
\ No newline at end of file
+}
+func TestWriteAheadLogReplay(t *testing.T) {
+	storage.Init("")
+	os.Remove("wal.gdbs")
+	defer os.Remove("wal.gdbs")
+	storage.Insert("wal.gdbs", []byte("\"line one\""))
+	storage.Insert("wal.gdbs", []byte("\"line two\""))
+	storage.ShutDown()
+	before, _ := ioutil.ReadFile("wal.gdbs")
+	// Get the file after an Update
+	storage.Init("")
+	if err := storage.Update("wal.gdbs", 1, []byte("\"line one, updated\"")); err != 0 {
+		t.Errorf("Error updating file: %v", err)
+		return
+	}
+	storage.ShutDown()
+	after, _ := ioutil.ReadFile("wal.gdbs")
+	if _, err := os.Stat("wal" + helpers.FileTypeLog); !os.IsNotExist(err) {
+		t.Errorf("Expected the write-ahead log to be removed after closing the file")
+	}
+	// Crash part-way through the Update: the file has the old bytes with a torn tail, and the log has the whole write
+	off := 0
+	for off < len(before) && before[off] == after[off] {
+		off++
+	}
+	torn := append(append([]byte{}, before[:off]...), after[off:off+3]...)
+	ioutil.WriteFile("wal.gdbs", torn, 0755)
+	ioutil.WriteFile("wal"+helpers.FileTypeLog, makeLogRecord(after[off:], off, len(after)), 0755)
+	storage.Init("")
+	b, err := storage.Read("wal.gdbs", 1)
+	if err != 0 || string(b) != "\"line one, updated\"" {
+		t.Errorf("Expected the Update to be replayed, but got: %v %v", string(b), err)
+	}
+	if b, err = storage.Read("wal.gdbs", 2); err != 0 || string(b) != "\"line two\"" {
+		t.Errorf("Expected line 2 to be unchanged, but got: %v %v", string(b), err)
+	}
+	storage.ShutDown()
+	// A torn log record never reached the data file, and must be thrown away
+	ioutil.WriteFile("wal"+helpers.FileTypeLog, makeLogRecord([]byte("garbage"), 0, 7)[:10], 0755)
+	storage.Init("")
+	if b, err = storage.Read("wal.gdbs", 1); err != 0 || string(b) != "\"line one, updated\"" {
+		t.Errorf("Expected a torn log record to be ignored, but got: %v %v", string(b), err)
+	}
+	storage.ShutDown()
+}
+
+func TestUpdateSlots(t *testing.T) {
+	storage.Init("")
+	os.Remove("slots.gdbs")
+	defer os.Remove("slots.gdbs")
+	storage.Insert("slots.gdbs", []byte("\"line one\""))
+	storage.Insert("slots.gdbs", []byte("\"line two\""))
+	storage.Insert("slots.gdbs", []byte("\"line three\""))
+	storage.ShutDown()
+	before, _ := ioutil.ReadFile("slots.gdbs")
+	// Updates that fit in a line's slot don't move anything
+	storage.Init("")
+	storage.Update("slots.gdbs", 1, []byte("\"line 1\""))
+	storage.Update("slots.gdbs", 2, []byte("\"line two!\""))
+	storage.ShutDown()
+	after, _ := ioutil.ReadFile("slots.gdbs")
+	if len(before) != len(after) || string(before[bytes.LastIndexByte(before, '['):]) != string(after[bytes.LastIndexByte(after, '['):]) {
+		t.Errorf("Expected updates that fit to keep the file size and indexing, but got: %v", string(after))
+	}
+	// Updates that don't fit move the line
+	storage.Init("")
+	if err := storage.Update("slots.gdbs", 1, []byte("\"line one, but much longer\"")); err != 0 {
+		t.Errorf("Error updating file: %v", err)
+	}
+	storage.ShutDown()
+	storage.Init("")
+	checkLines(t, "slots.gdbs", "\"line one, but much longer\"", "\"line two!\"", "\"line three\"")
+	// Reserved lines are empty, and writing what they were reserved for doesn't move them
+	line, err := storage.Reserve("slots.gdbs", []byte("\"line four\""))
+	if err != 0 || line != 4 {
+		t.Errorf("Expected to reserve line 4, but got: %v %v", line, err)
+	}
+	storage.SyncFile("slots.gdbs")
+	storage.ShutDown()
+	before, _ = ioutil.ReadFile("slots.gdbs")
+	storage.Init("")
+	checkLines(t, "slots.gdbs", "\"line one, but much longer\"", "\"line two!\"", "\"line three\"", "")
+	storage.Update("slots.gdbs", 4, []byte("\"line four\""))
+	storage.ShutDown()
+	after, _ = ioutil.ReadFile("slots.gdbs")
+	if len(before) != len(after) || string(before[bytes.LastIndexByte(before, '['):]) != string(after[bytes.LastIndexByte(after, '['):]) {
+		t.Errorf("Expected writing a reserved line to keep the file size and indexing, but got: %v", string(after))
+	}
+	storage.Init("")
+	checkLines(t, "slots.gdbs", "\"line one, but much longer\"", "\"line two!\"", "\"line three\"", "\"line four\"")
+	storage.ShutDown()
+	// Files made before lines were padded must still load
+	ioutil.WriteFile("slots.gdbs", []byte("\"a\"\n\"b\"\n[0,4]"), 0755)
+	storage.Init("")
+	checkLines(t, "slots.gdbs", "\"a\"", "\"b\"")
+	storage.Update("slots.gdbs", 1, []byte("\"c\""))
+	storage.Update("slots.gdbs", 2, []byte("\"bigger\""))
+	checkLines(t, "slots.gdbs", "\"c\"", "\"bigger\"")
+	storage.ShutDown()
+}
+
+func TestPagedReads(t *testing.T) {
+	storage.Init("")
+	os.Remove("pages.gdbs")
+	defer os.Remove("pages.gdbs")
+	// Enough lines for the data and indexing to span many pages
+	lines := make([]string, 1000)
+	for i := range lines {
+		lines[i] = "\"line " + strconv.Itoa(i) + "\""
+		storage.Insert("pages.gdbs", []byte(lines[i]))
+	}
+	// Move some lines out of order
+	for i := 0; i < len(lines); i += 100 {
+		lines[i] = "\"line " + strconv.Itoa(i) + " has moved to the end of the file\""
+		if err := storage.Update("pages.gdbs", uint32(i+1), []byte(lines[i])); err != 0 {
+			t.Errorf("Error updating file: %v", err)
+		}
+	}
+	storage.ShutDown()
+	storage.Init("")
+	checkLines(t, "pages.gdbs", lines...)
+	storage.ShutDown()
+}
+
+func TestChecksums(t *testing.T) {
+	storage.Init("")
+	os.Remove("checksums.gdbs")
+	defer os.Remove("checksums.gdbs")
+	storage.Insert("checksums.gdbs", []byte("\"line one\""))
+	storage.Insert("checksums.gdbs", []byte("\"line two\""))
+	storage.ShutDown()
+	// Change a byte of line one on the disk
+	b, _ := ioutil.ReadFile("checksums.gdbs")
+	b[bytes.Index(b, []byte("one"))] = 'O'
+	ioutil.WriteFile("checksums.gdbs", b, 0755)
+	storage.Init("")
+	if _, err := storage.Read("checksums.gdbs", 1); err != helpers.ErrorChecksumMismatch {
+		t.Errorf("Expected error %v reading a corrupt line, but got: %v", helpers.ErrorChecksumMismatch, err)
+	}
+	if b, err := storage.Read("checksums.gdbs", 2); err != 0 || string(b) != "\"line two\"" {
+		t.Errorf("Expected line 2 to be unchanged, but got: %v %v", string(b), err)
+	}
+	// Lines can hold new lines, escapes and trailing spaces
+	binaryLine := []byte{0, 10, 1, 32, 92, 110, 10, 32}
+	storage.Insert("checksums.gdbs", binaryLine)
+	storage.ShutDown()
+	storage.Init("")
+	if b, err := storage.Read("checksums.gdbs", 3); err != 0 || !bytes.Equal(b, binaryLine) {
+		t.Errorf("Expected line 3 to be %v, but got: %v %v", binaryLine, b, err)
+	}
+	storage.ShutDown()
+	// Lines written in base64 are still read
+	sum := make([]byte, 4)
+	binary.BigEndian.PutUint32(sum, crc32.Checksum(binaryLine, crc32.MakeTable(crc32.Castagnoli)))
+	ioutil.WriteFile("checksums.gdbs", []byte("$"+hex.EncodeToString(sum)+base64.RawStdEncoding.EncodeToString(binaryLine)+"\n[0]"), 0755)
+	storage.Init("")
+	checkLines(t, "checksums.gdbs", string(binaryLine))
+	storage.ShutDown()
+}
+
+func TestEngines(t *testing.T) {
+	storage.Init("")
+	defer storage.ShutDown()
+	if _, err := storage.NewEngine("nope", "engine-test"); err != helpers.ErrorInvalidEngine {
+		t.Errorf("Expected error %v for an unknown engine, but got: %v", helpers.ErrorInvalidEngine, err)
+	}
+	for _, name := range []string{storage.EngineFile, storage.EngineMemory} {
+		e, err := storage.NewEngine(name, "engine-test")
+		if err != 0 {
+			t.Errorf("Error getting engine %v: %v", name, err)
+			continue
+		}
+		if err = e.Create(); err != 0 {
+			t.Errorf("Error creating engine %v: %v", name, err)
+			continue
+		}
+		e.Insert(0, []byte("\"line one\""))
+		e.Insert(2, []byte("\"line two\""))
+		e.Insert(2, []byte("\"line three\""))
+		e.Update(2, 1, []byte{})
+		if p, _ := e.Partitions(); len(p) != 2 || p[0] != 0 || p[1] != 2 {
+			t.Errorf("Expected partitions [0 2] from engine %v, but got: %v", name, p)
+		}
+		if moved, err := e.Compact(2); err != 0 || moved[2] != 1 {
+			t.Errorf("Expected engine %v to move line 2 to line 1, but got: %v %v", name, moved, err)
+		}
+		if lines, _ := e.Lines(2); lines != 1 {
+			t.Errorf("Expected 1 line in partition 2 of engine %v, but got: %v", name, lines)
+		}
+		if b, err := e.Read(2, 1); err != 0 || string(b) != "\"line three\"" {
+			t.Errorf("Expected line three from engine %v, but got: %v %v", name, string(b), err)
+		}
+		if err = e.Delete(); err != 0 {
+			t.Errorf("Error deleting engine %v: %v", name, err)
+		}
+	}
+}
+
+func TestSyncPolicy(t *testing.T) {
+	if p := storage.GetSyncPolicy("Keystore-sync"); p != helpers.DefaultSyncPolicy {
+		t.Errorf("Expected the default sync policy, but got: %v", p)
+	}
+	storage.SetSyncPolicy("Keystore-sync/", helpers.SyncPolicyAlways)
+	if p := storage.GetSyncPolicy("Keystore-sync"); p != helpers.SyncPolicyAlways {
+		t.Errorf("Expected sync policy %v, but got: %v", helpers.SyncPolicyAlways, p)
+	}
+	storage.SetSyncPolicy("Keystore-sync", 100)
+	if p := storage.GetSyncPolicy("Keystore-sync"); p != helpers.DefaultSyncPolicy {
+		t.Errorf("Expected an invalid sync policy to be the default, but got: %v", p)
+	}
+	// Writes with every policy
+	storage.Init("")
+	defer os.Remove("sync.gdbs")
+	for _, p := range []uint8{helpers.SyncPolicyNever, helpers.SyncPolicyInterval, helpers.SyncPolicyAlways} {
+		storage.SetSyncPolicy(".", p)
+		if _, err := storage.Insert("sync.gdbs", []byte("\"data\"")); err != 0 {
+			t.Errorf("Error inserting with sync policy %v: %v", p, err)
+		}
+	}
+	storage.ShutDown()
+}
+
+func TestStats(t *testing.T) {
+	os.Remove("stats-1.gdbs")
+	os.Remove("stats-2.gdbs")
+	defer os.Remove("stats-1.gdbs")
+	defer os.Remove("stats-2.gdbs")
+	storage.Init("")
+	defer storage.ShutDown()
+	storage.SetMaxOpenFiles(1)
+	defer storage.SetMaxOpenFiles(25)
+	before := storage.Stats()
+	storage.Insert("stats-1.gdbs", []byte("\"one\""))   // miss
+	storage.Update("stats-1.gdbs", 1, []byte("\"1\""))   // hit
+	storage.Read("stats-1.gdbs", 1)                      // hit
+	storage.Insert("stats-2.gdbs", []byte("\"two\""))   // miss - evicts stats-1.gdbs
+	after := storage.Stats()
+	if n := after.OpenFileMisses - before.OpenFileMisses; n != 2 {
+		t.Errorf("Expected 2 open file misses, but got: %v", n)
+	}
+	if n := after.OpenFileHits - before.OpenFileHits; n != 2 {
+		t.Errorf("Expected 2 open file hits, but got: %v", n)
+	}
+	if n := after.Evictions - before.Evictions; n != 1 {
+		t.Errorf("Expected 1 eviction, but got: %v", n)
+	}
+	if after.OpenFiles != 1 {
+		t.Errorf("Expected 1 open file, but got: %v", after.OpenFiles)
+	}
+	if after.BytesWritten <= before.BytesWritten || after.BytesRead <= before.BytesRead {
+		t.Errorf("Expected bytes to be written and read, but got: %v %v", after.BytesWritten-before.BytesWritten, after.BytesRead-before.BytesRead)
+	}
+	for op, n := range map[string]uint64{storage.OperationInsert: 2, storage.OperationUpdate: 1, storage.OperationRead: 1} {
+		l := after.Latency[op]
+		if c := l.Count - before.Latency[op].Count; c != n {
+			t.Errorf("Expected %v %v operations, but got: %v", n, op, c)
+		}
+		if len(l.Buckets) == 0 || l.Buckets[len(l.Buckets)-1].Count > l.Count {
+			t.Errorf("Expected cumulative %v buckets up to %v, but got: %v", op, l.Count, l.Buckets)
+		}
+	}
+}
+
+// Checks every line in a file
+func checkLines(t *testing.T, file string, lines ...string) {
+	for i, line := range lines {
+		if b, err := storage.Read(file, uint32(i+1)); err != 0 || string(b) != line {
+			t.Errorf("Expected %v on line %v, but got: %v %v", line, i+1, string(b), err)
+		}
+	}
+}
+
+// Makes a write-ahead log record: [offset: 8 bytes][length: 4 bytes][file size: 8 bytes][bytes][crc32: 4 bytes]
+func makeLogRecord(b []byte, off int, size int) []byte {
+	rec := make([]byte, 20+len(b)+4)
+	binary.BigEndian.PutUint64(rec, uint64(off))
+	binary.BigEndian.PutUint32(rec[8:], uint32(len(b)))
+	binary.BigEndian.PutUint64(rec[12:], uint64(size))
+	copy(rec[20:], b)
+	binary.BigEndian.PutUint32(rec[20+len(b):], crc32.ChecksumIEEE(rec[:20+len(b)]))
+	return rec
+}
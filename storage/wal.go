@@ -0,0 +1,244 @@
+/*
+storage package Copyright 2020 Dominique Debergue
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at:
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+either express or implied. See the License for the specific
+language governing permissions and limitations under the License.
+*/
+
+package storage
+
+import (
+	"encoding/binary"
+	"github.com/hewiefreeman/GopherDB/helpers"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Write-ahead log
+//
+// Every OpenFile has a log file next to it, named after the data file with the FileTypeLog extension. Before the
+// bytes of an Insert or Update are written to a data file, they are written to the log as a single record:
+//
+//     [offset: 8 bytes][length: 4 bytes][file size: 8 bytes][bytes: length bytes][crc32 of everything before it: 4 bytes]
+//
+// Once the data file is written, the log is emptied. Writing a record's bytes at it's offset and truncating the file
+// to it's file size always gives the same result, so a record left in a log by a crash is safely replayed the next
+// time the data file is opened. A record with a bad checksum never made it to the data file, and is thrown away.
+//
+// The log is removed when an OpenFile is closed, so a log found on the disk means the file was not closed cleanly.
+
+const (
+	logHeaderSize int = 20
+	logFooterSize int = 4
+
+	defaultSyncInterval time.Duration = time.Second
+)
+
+var (
+	// Sync policies by table folder
+	syncPoliciesMux sync.Mutex
+	syncPolicies    map[string]uint8 = make(map[string]uint8)
+
+	// Settings
+	syncInterval atomic.Value // time.Duration
+
+	syncStop chan bool // stops the syncTimer - locked by openFilesMux
+)
+
+// Gets the log file name for a data file
+func logFileName(file string) string {
+	return strings.TrimSuffix(file, filepath.Ext(file)) + helpers.FileTypeLog
+}
+
+// Opens the log for a data file, and replays the record left in it if there is one.
+func openLog(file string, f *os.File) (*os.File, int) {
+	log, err := os.OpenFile(logFileName(file), os.O_RDWR|os.O_CREATE, 0755)
+	if err != nil {
+		return nil, helpers.ErrorFileOpen
+	}
+	rec, rErr := io.ReadAll(log)
+	if rErr != nil {
+		log.Close()
+		return nil, helpers.ErrorFileRead
+	}
+	if len(rec) == 0 {
+		return log, 0
+	}
+	if off, size, b, ok := readLogRecord(rec); ok {
+		// Replay the write
+		if _, wErr := f.WriteAt(b, off); wErr != nil {
+			log.Close()
+			return nil, helpers.ErrorFileWrite
+		}
+		if tErr := f.Truncate(size); tErr != nil {
+			log.Close()
+			return nil, helpers.ErrorFileWrite
+		}
+		if sErr := f.Sync(); sErr != nil {
+			log.Close()
+			return nil, helpers.ErrorFileWrite
+		}
+		helpers.LogAndPrint("Replayed an unfinished write to '"+file+"' from it's write-ahead log", 4)
+	}
+	if tErr := log.Truncate(0); tErr != nil {
+		log.Close()
+		return nil, helpers.ErrorFileWrite
+	}
+	return log, 0
+}
+
+// Makes a log record for writing b at off, and truncating the file to size
+func makeLogRecord(b []byte, off int64, size int64) []byte {
+	rec := make([]byte, logHeaderSize+len(b)+logFooterSize)
+	binary.BigEndian.PutUint64(rec, uint64(off))
+	binary.BigEndian.PutUint32(rec[8:], uint32(len(b)))
+	binary.BigEndian.PutUint64(rec[12:], uint64(size))
+	copy(rec[logHeaderSize:], b)
+	binary.BigEndian.PutUint32(rec[logHeaderSize+len(b):], crc32.ChecksumIEEE(rec[:logHeaderSize+len(b)]))
+	return rec
+}
+
+// Gets the offset, file size and bytes from a log record. Returns false if the record is incomplete or corrupt.
+func readLogRecord(rec []byte) (int64, int64, []byte, bool) {
+	if len(rec) < logHeaderSize+logFooterSize {
+		return 0, 0, nil, false
+	}
+	l := int(binary.BigEndian.Uint32(rec[8:]))
+	if l < 0 || len(rec) < logHeaderSize+l+logFooterSize {
+		return 0, 0, nil, false
+	}
+	if crc32.ChecksumIEEE(rec[:logHeaderSize+l]) != binary.BigEndian.Uint32(rec[logHeaderSize+l:]) {
+		return 0, 0, nil, false
+	}
+	return int64(binary.BigEndian.Uint64(rec)), int64(binary.BigEndian.Uint64(rec[12:])), rec[logHeaderSize : logHeaderSize+l], true
+}
+
+// Writes b to the OpenFile at off through the write-ahead log, and truncates the file to size - must lock f.mux before-hand.
+func (f *OpenFile) write(b []byte, off int64, size int64) bool {
+	policy := getSyncPolicy(f.name)
+	// Log the write
+	if _, err := f.log.WriteAt(makeLogRecord(b, off, size), 0); err != nil {
+		return false
+	}
+	if policy == helpers.SyncPolicyAlways {
+		if err := f.log.Sync(); err != nil {
+			return false
+		}
+	}
+	// Write to the data file
+	if _, err := f.file.WriteAt(b, off); err != nil {
+		return false
+	}
+	if err := f.file.Truncate(size); err != nil {
+		return false
+	}
+	switch policy {
+	case helpers.SyncPolicyAlways:
+		if err := f.file.Sync(); err != nil {
+			return false
+		}
+	case helpers.SyncPolicyInterval:
+		f.dirty = true
+	}
+	// Write is done - empty the log
+	f.log.Truncate(0)
+	return true
+}
+
+// SetSyncPolicy sets the sync policy for the data files in a table's folder.
+func SetSyncPolicy(folder string, policy uint8) {
+	if policy > helpers.SyncPolicyAlways {
+		policy = helpers.DefaultSyncPolicy
+	}
+	syncPoliciesMux.Lock()
+	syncPolicies[filepath.Clean(folder)] = policy
+	syncPoliciesMux.Unlock()
+}
+
+// GetSyncPolicy gets the sync policy for the data files in a table's folder.
+func GetSyncPolicy(folder string) uint8 {
+	syncPoliciesMux.Lock()
+	policy, ok := syncPolicies[filepath.Clean(folder)]
+	syncPoliciesMux.Unlock()
+	if !ok {
+		return helpers.DefaultSyncPolicy
+	}
+	return policy
+}
+
+// Gets the sync policy for a data file
+func getSyncPolicy(file string) uint8 {
+	return GetSyncPolicy(filepath.Dir(file))
+}
+
+// SetSyncInterval sets how often data files using SyncPolicyInterval are flushed to the disk.
+func SetSyncInterval(t time.Duration) {
+	if t <= 0 {
+		return
+	}
+	syncInterval.Store(t)
+}
+
+// Flushes every OpenFile with unsynced writes to the disk on the sync interval, until stop is closed.
+func syncTimer(stop chan bool) {
+	for {
+		select {
+		case <-time.After(syncInterval.Load().(time.Duration)):
+			syncOpenFiles()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// SyncFile flushes a data file's writes to the disk. Closed data files were flushed when they were closed.
+func SyncFile(file string) int {
+	openFilesMux.Lock()
+	f := openFiles[file]
+	openFilesMux.Unlock()
+	if f == nil {
+		return 0
+	}
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	if f.dirty {
+		if err := f.file.Sync(); err != nil {
+			return helpers.ErrorFileWrite
+		}
+		f.dirty = false
+	}
+	return 0
+}
+
+// Flushes every OpenFile with unsynced writes to the disk
+func syncOpenFiles() {
+	openFilesMux.Lock()
+	files := make([]*OpenFile, 0, len(openFiles))
+	for _, f := range openFiles {
+		files = append(files, f)
+	}
+	openFilesMux.Unlock()
+	for _, f := range files {
+		f.mux.Lock()
+		if f.dirty {
+			f.file.Sync()
+			f.dirty = false
+		}
+		f.mux.Unlock()
+	}
+}
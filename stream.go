@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bufio"
+	"github.com/gorilla/websocket"
+	"github.com/hewiefreeman/GopherDB/helpers"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+)
+
+const (
+	streamAddress    string = "localhost:8083" // TCP stream address
+	streamPath       string = "/stream"        // WebSocket stream path on the HTTP server
+	maxStreamQueries int    = 64               // Maximum queries running at once for each stream connection
+)
+
+var (
+	wsUpgrader = websocket.Upgrader{ReadBufferSize: 4096, WriteBufferSize: 4096}
+)
+
+// streamAuth is the first message a client sends on a stream connection.
+type streamAuth struct {
+	Name string
+	Pass string
+}
+
+// streamQuery is a query sent on a stream connection, tagged with a client chosen ID.
+type streamQuery struct {
+	ID uint64
+	Q  []interface{}
+}
+
+// streamResponse is the response to a streamQuery, tagged with the query's ID. The response to a streamAuth has ID 0.
+type streamResponse struct {
+	ID uint64
+	R  interface{}
+	E  helpers.Error
+}
+
+// Example stream messages:
+//
+//     {"Name": "", "Pass": "masterPassword"}                   // First message authenticates the connection
+//     {"ID": 1, "Q": ["Get", "users", "Maya", {"mmr": []}]}    // Every message after is a query
+//     {"ID": 2, "Q": ["Update", "users", "Maya", {"mmr": 1600}]}
+//
+// Example stream responses (responses are sent as soon as their query finishes, so may be out of order):
+//
+//     {"ID": 0, "R": null, "E": {"ID": 0, "From": ""}}
+//     {"ID": 2, "R": null, "E": {"ID": 0, "From": ""}}
+//     {"ID": 1, "R": {"mmr": 1500}, "E": {"ID": 0, "From": ""}}
+//
+// TCP stream messages are separated by a new line ("\n"), and WebSocket stream messages are sent as text messages.
+
+// streamConn reads and writes whole messages on a stream connection.
+type streamConn interface {
+	read() ([]byte, error)
+	write([]byte) error
+	close()
+}
+
+// listenStreams accepts TCP stream connections until the listener fails.
+func listenStreams(address string) error {
+	l, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+	for {
+		c, aErr := l.Accept()
+		if aErr != nil {
+			return aErr
+		}
+		go serveStream(newTCPStream(c), clientAddress(c.RemoteAddr().String()))
+	}
+}
+
+// streamHandler upgrades an HTTP request to a WebSocket stream connection.
+func streamHandler(w http.ResponseWriter, r *http.Request) {
+	c, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	c.SetReadLimit(maxQuerySize)
+	serveStream(&wsStream{conn: c}, clientAddress(r.RemoteAddr))
+}
+
+// serveStream authenticates a stream connection, then runs it's queries until the connection closes.
+func serveStream(sc streamConn, client string) {
+	defer sc.close()
+	var writeMux sync.Mutex
+	send := func(res streamResponse) bool {
+		jBytes, jErr := helpers.Fjson.Marshal(res)
+		if jErr != nil {
+			jBytes, _ = helpers.Fjson.Marshal(streamResponse{ID: res.ID, E: helpers.NewError(helpers.ErrorJsonEncoding, "")})
+		}
+		writeMux.Lock()
+		defer writeMux.Unlock()
+		return sc.write(jBytes) == nil
+	}
+
+	// Connection limit
+	if cErr := openConnection(); cErr != 0 {
+		send(streamResponse{E: helpers.NewError(cErr, "")})
+		return
+	}
+	defer closeConnection()
+
+	// Authenticate
+	msg, rErr := sc.read()
+	if rErr != nil {
+		return
+	}
+	var auth streamAuth
+	if err := helpers.Fjson.Unmarshal(msg, &auth); err != nil {
+		send(streamResponse{E: helpers.NewError(helpers.ErrorJsonDecoding, "")})
+		return
+	}
+	conn, aErr := authenticate(client, auth.Name, auth.Pass)
+	if aErr != 0 {
+		send(streamResponse{E: helpers.NewError(aErr, auth.Name)})
+		return
+	}
+	if !send(streamResponse{}) {
+		return
+	}
+
+	// Run queries
+	var wg sync.WaitGroup
+	running := make(chan struct{}, maxStreamQueries)
+	for {
+		if msg, rErr = sc.read(); rErr != nil {
+			break
+		}
+		var q streamQuery
+		if err := helpers.Fjson.Unmarshal(msg, &q); err != nil {
+			send(streamResponse{ID: q.ID, E: helpers.NewError(helpers.ErrorJsonDecoding, "")})
+			continue
+		}
+		running <- struct{}{}
+		wg.Add(1)
+		go func() {
+			var res streamResponse
+			res.ID = q.ID
+			res.R, res.E = runQuery(conn, q.Q)
+			send(res)
+			<-running
+			wg.Done()
+		}()
+	}
+	// Wait for running queries before closing the connection
+	wg.Wait()
+}
+
+// TCP stream connection
+type tcpStream struct {
+	conn    net.Conn
+	scanner *bufio.Scanner
+}
+
+func newTCPStream(c net.Conn) *tcpStream {
+	s := bufio.NewScanner(c)
+	s.Buffer(make([]byte, 4096), int(maxQuerySize))
+	return &tcpStream{conn: c, scanner: s}
+}
+
+func (s *tcpStream) read() ([]byte, error) {
+	if !s.scanner.Scan() {
+		if err := s.scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+	return s.scanner.Bytes(), nil
+}
+
+func (s *tcpStream) write(msg []byte) error {
+	_, err := s.conn.Write(append(msg, '\n'))
+	return err
+}
+
+func (s *tcpStream) close() {
+	s.conn.Close()
+}
+
+// WebSocket stream connection
+type wsStream struct {
+	conn *websocket.Conn
+}
+
+func (s *wsStream) read() ([]byte, error) {
+	_, msg, err := s.conn.ReadMessage()
+	return msg, err
+}
+
+func (s *wsStream) write(msg []byte) error {
+	return s.conn.WriteMessage(websocket.TextMessage, msg)
+}
+
+func (s *wsStream) close() {
+	s.conn.Close()
+}